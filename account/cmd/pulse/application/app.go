@@ -4,10 +4,24 @@ import (
 	"context"
 	"fmt"
 	"go.uber.org/zap"
+	"net/netip"
 	"pulse-auth/cmd/pulse/config"
 	"pulse-auth/internal/authentication"
+	"pulse-auth/internal/directory"
+	"pulse-auth/internal/job"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/oidc"
+	"pulse-auth/internal/publicapi"
+	"pulse-auth/internal/saml"
+	"pulse-auth/internal/service/consent"
+	"pulse-auth/internal/service/export"
+	"pulse-auth/internal/service/federation"
+	"pulse-auth/internal/service/identity"
+	"pulse-auth/internal/service/provisioning"
+	"pulse-auth/internal/service/session"
 	"pulse-auth/internal/service/user"
-	"pulse-auth/internal/storage/postgres"
+	"pulse-auth/internal/service/username"
+	"pulse-auth/internal/storage/cache"
 	"pulse-auth/internal/token"
 	"syscall"
 )
@@ -41,31 +55,256 @@ func (a *App) Run() error {
 	httpServer := a.newHTTPServer(envStruct)
 	a.Closer.Add(httpServer.GracefulStop()...)
 
+	a.Closer.Add(envStruct.scheduler.Stop)
+
 	a.Closer.Run(httpServer.Run()...)
+	a.Closer.Run(envStruct.scheduler.Run)
 	a.Closer.Wait()
+
+	// The connections are closed last, the shutdown functions above may still be using them.
+	if err = envStruct.db.Close(); err != nil {
+		return fmt.Errorf("close storage: %w", err)
+	}
+
 	return nil
 }
 
 type env struct {
 	userService           user.Service
+	consentService        consent.Service
+	federationService     federation.Service
+	identityService       identity.Service
+	provisioningService   provisioning.Service
+	sessionService        session.Service
+	exportService         export.Service
 	authenticationService authentication.Service
+	scheduler             *job.Scheduler
+	db                    database
+	// cache is nil when the cache is disabled.
+	cache          *cache.Storage
+	trustedProxies []netip.Prefix
 }
 
 func (a *App) constructEnv(ctx context.Context) (*env, error) {
-	db, err := postgres.NewStorage(a.Logger, a.Config.Storage)
+	usernames, err := username.NewChecker(a.Config.Application.ReservedUsernames)
+	if err != nil {
+		return nil, fmt.Errorf("new username checker: %w", err)
+	}
+	trustedProxies, err := publicapi.ParseTrustedProxies(a.Config.PublicServer.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("parse trusted proxies: %w", err)
+	}
+	if a.Config.Export.SigningKey == "" {
+		return nil, fmt.Errorf("export.signing_key is required")
+	}
+	cookies, err := a.sessionCookies()
+	if err != nil {
+		return nil, fmt.Errorf("session cookies: %w", err)
+	}
+
+	db, err := a.newStorage()
 	if err != nil {
 		return nil, fmt.Errorf("new storage: %w", err)
 	}
+	if a.Config.Storage.AutoMigrate {
+		if err = db.Migrate(ctx); err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+	}
+	store, cached := a.decorateStorage(ctx, db)
 
 	tokenGenerator := token.NewGenerator(a.Config.Application)
 	userService := &user.ServiceImpl{
-		Storage:        db,
+		Storage:                 store,
+		TokenGenerator:          tokenGenerator,
+		ReauthenticationTimeout: a.Config.Application.ReauthenticationTimeout,
+		DeletionGracePeriod:     a.Config.Application.DeletionGracePeriod,
+		MaxBatchSize:            a.Config.Application.MaxUserBatchSize,
+		UsernameChangeCooldown:  a.Config.Application.UsernameChangeCooldown,
+		UsernameReservation:     a.Config.Application.UsernameReservation,
+		Usernames:               usernames,
+		Logger:                  a.Logger,
+	}
+	if a.Config.LDAP.Enable {
+		userService.Directory = a.directory()
+	}
+
+	consentService := &consent.ServiceImpl{
+		Storage:        store,
 		TokenGenerator: tokenGenerator,
+		Clients:        a.clients(),
 		Logger:         a.Logger,
 	}
 
-	return &env{
+	samlProviders, err := a.samlProviders()
+	if err != nil {
+		return nil, fmt.Errorf("saml providers: %w", err)
+	}
+
+	federationService := &federation.ServiceImpl{
+		Storage:             store,
+		TokenGenerator:      tokenGenerator,
+		Providers:           a.identityProviders(),
+		SAMLProviders:       samlProviders,
+		StateKey:            []byte(a.Config.Application.SaltValue),
+		Usernames:           usernames,
+		DeletionGracePeriod: a.Config.Application.DeletionGracePeriod,
+		Logger:              a.Logger,
+	}
+
+	identityService := &identity.ServiceImpl{
+		Storage:                 store,
+		FederationService:       federationService,
+		ReauthenticationTimeout: a.Config.Application.ReauthenticationTimeout,
+		Logger:                  a.Logger,
+	}
+
+	provisioningService := &provisioning.ServiceImpl{
+		Storage:             store,
+		Usernames:           usernames,
+		DeletionGracePeriod: a.Config.Application.DeletionGracePeriod,
+		Logger:              a.Logger,
+	}
+
+	sessionService := &session.ServiceImpl{
+		Storage:   store,
+		Retention: a.Config.Application.SessionRetention,
+		Logger:    a.Logger,
+	}
+
+	exportService := &export.ServiceImpl{
+		Storage:      store,
+		Key:          []byte(a.Config.Export.SigningKey),
+		LinkLifetime: a.Config.Export.LinkLifetime,
+		Retention:    a.Config.Export.Retention,
+		StaleTimeout: a.Config.Export.StaleTimeout,
+		Logger:       a.Logger,
+	}
+
+	environment := &env{
 		userService:           userService,
-		authenticationService: authentication.Service{UserService: userService},
+		consentService:        consentService,
+		federationService:     federationService,
+		identityService:       identityService,
+		provisioningService:   provisioningService,
+		sessionService:        sessionService,
+		exportService:         exportService,
+		authenticationService: authentication.Service{UserService: userService, Cookies: cookies},
+		db:                    db,
+		cache:                 cached,
+		trustedProxies:        trustedProxies,
+	}
+	if environment.scheduler, err = a.newScheduler(db, environment); err != nil {
+		return nil, fmt.Errorf("new scheduler: %w", err)
+	}
+
+	return environment, nil
+}
+
+// sessionCookies is nil unless the cookie mode is enabled. CSRF tokens get a key of their own, so leaking one secret
+// doesn't let anyone forge the other.
+func (a *App) sessionCookies() (*authentication.Cookies, error) {
+	cfg := a.Config.PublicServer.SessionCookie
+	if !cfg.Enable {
+		return nil, nil
+	}
+	if cfg.CSRFKey == "" {
+		return nil, fmt.Errorf("session_cookie.csrf_key is required when session cookies are enabled")
+	}
+
+	return &authentication.Cookies{
+		Config: cfg,
+		Key:    []byte(cfg.CSRFKey),
 	}, nil
 }
+
+func (a *App) clients() map[model.ClientID]*model.Client {
+	clients := make(map[model.ClientID]*model.Client, len(a.Config.Clients))
+	for _, client := range a.Config.Clients {
+		clients[model.ClientID(client.ID)] = &model.Client{
+			ClientID:     model.ClientID(client.ID),
+			Name:         client.Name,
+			RedirectURIs: client.RedirectURIs,
+			Scopes:       client.Scopes,
+		}
+	}
+
+	return clients
+}
+
+func (a *App) identityProviders() map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(a.Config.IdentityProviders))
+	for _, provider := range a.Config.IdentityProviders {
+		providers[provider.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		})
+	}
+
+	return providers
+}
+
+func (a *App) samlProviders() (map[string]*saml.Provider, error) {
+	cfg := a.Config.SAML
+	providers := make(map[string]*saml.Provider, len(cfg.IdentityProviders))
+	if len(cfg.IdentityProviders) == 0 {
+		return providers, nil
+	}
+
+	var providerConfig saml.Config
+	if cfg.CertificatePath != "" {
+		key, certificate, err := saml.LoadKeyPair(cfg.CertificatePath, cfg.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("load key pair: %w", err)
+		}
+		providerConfig.Key, providerConfig.Certificate = key, certificate
+	}
+
+	for _, provider := range cfg.IdentityProviders {
+		providerConfig.Name = provider.Name
+		providerConfig.RootURL = cfg.RootURL
+		providerConfig.MetadataURL = provider.MetadataURL
+		providerConfig.MetadataPath = provider.MetadataPath
+		providerConfig.AllowIDPInitiated = provider.AllowIDPInitiated
+		providerConfig.Attributes = saml.Attributes{
+			Username:   provider.Attributes.Username,
+			Email:      provider.Attributes.Email,
+			FirstName:  provider.Attributes.FirstName,
+			SecondName: provider.Attributes.SecondName,
+			City:       provider.Attributes.City,
+		}
+
+		samlProvider, err := saml.NewProvider(providerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("new provider %s: %w", provider.Name, err)
+		}
+		providers[provider.Name] = samlProvider
+	}
+
+	return providers, nil
+}
+
+func (a *App) directory() *directory.LDAPAuthenticator {
+	cfg := a.Config.LDAP
+	return directory.NewLDAPAuthenticator(directory.Config{
+		URL:                cfg.URL,
+		StartTLS:           cfg.StartTLS,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		BindDN:             cfg.BindDN,
+		BindPassword:       cfg.BindPassword,
+		BaseDN:             cfg.BaseDN,
+		UserFilter:         cfg.UserFilter,
+		Attributes: directory.Attributes{
+			Subject:    cfg.Attributes.Subject,
+			Username:   cfg.Attributes.Username,
+			FirstName:  cfg.Attributes.FirstName,
+			SecondName: cfg.Attributes.SecondName,
+			City:       cfg.Attributes.City,
+			Groups:     cfg.Attributes.Groups,
+		},
+		GroupRoles: cfg.GroupRoles,
+	})
+}
@@ -2,34 +2,147 @@ package application
 
 import (
 	"github.com/go-chi/chi/v5"
+	"pulse-auth/internal/adminapi"
+	"pulse-auth/internal/model"
 	"pulse-auth/internal/publicapi"
+	"pulse-auth/internal/scimapi"
 )
 
 func (a *App) newHTTPServer(env *env) *HTTPServerWrapper {
 	return NewHTTPServerWrapper(
 		a.Logger,
-		WithAdminServer(a.Config.AdminServer),
+		WithAdminServer(a.Config.AdminServer, a.adminMux(env)),
 		WithPublicServer(a.Config.PublicServer, a.publicMux(env)),
 	)
 }
 
+func (a *App) adminMux(env *env) *chi.Mux {
+	mux := chi.NewMux()
+
+	handler := adminapi.Handler{
+		Logger:    a.Logger,
+		Cache:     env.cache,
+		Scheduler: env.scheduler,
+	}
+
+	if env.cache != nil {
+		mux.Get("/cache", handler.CacheStats)
+	}
+	mux.Get("/jobs", handler.Jobs)
+
+	return mux
+}
+
 func (a *App) publicMux(env *env) *chi.Mux {
 	mux := chi.NewMux()
 
 	handler := publicapi.Handler{
-		Logger:      a.Logger,
-		UserService: env.userService,
+		Logger:            a.Logger,
+		UserService:       env.userService,
+		ConsentService:    env.consentService,
+		FederationService: env.federationService,
+		IdentityService:   env.identityService,
+		SessionService:    env.sessionService,
+		ExportService:     env.exportService,
+		Cookies:           env.authenticationService.Cookies,
+		TrustedProxies:    env.trustedProxies,
 	}
 
-	mux.Post("/login", handler.Login)
-	mux.Post("/user/register", handler.Register)
+	// Signing in sets the session cookie, so cross-site forms must not be able to do it.
+	mux.With(env.authenticationService.SameOriginInterceptor).Post("/login", handler.Login)
+	mux.Get("/login/{provider}", handler.LoginWithProvider)
+	mux.Get("/login/{provider}/callback", handler.ProviderCallback)
+	mux.Route("/saml/{idp}", func(r chi.Router) {
+		r.Get("/metadata", handler.SAMLMetadata)
+		r.Get("/login", handler.LoginWithSAML)
+		r.Post("/acs", handler.SAMLAssertionConsumer)
+	})
+	mux.With(env.authenticationService.SameOriginInterceptor).Post("/user/register", handler.Register)
+	mux.With(env.authenticationService.SameOriginInterceptor).Post("/user/restore", handler.Restore)
+	mux.Get("/exports/{exportID}/download", handler.DownloadExport)
+	profileScope := env.authenticationService.RequireScope(model.ScopeProfile)
 	mux.Route("/user", func(r chi.Router) {
-		r.Use(env.authenticationService.AuthenticationInterceptor)
+		// Profiles are visible to anonymous callers too, limited to the fields their owners made public.
+		r.With(env.authenticationService.OptionalAuthenticationInterceptor, profileScope).Get("/{userID}", handler.GetUserByID)
+
+		r.Group(func(r chi.Router) {
+			r.Use(env.authenticationService.AuthenticationInterceptor)
+
+			// OAuth clients may read profiles with the profile scope and sign their own token out.
+			r.With(profileScope).Get("/me", handler.GetMe)
+			r.With(profileScope).Get("/search", handler.SearchUser)
+			r.Post("/logout", handler.Logout)
+
+			r.Group(func(r chi.Router) {
+				r.Use(env.authenticationService.FirstPartyInterceptor)
+
+				r.Patch("/me", handler.UpdateMe)
+				r.Delete("/me", handler.DeleteMe)
+				r.Put("/me/username", handler.ChangeUsername)
+				r.Post("/me/export", handler.RequestExport)
+				r.Get("/me/export/{exportID}", handler.GetExport)
 
-		r.Get("/{userID}", handler.GetUserByID)
+				r.Get("/consents", handler.ListConsents)
+				r.Delete("/consents/{clientID}", handler.RevokeConsent)
 
-		r.Get("/search", handler.SearchUser)
+				r.Post("/reauthenticate", handler.Reauthenticate)
+				r.Get("/identities", handler.ListIdentities)
+				r.Post("/identities", handler.LinkIdentity)
+				r.Delete("/identities/{identityID}", handler.UnlinkIdentity)
+
+				r.Get("/sessions", handler.ListSessions)
+				r.Delete("/sessions", handler.RevokeSessions)
+				r.Delete("/sessions/{sessionID}", handler.RevokeSession)
+			})
+		})
 	})
+	mux.Route("/users", func(r chi.Router) {
+		r.Use(env.authenticationService.AuthenticationInterceptor, profileScope)
+
+		r.Get("/search", handler.SearchUsers)
+		r.Post("/batch", handler.GetUsersByIDs)
+		r.Get("/by-username/{username}", handler.GetUserByUsername)
+	})
+	mux.Route("/oauth", func(r chi.Router) {
+		// Clients send the browser here, it comes with the session cookie rather than a bearer token.
+		// Only the user approves consents, never a client with a token of its own.
+		r.With(env.authenticationService.NavigationInterceptor, env.authenticationService.FirstPartyInterceptor).
+			Get("/authorize", handler.Authorize)
+		r.With(env.authenticationService.AuthenticationInterceptor, env.authenticationService.FirstPartyInterceptor).
+			Post("/authorize", handler.DecideConsent)
+	})
+	if a.Config.SCIM.Enable {
+		mux.Route("/scim/v2", a.scimRoutes(env))
+	}
 
 	return mux
 }
+
+func (a *App) scimRoutes(env *env) func(r chi.Router) {
+	tokenHashes := make(map[string]string, len(a.Config.SCIM.Clients))
+	for _, client := range a.Config.SCIM.Clients {
+		tokenHashes[client.TokenHash] = client.Name
+	}
+
+	handler := scimapi.Handler{
+		Logger:              a.Logger,
+		ProvisioningService: env.provisioningService,
+		BaseURL:             a.Config.SCIM.BaseURL,
+		TokenHashes:         tokenHashes,
+		MaxResults:          a.Config.SCIM.MaxResults,
+	}
+
+	return func(r chi.Router) {
+		r.Use(handler.AuthenticationInterceptor)
+
+		r.Get("/ServiceProviderConfig", handler.ServiceProviderConfig)
+		r.Get("/Users", handler.ListUsers)
+		r.Post("/Users", handler.CreateUser)
+		r.Get("/Users/{userID}", handler.GetUser)
+		r.Put("/Users/{userID}", handler.ReplaceUser)
+		r.Patch("/Users/{userID}", handler.PatchUser)
+		r.Delete("/Users/{userID}", handler.DeleteUser)
+		r.HandleFunc("/Groups", handler.Groups)
+		r.HandleFunc("/Groups/*", handler.Groups)
+	}
+}
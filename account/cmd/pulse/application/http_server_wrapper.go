@@ -24,9 +24,9 @@ type httpServerOption struct {
 
 type HTTPServerOption func(*httpServerOption)
 
-func WithAdminServer(cfg config.ServerConfig) HTTPServerOption {
+func WithAdminServer(cfg config.ServerConfig, mux *chi.Mux) HTTPServerOption {
 	return func(opts *httpServerOption) {
-		opts.adminServerOption = &ServerOption{Port: cfg.Port}
+		opts.adminServerOption = &ServerOption{Port: cfg.Port, Mux: mux}
 	}
 }
 
@@ -54,7 +54,7 @@ func NewHTTPServerWrapper(logger *zap.Logger, opts ...HTTPServerOption) *HTTPSer
 	var servers []*http.Server
 
 	if options.adminServerOption != nil {
-		servers = append(servers, newNetHTTPServer(logger, options.adminServerOption.Port, nil))
+		servers = append(servers, newNetHTTPServer(logger, options.adminServerOption.Port, options.adminServerOption.Mux))
 	}
 
 	for _, option := range options.publicServersOption {
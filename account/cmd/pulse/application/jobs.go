@@ -0,0 +1,78 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pulse-auth/internal/job"
+	"pulse-auth/internal/storage/postgres"
+)
+
+// newScheduler registers the periodic jobs. Instances sharing a postgres database take turns through advisory
+// locks, so each run of a job happens on one of them. The sqlite storage has a single instance.
+// Key rotation isn't one of them until tokens are signed with a persistent key, the generator uses a key per token.
+func (a *App) newScheduler(db database, env *env) (*job.Scheduler, error) {
+	var locker job.Locker = job.LocalLocker{}
+	if pg, ok := db.(*postgres.Storage); ok {
+		locker = pg
+	}
+	scheduler := job.NewScheduler(locker, a.Logger)
+
+	jobs := []job.Job{
+		{
+			Name:     "token_cleanup",
+			Schedule: a.Config.Jobs.TokenCleanup,
+			Run: func(ctx context.Context) error {
+				deleted, err := env.sessionService.DeleteExpiredSessions(ctx)
+				if err != nil {
+					return fmt.Errorf("delete expired sessions: %w", err)
+				}
+				if deleted > 0 {
+					a.Logger.Sugar().Infof("deleted %d expired sessions", deleted)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "account_purge",
+			Schedule: a.Config.Jobs.AccountPurge,
+			Run: func(ctx context.Context) error {
+				purged, err := env.userService.PurgeDeletedAccounts(ctx)
+				if err != nil {
+					return fmt.Errorf("purge deleted accounts: %w", err)
+				}
+				if purged > 0 {
+					a.Logger.Sugar().Infof("purged %d deleted accounts", purged)
+				}
+				return nil
+			},
+		},
+		{
+			// Exports are kept in the database, so the ones queued before a restart are picked up again.
+			Name:     "exports",
+			Schedule: a.Config.Jobs.Exports,
+			Run: func(ctx context.Context) error {
+				processed, processErr := env.exportService.ProcessExports(ctx)
+				if processed > 0 {
+					a.Logger.Sugar().Infof("built %d exports", processed)
+				}
+				if processErr != nil {
+					processErr = fmt.Errorf("process exports: %w", processErr)
+				}
+
+				_, deleteErr := env.exportService.DeleteExpiredExports(ctx)
+				if deleteErr != nil {
+					deleteErr = fmt.Errorf("delete expired exports: %w", deleteErr)
+				}
+				return errors.Join(processErr, deleteErr)
+			},
+		},
+	}
+	for _, j := range jobs {
+		if err := scheduler.Add(j); err != nil {
+			return nil, fmt.Errorf("add job: %w", err)
+		}
+	}
+
+	return scheduler, nil
+}
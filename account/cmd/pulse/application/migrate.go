@@ -0,0 +1,86 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = "usage: pulse migrate up|down|status|create <name>"
+
+// Migrate runs the migrate subcommand with its arguments: up applies pending migrations, down rolls back the last
+// one, status lists them and create adds an empty SQL migration to the migrations directory.
+func (a *App) Migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		if err := goose.Create(nil, a.migrationsDir(), args[1], "sql"); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		return nil
+	}
+
+	ctx := context.Background()
+	db, err := a.newStorage()
+	if err != nil {
+		return fmt.Errorf("new storage: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			a.Logger.Sugar().Errorf("close storage: %v", err)
+		}
+	}()
+
+	migrator, err := db.NewMigrator()
+	if err != nil {
+		return fmt.Errorf("new migrator: %w", err)
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("up: %w", err)
+		}
+		for _, result := range results {
+			a.Logger.Sugar().Infof("applied migration %s in %s", result.Source.Path, result.Duration)
+		}
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("down: %w", err)
+		}
+		a.Logger.Sugar().Infof("rolled back migration %s in %s", result.Source.Path, result.Duration)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("status: %w", err)
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "MIGRATION\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Local().String()
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", status.Source.Path, status.State, appliedAt)
+		}
+		if err = writer.Flush(); err != nil {
+			return fmt.Errorf("flush status: %w", err)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
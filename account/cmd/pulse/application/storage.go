@@ -0,0 +1,89 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/storage/cache"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/storage/postgres"
+	"pulse-auth/internal/storage/sqlite"
+
+	"github.com/pressly/goose/v3"
+)
+
+// database is a storage backend with its own migrations. It is closed once the application stopped using it.
+type database interface {
+	storage.Storage
+	NewMigrator() (*goose.Provider, error)
+	Migrate(ctx context.Context) error
+	Close() error
+}
+
+// memoryDatabase is the memory storage as a database. It has no schema, so there is nothing to migrate or close.
+type memoryDatabase struct {
+	*memory.Storage
+}
+
+func (memoryDatabase) NewMigrator() (*goose.Provider, error) {
+	return nil, fmt.Errorf("the memory storage has no migrations")
+}
+
+func (memoryDatabase) Migrate(context.Context) error {
+	return nil
+}
+
+func (memoryDatabase) Close() error {
+	return nil
+}
+
+// newStorage opens the storage backend selected by the driver in the config.
+func (a *App) newStorage() (database, error) {
+	switch a.Config.Storage.Driver {
+	case config.DriverPostgres:
+		return postgres.NewStorage(a.Logger, a.Config.Storage)
+	case config.DriverSQLite:
+		return sqlite.NewStorage(a.Logger, a.Config.Storage)
+	case config.DriverMemory:
+		a.Logger.Warn("the memory storage loses everything on restart, use it for development only")
+		return memoryDatabase{memory.NewStorage()}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", a.Config.Storage.Driver)
+	}
+}
+
+// migrationsDir is where `pulse migrate create` writes migrations for the selected driver.
+func (a *App) migrationsDir() string {
+	if a.Config.Storage.Driver == config.DriverSQLite {
+		return filepath.Join(a.Config.Storage.MigrationsDir, sqlite.MigrationsDir)
+	}
+
+	return a.Config.Storage.MigrationsDir
+}
+
+// decorateStorage starts the replica health checks of the postgres storage and puts the cache in front of the
+// storage when it is enabled. The cache is nil otherwise. Instances sharing a postgres database exchange cache
+// invalidations through it, the sqlite storage has a single instance.
+func (a *App) decorateStorage(ctx context.Context, db database) (storage.Storage, *cache.Storage) {
+	pg, _ := db.(*postgres.Storage)
+	if pg != nil {
+		a.Closer.Run(func() error { return pg.CheckReplicas(ctx) })
+	}
+
+	if !a.Config.Cache.Enable {
+		return db, nil
+	}
+
+	var notifier cache.Notifier
+	if pg != nil {
+		notifier = pg
+	}
+	cached := cache.NewStorage(db, notifier, a.Config.Cache, a.Logger)
+	if pg != nil {
+		a.Closer.Run(func() error { return pg.Listen(ctx, cache.Channel, cached) })
+	}
+
+	return cached, cached
+}
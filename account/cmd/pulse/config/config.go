@@ -15,6 +15,15 @@ type Config struct {
 	PublicServer ServerConfig      `yaml:"public_server"`
 	AdminServer  ServerConfig      `yaml:"admin_server"`
 	Storage      StorageConfig
+	Clients      []ClientConfig `yaml:"clients"`
+
+	IdentityProviders []IdentityProviderConfig `yaml:"identity_providers"`
+	LDAP              LDAPConfig               `yaml:"ldap"`
+	SAML              SAMLConfig               `yaml:"saml"`
+	SCIM              SCIMConfig               `yaml:"scim"`
+	Export            ExportConfig             `yaml:"export"`
+	Cache             CacheConfig              `yaml:"cache"`
+	Jobs              JobsConfig               `yaml:"jobs"`
 }
 
 type LoggerConfig struct {
@@ -28,6 +37,19 @@ type ApplicationConfig struct {
 	GracefulShutdownTimeout time.Duration `yaml:"graceful_shutdown_timeout"`
 	App                     string        `yaml:"app"`
 	SaltValue               string        `yaml:"salt_value"`
+	ReauthenticationTimeout time.Duration `yaml:"reauthentication_timeout" env-default:"5m"`
+	// DeletionGracePeriod is how long a deleted account can be restored before its personal data is purged.
+	DeletionGracePeriod time.Duration `yaml:"deletion_grace_period" env-default:"720h"`
+	// SessionRetention is how long expired and revoked sessions are kept before they are deleted.
+	SessionRetention time.Duration `yaml:"session_retention" env-default:"168h"`
+	// MaxUserBatchSize caps the number of ids accepted by POST /users/batch.
+	MaxUserBatchSize int `yaml:"max_user_batch_size" env-default:"500"`
+	// UsernameChangeCooldown is the minimum time between username changes of a user.
+	UsernameChangeCooldown time.Duration `yaml:"username_change_cooldown" env-default:"720h"`
+	// UsernameReservation is how long nobody else can take a username after it was changed.
+	UsernameReservation time.Duration `yaml:"username_reservation" env-default:"2160h"`
+	// ReservedUsernames can not be registered or taken by a rename, whatever their case or width.
+	ReservedUsernames []string `yaml:"reserved_usernames"`
 }
 
 type ServerConfig struct {
@@ -35,13 +57,189 @@ type ServerConfig struct {
 	Endpoint     string `yaml:"endpoint"`
 	Port         int    `yaml:"port" env:"PORT"`
 	JwtTokenSalt string `yaml:"jwt_token_salt" env:"JWT_TOKEN_SALT"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies in front of the server. Only their
+	// X-Forwarded-For header is believed.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	SessionCookie SessionCookieConfig `yaml:"session_cookie"`
+}
+
+// SessionCookieConfig enables browser sessions kept in an HttpOnly cookie instead of the Authorization header.
+type SessionCookieConfig struct {
+	Enable   bool          `yaml:"enable"`
+	Name     string        `yaml:"name" env-default:"pulse_session"`
+	CSRFName string        `yaml:"csrf_name" env-default:"pulse_csrf"`
+	Domain   string        `yaml:"domain"`
+	Path     string        `yaml:"path" env-default:"/"`
+	Lifetime time.Duration `yaml:"lifetime" env-default:"24h"`
+	SameSite string        `yaml:"same_site" env-default:"lax"`
+	// LoginURL is where browsers navigating to the authorization endpoint without a session are sent to sign in.
+	// It gets the original request in the return_to parameter.
+	LoginURL string `yaml:"login_url"`
+	// CSRFKey binds CSRF tokens to sessions. It is required with the cookie mode and must not be shared with
+	// any other secret.
+	CSRFKey string `yaml:"csrf_key" env:"SESSION_CSRF_KEY"`
+	// AllowedOrigins are the origins of the frontends that may sign in with the cookie mode. When empty only the
+	// origin of the server itself is allowed.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// ExportConfig controls personal data exports built in the background.
+type ExportConfig struct {
+	// SigningKey signs download links. It is required and must not be shared with any other secret.
+	SigningKey   string        `yaml:"signing_key" env:"EXPORT_SIGNING_KEY"`
+	LinkLifetime time.Duration `yaml:"link_lifetime" env-default:"15m"`
+	Retention    time.Duration `yaml:"retention" env-default:"168h"`
+	StaleTimeout time.Duration `yaml:"stale_timeout" env-default:"10m"`
+}
+
+// CacheConfig controls the in-process cache of users and active tokens.
+type CacheConfig struct {
+	Enable bool `yaml:"enable" env:"CACHE_ENABLE"`
+	// Size is the maximum number of users, and separately of tokens, kept in memory.
+	Size int `yaml:"size" env-default:"10000"`
+	// TTL bounds how long an entry can be served after a missed invalidation.
+	TTL time.Duration `yaml:"ttl" env-default:"30s"`
+}
+
+// JobsConfig sets the schedules of the periodic jobs: standard five field cron expressions or descriptors like
+// "@hourly" and "@every 10s".
+type JobsConfig struct {
+	TokenCleanup string `yaml:"token_cleanup" env-default:"@hourly"`
+	AccountPurge string `yaml:"account_purge" env-default:"@hourly"`
+	// Exports builds the queued personal data exports and deletes the expired ones.
+	Exports string `yaml:"exports" env-default:"@every 10s"`
+}
+
+type ClientConfig struct {
+	ID           string   `yaml:"id"`
+	Name         string   `yaml:"name"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+type IdentityProviderConfig struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+type LDAPConfig struct {
+	Enable             bool                 `yaml:"enable"`
+	URL                string               `yaml:"url"`
+	StartTLS           bool                 `yaml:"start_tls"`
+	InsecureSkipVerify bool                 `yaml:"insecure_skip_verify"`
+	BindDN             string               `yaml:"bind_dn"`
+	BindPassword       string               `yaml:"bind_password" env:"LDAP_BIND_PASSWORD"`
+	BaseDN             string               `yaml:"base_dn"`
+	UserFilter         string               `yaml:"user_filter" env-default:"(uid=%s)"`
+	Attributes         LDAPAttributesConfig `yaml:"attributes"`
+	GroupRoles         map[string]string    `yaml:"group_roles"`
+}
+
+type LDAPAttributesConfig struct {
+	Subject    string `yaml:"subject" env-default:"dn"`
+	Username   string `yaml:"username" env-default:"uid"`
+	FirstName  string `yaml:"first_name" env-default:"givenName"`
+	SecondName string `yaml:"second_name" env-default:"sn"`
+	City       string `yaml:"city" env-default:"l"`
+	Groups     string `yaml:"groups" env-default:"memberOf"`
+}
+
+type SAMLConfig struct {
+	RootURL           string                       `yaml:"root_url" env-default:"http://localhost:8080"`
+	CertificatePath   string                       `yaml:"certificate_path"`
+	KeyPath           string                       `yaml:"key_path"`
+	IdentityProviders []SAMLIdentityProviderConfig `yaml:"identity_providers"`
+}
+
+type SAMLIdentityProviderConfig struct {
+	Name              string               `yaml:"name"`
+	MetadataURL       string               `yaml:"metadata_url"`
+	MetadataPath      string               `yaml:"metadata_path"`
+	AllowIDPInitiated bool                 `yaml:"allow_idp_initiated"`
+	Attributes        SAMLAttributesConfig `yaml:"attributes"`
+}
+
+// SAMLAttributesConfig overrides attribute names, empty fields keep the defaults of the saml package.
+type SAMLAttributesConfig struct {
+	Username   string `yaml:"username"`
+	Email      string `yaml:"email"`
+	FirstName  string `yaml:"first_name"`
+	SecondName string `yaml:"second_name"`
+	City       string `yaml:"city"`
 }
 
+type SCIMConfig struct {
+	Enable     bool               `yaml:"enable"`
+	BaseURL    string             `yaml:"base_url" env-default:"http://localhost:8080/scim/v2"`
+	MaxResults uint64             `yaml:"max_results" env-default:"100"`
+	Clients    []SCIMClientConfig `yaml:"clients"`
+}
+
+// SCIMClientConfig keeps only the hex SHA-256 of the bearer token so the config holds no secrets.
+type SCIMClientConfig struct {
+	Name      string `yaml:"name"`
+	TokenHash string `yaml:"token_hash"`
+}
+
+// Storage drivers accepted in StorageConfig.Driver.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
 type StorageConfig struct {
+	// Driver is postgres, sqlite or memory.
+	Driver string `yaml:"driver" env:"DB_DRIVER" env-default:"postgres"`
+	// Path is the database file of the sqlite driver.
+	Path string `yaml:"path" env:"DB_PATH" env-default:"pulse.db"`
+
+	// DSN is a complete connection string. When set, it replaces the connection settings below except the pool limits.
+	DSN      string `yaml:"dsn" env:"DB_DSN"`
+	Host     string `yaml:"host" env:"DB_HOST" env-default:"localhost"`
 	Name     string `env:"DB_NAME"`
-	Port     int    `env:"DB_PORT"`
+	Port     int    `env:"DB_PORT" env-default:"5432"`
 	Username string `env:"DB_USER"`
 	Password string `env:"DB_PASSWORD"`
+
+	// SSLMode is one of disable, allow, prefer, require, verify-ca or verify-full.
+	SSLMode     string `yaml:"sslmode" env:"DB_SSLMODE" env-default:"prefer"`
+	SSLRootCert string `yaml:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert     string `yaml:"sslcert" env:"DB_SSLCERT"`
+	SSLKey      string `yaml:"sslkey" env:"DB_SSLKEY"`
+
+	ApplicationName string `yaml:"application_name" env-default:"pulse"`
+	// StatementTimeout aborts statements running longer, zero leaves the server default.
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env-default:"5"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env-default:"5m"`
+
+	// Replicas serve the profile reads of the postgres driver. Unhealthy replicas are skipped until they recover.
+	Replicas             []ReplicaConfig `yaml:"replicas"`
+	ReplicaCheckInterval time.Duration   `yaml:"replica_check_interval" env-default:"5s"`
+	// ReplicaMaxLag takes replicas further behind the primary out of rotation, zero disables the check.
+	ReplicaMaxLag time.Duration `yaml:"replica_max_lag" env-default:"10s"`
+
+	// AutoMigrate applies pending migrations on startup.
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	// MigrationsDir is where `pulse migrate create` writes new migrations.
+	MigrationsDir string `yaml:"migrations_dir" env-default:"migrations"`
+}
+
+// ReplicaConfig is a read replica, given either as a complete DSN or as a host and port that share the other
+// connection settings of the primary.
+type ReplicaConfig struct {
+	DSN  string `yaml:"dsn"`
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
 }
 
 func New() *Config {
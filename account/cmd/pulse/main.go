@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go.uber.org/zap"
 	"log"
+	"os"
 	"pulse-auth/cmd/pulse/application"
 	"pulse-auth/cmd/pulse/config"
 	"pulse-auth/internal/utils"
@@ -23,6 +24,13 @@ func main() {
 	}
 
 	app := application.New(cfg, logger)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = app.Migrate(os.Args[2:]); err != nil {
+			logger.Sugar().Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
 	if err = app.Run(); err != nil {
 		logger.Sugar().Fatalf("application stopped with error: %v", err)
 	} else {
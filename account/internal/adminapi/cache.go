@@ -0,0 +1,10 @@
+package adminapi
+
+import (
+	"net/http"
+)
+
+// CacheStats returns the hit and miss counters of the user and token caches.
+func (h *Handler) CacheStats(w http.ResponseWriter, _ *http.Request) {
+	writeResponse(w, h.Cache.Stats())
+}
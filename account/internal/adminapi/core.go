@@ -0,0 +1,28 @@
+// Package adminapi serves the operational endpoints of the admin server.
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"pulse-auth/internal/job"
+	"pulse-auth/internal/storage/cache"
+	"pulse-auth/internal/utils"
+
+	"github.com/go-http-utils/headers"
+	"go.uber.org/zap"
+)
+
+type Handler struct {
+	Logger *zap.Logger
+	// Cache is nil when the cache is disabled.
+	Cache     *cache.Storage
+	Scheduler *job.Scheduler
+}
+
+func writeResponse(w http.ResponseWriter, response any) {
+	w.Header().Set(headers.ContentType, "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, utils.InternalErrorMessage, http.StatusInternalServerError)
+	}
+}
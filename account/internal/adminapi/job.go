@@ -0,0 +1,19 @@
+package adminapi
+
+import (
+	"net/http"
+	"pulse-auth/internal/utils"
+)
+
+// Jobs returns the schedule, the next run and the last run of each periodic job. With Postgres the last runs are
+// read from the database, so every instance reports the runs of all of them.
+func (h *Handler) Jobs(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.Scheduler.Statuses(r.Context())
+	if err != nil {
+		h.Logger.Sugar().Errorf("job statuses: %v", err)
+		http.Error(w, utils.InternalErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	writeResponse(w, statuses)
+}
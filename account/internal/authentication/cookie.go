@@ -0,0 +1,170 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-http-utils/headers"
+)
+
+const csrfHeader = "X-CSRF-Token"
+
+// Cookies keeps browser sessions in an HttpOnly cookie and protects them with a double-submit CSRF token.
+// A nil *Cookies means the cookie mode is disabled and every method is a no-op.
+type Cookies struct {
+	Config config.SessionCookieConfig
+	// Key binds CSRF tokens to the session, so a token planted by a sibling domain doesn't validate.
+	Key []byte
+}
+
+// Set issues the session cookie and the CSRF cookie the frontend reads and echoes in the X-CSRF-Token header.
+func (c *Cookies) Set(w http.ResponseWriter, token *model.Token) {
+	if c == nil {
+		return
+	}
+
+	http.SetCookie(w, c.cookie(c.Config.Name, token.Token, true))
+	http.SetCookie(w, c.cookie(c.Config.CSRFName, c.csrfToken(token.TokenID), false))
+}
+
+// Clear removes both cookies, signing the browser out.
+func (c *Cookies) Clear(w http.ResponseWriter) {
+	if c == nil {
+		return
+	}
+
+	http.SetCookie(w, c.cookie(c.Config.Name, "", true))
+	http.SetCookie(w, c.cookie(c.Config.CSRFName, "", false))
+}
+
+func (c *Cookies) cookie(name, value string, httpOnly bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     c.Config.Path,
+		Domain:   c.Config.Domain,
+		Expires:  time.Now().Add(c.Config.Lifetime),
+		MaxAge:   int(c.Config.Lifetime.Seconds()),
+		HttpOnly: httpOnly,
+		Secure:   true,
+		SameSite: c.sameSite(),
+	}
+	if value == "" {
+		cookie.Expires = time.Unix(0, 0)
+		cookie.MaxAge = -1
+	}
+
+	return cookie
+}
+
+func (c *Cookies) sameSite() http.SameSite {
+	switch strings.ToLower(c.Config.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func (c *Cookies) sessionToken(r *http.Request) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	cookie, err := r.Cookie(c.Config.Name)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
+// redirectToLogin sends the browser to the login page, which returns to the request after signing in. It reports
+// false when no login page is configured.
+func (c *Cookies) redirectToLogin(w http.ResponseWriter, r *http.Request) bool {
+	if c == nil || c.Config.LoginURL == "" {
+		return false
+	}
+
+	login, err := url.Parse(c.Config.LoginURL)
+	if err != nil {
+		return false
+	}
+	query := login.Query()
+	query.Set("return_to", r.URL.RequestURI())
+	login.RawQuery = query.Encode()
+
+	http.Redirect(w, r, login.String(), http.StatusFound)
+	return true
+}
+
+// checkOrigin rejects requests a browser sent from another site. There is no session to bind a CSRF token to before
+// signing in, so the endpoints that start one rely on the Origin header, or the Referer when an older browser leaves
+// Origin out. Requests with neither don't come from a browser and can't ride on its cookies.
+func (c *Cookies) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get(headers.Origin)
+	if origin == "" {
+		referer, err := url.Parse(r.Header.Get(headers.Referer))
+		if err != nil || referer.Host == "" {
+			return nil
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	if !c.allowsOrigin(r, origin) {
+		return utils.WrapForbiddenError(fmt.Errorf("cross-site request from %s", origin), utils.CSRFTokenMismatchMessage)
+	}
+
+	return nil
+}
+
+func (c *Cookies) allowsOrigin(r *http.Request, origin string) bool {
+	if len(c.Config.AllowedOrigins) == 0 {
+		parsed, err := url.Parse(origin)
+		return err == nil && parsed.Host == r.Host
+	}
+
+	return slices.ContainsFunc(c.Config.AllowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
+}
+
+// checkCSRF requires the header to match both the CSRF cookie and the token derived from the session.
+func (c *Cookies) checkCSRF(r *http.Request, token *model.Token) error {
+	header := []byte(r.Header.Get(csrfHeader))
+	cookie, err := r.Cookie(c.Config.CSRFName)
+	if err != nil || len(header) == 0 ||
+		!hmac.Equal(header, []byte(cookie.Value)) || !hmac.Equal(header, []byte(c.csrfToken(token.TokenID))) {
+		return utils.WrapForbiddenError(fmt.Errorf("csrf token mismatch"), utils.CSRFTokenMismatchMessage)
+	}
+
+	return nil
+}
+
+func (c *Cookies) csrfToken(id model.TokenID) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte(id.String()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// isSafeMethod reports whether the method can't change state and so needs no CSRF token.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
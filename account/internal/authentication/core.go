@@ -1,18 +1,193 @@
 package authentication
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"pulse-auth/internal/model"
 	"pulse-auth/internal/service/user"
+	"pulse-auth/internal/utils"
+	"slices"
+	"strings"
+
+	"github.com/go-http-utils/headers"
 )
 
+const bearerPrefix = "Bearer "
+
+type tokenContextKey struct{}
+
 type Service struct {
 	UserService user.Service
+	// Cookies is set when browsers may authenticate with the session cookie instead of the Authorization header.
+	Cookies *Cookies
 }
 
 func (s Service) AuthenticationInterceptor(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//s.UserService.GetUserByID(r.Body)
-		// todo: check user is authenticated
+		ctx := r.Context()
+
+		token, err := s.authenticate(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, tokenContextKey{}, token)))
+	})
+}
+
+// NavigationInterceptor authenticates the pages browsers navigate to, like the authorization endpoint. Browsers
+// can't add an Authorization header there and send the session cookie instead. Without a session they are redirected
+// to the login page when one is configured.
+func (s Service) NavigationInterceptor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		token, err := s.authenticate(r)
+		if err != nil {
+			if result, ok := utils.FromError(err); ok && result.StatusCode == http.StatusUnauthorized &&
+				r.Header.Get(headers.Authorization) == "" && s.Cookies.redirectToLogin(w, r) {
+				return
+			}
+			writeError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, tokenContextKey{}, token)))
+	})
+}
+
+// RequireScope lets tokens issued to OAuth clients through only when the user granted them the scope. The user's own
+// sessions and anonymous requests pass, so it goes after the authentication interceptor.
+func (s Service) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := TokenFromContext(r.Context())
+			if err == nil && token.IssuedToClient() && !slices.Contains(token.Scopes, scope) {
+				writeError(w, utils.WrapForbiddenError(
+					fmt.Errorf("client %s lacks scope %s", token.ClientID, scope), utils.InsufficientScopeMessage))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FirstPartyInterceptor rejects tokens issued to OAuth clients. Managing the account, its consents and sessions and
+// approving consents is up to the user, whatever scopes a client was granted.
+func (s Service) FirstPartyInterceptor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := TokenFromContext(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if token.IssuedToClient() {
+			writeError(w, utils.WrapForbiddenError(
+				fmt.Errorf("client %s may not manage the account", token.ClientID), utils.InsufficientScopeMessage))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SameOriginInterceptor guards the endpoints that start a session, like login and registration, against login CSRF:
+// a cross-site form signing the browser in to an account the attacker controls. It does nothing without the cookie
+// mode, since the token is then only returned in the response the attacker can't read.
+func (s Service) SameOriginInterceptor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Cookies != nil {
+			if err := s.Cookies.checkOrigin(r); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
+
+// OptionalAuthenticationInterceptor lets anonymous requests through. Requests that present credentials
+// must still authenticate, so a revoked token isn't silently treated as anonymous.
+func (s Service) OptionalAuthenticationInterceptor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value, _ := s.credentials(r); value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.AuthenticationInterceptor(next).ServeHTTP(w, r)
+	})
+}
+
+// authenticate prefers the Authorization header. Requests authenticated by the cookie must also pass the CSRF check
+// unless they are safe, since browsers attach cookies to cross-site requests.
+func (s Service) authenticate(r *http.Request) (*model.Token, error) {
+	value, fromCookie := s.credentials(r)
+	if value == "" {
+		return nil, utils.WrapUnauthorizedError(fmt.Errorf("missing bearer token"), utils.UnauthorizedMessage)
+	}
+
+	token, err := s.UserService.Authenticate(r.Context(), &user.AuthenticateParams{
+		Token: value,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("authenticate: %w", err)
+	}
+
+	if fromCookie && !isSafeMethod(r.Method) {
+		if err = s.Cookies.checkCSRF(r, token); err != nil {
+			return nil, fmt.Errorf("check csrf: %w", err)
+		}
+	}
+
+	return token, nil
+}
+
+func (s Service) credentials(r *http.Request) (string, bool) {
+	header := r.Header.Get(headers.Authorization)
+	if strings.HasPrefix(header, bearerPrefix) {
+		return strings.TrimPrefix(header, bearerPrefix), false
+	}
+
+	return s.Cookies.sessionToken(r)
+}
+
+// TokenFromContext returns the token the request was authenticated with.
+func TokenFromContext(ctx context.Context) (*model.Token, error) {
+	token, ok := ctx.Value(tokenContextKey{}).(*model.Token)
+	if !ok {
+		return nil, utils.WrapUnauthorizedError(fmt.Errorf("request is not authenticated"), utils.UnauthorizedMessage)
+	}
+
+	return token, nil
+}
+
+// UserIDFromContext returns the ID of the authenticated user.
+func UserIDFromContext(ctx context.Context) (model.UserID, error) {
+	token, err := TokenFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return token.UserID, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	errorResult, ok := utils.FromError(err)
+	if !ok {
+		errorResult = utils.WrapInternalError(err)
+	}
+
+	w.Header().Set(headers.ContentType, "application/json")
+	w.WriteHeader(errorResult.StatusCode)
+	_ = json.NewEncoder(w).Encode(
+		map[string]any{
+			"message": errorResult.Msg,
+			"code":    errorResult.StatusCode,
+		})
+}
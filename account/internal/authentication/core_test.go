@@ -0,0 +1,256 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/user"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubUserService struct {
+	user.Service
+	token *model.Token
+}
+
+func (s stubUserService) Authenticate(_ context.Context, params *user.AuthenticateParams) (*model.Token, error) {
+	if params.Token != s.token.Token {
+		return nil, utils.WrapUnauthorizedError(errors.New("unknown token"), utils.UnauthorizedMessage)
+	}
+	return s.token, nil
+}
+
+func TestAuthenticationInterceptorWithCookies(t *testing.T) {
+	token := &model.Token{TokenID: "token-id", UserID: "user-id", Token: "jwt"}
+	cookies := &Cookies{
+		Config: config.SessionCookieConfig{Name: "session", CSRFName: "csrf", Path: "/", Lifetime: time.Hour},
+		Key:    []byte("key"),
+	}
+	service := Service{UserService: stubUserService{token: token}, Cookies: cookies}
+
+	recorder := httptest.NewRecorder()
+	cookies.Set(recorder, token)
+	issued := recorder.Result().Cookies()
+	csrf := issued[1].Value
+
+	handler := service.AuthenticationInterceptor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := UserIDFromContext(r.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, token.UserID, userID)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		bearer   string
+		cookies  []*http.Cookie
+		header   string
+		expected int
+	}{
+		{name: "bearer", method: http.MethodPost, bearer: "jwt", expected: http.StatusOK},
+		{name: "no credentials", method: http.MethodGet, expected: http.StatusUnauthorized},
+		{name: "cookie on safe method", method: http.MethodGet, cookies: issued[:1], expected: http.StatusOK},
+		{name: "cookie without csrf", method: http.MethodPost, cookies: issued[:1], expected: http.StatusForbidden},
+		{name: "cookie with csrf", method: http.MethodDelete, cookies: issued, header: csrf, expected: http.StatusOK},
+		{
+			name:     "csrf cookie planted with matching header",
+			method:   http.MethodPost,
+			cookies:  []*http.Cookie{issued[0], {Name: "csrf", Value: "planted"}},
+			header:   "planted",
+			expected: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, "/user/sessions", nil)
+			if test.bearer != "" {
+				request.Header.Set("Authorization", "Bearer "+test.bearer)
+			}
+			for _, cookie := range test.cookies {
+				request.AddCookie(cookie)
+			}
+			if test.header != "" {
+				request.Header.Set(csrfHeader, test.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, test.expected, recorder.Code)
+		})
+	}
+}
+
+func TestOptionalAuthenticationInterceptor(t *testing.T) {
+	token := &model.Token{TokenID: "token-id", UserID: "user-id", Token: "jwt"}
+	service := Service{UserService: stubUserService{token: token}}
+
+	var authenticated bool
+	handler := service.OptionalAuthenticationInterceptor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := TokenFromContext(r.Context())
+		authenticated = err == nil
+	}))
+
+	tests := []struct {
+		name          string
+		bearer        string
+		expected      int
+		authenticated bool
+	}{
+		{name: "anonymous", expected: http.StatusOK},
+		{name: "bearer", bearer: "jwt", expected: http.StatusOK, authenticated: true},
+		{name: "revoked bearer", bearer: "revoked", expected: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticated = false
+			request := httptest.NewRequest(http.MethodGet, "/user/user-id", nil)
+			if test.bearer != "" {
+				request.Header.Set("Authorization", "Bearer "+test.bearer)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expected, recorder.Code)
+			assert.Equal(t, test.authenticated, authenticated)
+		})
+	}
+}
+
+func TestClientTokens(t *testing.T) {
+	session := &model.Token{TokenID: "session-id", UserID: "user-id", Token: "session"}
+	granted := &model.Token{TokenID: "granted-id", UserID: "user-id", Token: "granted", ClientID: "app", Scopes: []string{"profile"}}
+	bare := &model.Token{TokenID: "bare-id", UserID: "user-id", Token: "bare", ClientID: "app"}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		token   *model.Token
+		scoped  int
+		account int
+	}{
+		{name: "user session", token: session, scoped: http.StatusOK, account: http.StatusOK},
+		{name: "client with scope", token: granted, scoped: http.StatusOK, account: http.StatusForbidden},
+		{name: "client without scope", token: bare, scoped: http.StatusForbidden, account: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := Service{UserService: stubUserService{token: test.token}}
+			scoped := service.AuthenticationInterceptor(service.RequireScope("profile")(ok))
+			account := service.AuthenticationInterceptor(service.FirstPartyInterceptor(ok))
+
+			serve := func(handler http.Handler) int {
+				request := httptest.NewRequest(http.MethodGet, "/user/me", nil)
+				request.Header.Set("Authorization", "Bearer "+test.token.Token)
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+				return recorder.Code
+			}
+			assert.Equal(t, test.scoped, serve(scoped))
+			assert.Equal(t, test.account, serve(account))
+		})
+	}
+
+	// Anonymous profile reads are left to the handler.
+	service := Service{UserService: stubUserService{token: session}}
+	recorder := httptest.NewRecorder()
+	service.OptionalAuthenticationInterceptor(service.RequireScope("profile")(ok)).
+		ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/user/user-id", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestNavigationInterceptor(t *testing.T) {
+	token := &model.Token{TokenID: "token-id", UserID: "user-id", Token: "jwt"}
+	cookies := &Cookies{
+		Config: config.SessionCookieConfig{
+			Name:     "session",
+			CSRFName: "csrf",
+			Path:     "/",
+			Lifetime: time.Hour,
+			LoginURL: "https://pulse.example.com/login?theme=dark",
+		},
+		Key: []byte("key"),
+	}
+	service := Service{UserService: stubUserService{token: token}, Cookies: cookies}
+	handler := service.NavigationInterceptor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	cookies.Set(recorder, token)
+	session := recorder.Result().Cookies()[0]
+
+	request := httptest.NewRequest(http.MethodGet, "/oauth/authorize?client_id=app&scope=openid", nil)
+	request.AddCookie(session)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// Browsers without a session sign in first and come back to the authorization request.
+	request = httptest.NewRequest(http.MethodGet, "/oauth/authorize?client_id=app&scope=openid", nil)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t,
+		"https://pulse.example.com/login?return_to=%2Foauth%2Fauthorize%3Fclient_id%3Dapp%26scope%3Dopenid&theme=dark",
+		recorder.Header().Get("Location"))
+
+	// API callers with a bad token get the error rather than a login page.
+	request = httptest.NewRequest(http.MethodGet, "/oauth/authorize", nil)
+	request.Header.Set("Authorization", "Bearer revoked")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestSameOriginInterceptor(t *testing.T) {
+	handler := func(service Service) http.Handler {
+		return service.SameOriginInterceptor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	}
+	sameHost := Service{Cookies: &Cookies{}}
+	allowed := Service{Cookies: &Cookies{Config: config.SessionCookieConfig{
+		AllowedOrigins: []string{"https://app.example.com/"},
+	}}}
+
+	tests := []struct {
+		name     string
+		service  Service
+		origin   string
+		referer  string
+		expected int
+	}{
+		{name: "no browser headers", service: sameHost, expected: http.StatusOK},
+		{name: "same origin", service: sameHost, origin: "https://pulse.example.com", expected: http.StatusOK},
+		{name: "cross origin", service: sameHost, origin: "https://evil.example", expected: http.StatusForbidden},
+		{name: "null origin", service: sameHost, origin: "null", expected: http.StatusForbidden},
+		{name: "same origin referer", service: sameHost, referer: "https://pulse.example.com/login", expected: http.StatusOK},
+		{name: "cross origin referer", service: sameHost, referer: "https://evil.example/form", expected: http.StatusForbidden},
+		{name: "allowed origin", service: allowed, origin: "https://app.example.com", expected: http.StatusOK},
+		{name: "server origin not allowed", service: allowed, origin: "https://pulse.example.com", expected: http.StatusForbidden},
+		{name: "cookies disabled", service: Service{}, origin: "https://evil.example", expected: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "https://pulse.example.com/login", nil)
+			if test.origin != "" {
+				request.Header.Set("Origin", test.origin)
+			}
+			if test.referer != "" {
+				request.Header.Set("Referer", test.referer)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler(test.service).ServeHTTP(recorder, request)
+			assert.Equal(t, test.expected, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	dnAttribute    = "dn"
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found in directory")
+	ErrInvalidCredentials = errors.New("invalid directory credentials")
+)
+
+// Entry is a directory user mapped onto the fields of a local account.
+type Entry struct {
+	Subject    string
+	Username   string
+	FirstName  string
+	SecondName string
+	City       string
+	Roles      []string
+}
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (*Entry, error)
+}
+
+type Attributes struct {
+	Subject    string
+	Username   string
+	FirstName  string
+	SecondName string
+	City       string
+	Groups     string
+}
+
+type Config struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	Attributes         Attributes
+	GroupRoles         map[string]string
+}
+
+// LDAPAuthenticator finds the user with a service account and then binds as the user to check the password.
+type LDAPAuthenticator struct {
+	config Config
+}
+
+func NewLDAPAuthenticator(config Config) *LDAPAuthenticator {
+	return &LDAPAuthenticator{config: config}
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*Entry, error) {
+	// An empty password would make an unauthenticated bind succeed.
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err = conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+		return nil, fmt.Errorf("service bind: %w", err)
+	}
+
+	entry, err := a.search(conn, username)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+
+	if err = conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("user bind: %w", err)
+	}
+
+	mapped, err := a.mapEntry(entry, username)
+	if err != nil {
+		return nil, fmt.Errorf("map entry: %w", err)
+	}
+
+	return mapped, nil
+}
+
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.config.InsecureSkipVerify}
+
+	conn, err := ldap.DialURL(a.config.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(requestTimeout)
+
+	if a.config.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("start tls: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (a *LDAPAuthenticator) search(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	attributes := a.config.Attributes
+	request := ldap.NewSearchRequest(
+		a.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(requestTimeout.Seconds()), false,
+		fmt.Sprintf(a.config.UserFilter, ldap.EscapeFilter(username)),
+		[]string{
+			attributes.Subject, attributes.Username, attributes.FirstName,
+			attributes.SecondName, attributes.City, attributes.Groups,
+		},
+		nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrUserNotFound
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("filter matches %d entries", len(result.Entries))
+	}
+}
+
+// mapEntry rejects entries without a subject, all of them would be linked to the same shadow account otherwise.
+func (a *LDAPAuthenticator) mapEntry(entry *ldap.Entry, username string) (*Entry, error) {
+	attributes := a.config.Attributes
+
+	subject := entry.DN
+	if attributes.Subject != dnAttribute {
+		subject = entry.GetAttributeValue(attributes.Subject)
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("entry %s has no %s attribute", entry.DN, attributes.Subject)
+	}
+
+	mapped := &Entry{
+		Subject:    subject,
+		Username:   entry.GetAttributeValue(attributes.Username),
+		FirstName:  entry.GetAttributeValue(attributes.FirstName),
+		SecondName: entry.GetAttributeValue(attributes.SecondName),
+		City:       entry.GetAttributeValue(attributes.City),
+	}
+	if mapped.Username == "" {
+		mapped.Username = username
+	}
+
+	for _, group := range entry.GetAttributeValues(attributes.Groups) {
+		if role, ok := a.groupRole(group); ok {
+			mapped.Roles = append(mapped.Roles, role)
+		}
+	}
+
+	return mapped, nil
+}
+
+// groupRole compares group DNs the way the directory does, ignoring case and insignificant spaces.
+func (a *LDAPAuthenticator) groupRole(group string) (string, bool) {
+	groupDN, err := ldap.ParseDN(group)
+	if err != nil {
+		return "", false
+	}
+
+	for configured, role := range a.config.GroupRoles {
+		configuredDN, err := ldap.ParseDN(configured)
+		if err == nil && groupDN.EqualFold(configuredDN) {
+			return role, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,194 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	serviceDN       = "cn=pulse,ou=services,dc=example,dc=com"
+	servicePassword = "service-secret"
+	userDN          = "uid=nikita,ou=people,dc=example,dc=com"
+	userPassword    = "user-secret"
+	adminsGroupDN   = "cn=admins,ou=groups,dc=example,dc=com"
+)
+
+// stubServer is an in-process directory answering simple binds and searches for a single user.
+type stubServer struct {
+	listener net.Listener
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := &stubServer{listener: listener}
+	go server.serve()
+
+	return server
+}
+
+func (s *stubServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *stubServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *stubServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+
+			code := ldap.LDAPResultInvalidCredentials
+			if dn == serviceDN && password == servicePassword || dn == userDN && password == userPassword {
+				code = ldap.LDAPResultSuccess
+			}
+			_, _ = conn.Write(response(messageID, result(ldap.ApplicationBindResponse, code)).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			if filter == "(uid=nikita)" {
+				_, _ = conn.Write(response(messageID, userEntry()).Bytes())
+			}
+			_, _ = conn.Write(response(messageID, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func response(messageID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(op)
+
+	return packet
+}
+
+func result(tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+
+	return op
+}
+
+func userEntry() *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, userDN, "Object Name"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range map[string][]string{
+		"uid":       {"nikita"},
+		"entryUUID": {"5f9a8c1e-3f61-4a0b-a3a8-6c2d2b1f0a11"},
+		"givenName": {"Никита"},
+		"sn":        {"Иванов"},
+		"l":         {"Москва"},
+		"memberOf":  {"CN=Admins, OU=Groups, DC=example, DC=com", "cn=users,ou=groups,dc=example,dc=com"},
+	} {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+
+	return op
+}
+
+func TestLDAPAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	server := newStubServer(t)
+	authenticator := NewLDAPAuthenticator(Config{
+		URL:          server.url(),
+		BindDN:       serviceDN,
+		BindPassword: servicePassword,
+		BaseDN:       "dc=example,dc=com",
+		UserFilter:   "(uid=%s)",
+		Attributes: Attributes{
+			Subject:    "entryUUID",
+			Username:   "uid",
+			FirstName:  "givenName",
+			SecondName: "sn",
+			City:       "l",
+			Groups:     "memberOf",
+		},
+		GroupRoles: map[string]string{adminsGroupDN: "admin"},
+	})
+
+	entry, err := authenticator.Authenticate(ctx, "nikita", userPassword)
+	require.NoError(t, err)
+	assert.Equal(t, &Entry{
+		Subject:    "5f9a8c1e-3f61-4a0b-a3a8-6c2d2b1f0a11",
+		Username:   "nikita",
+		FirstName:  "Никита",
+		SecondName: "Иванов",
+		City:       "Москва",
+		Roles:      []string{"admin"},
+	}, entry)
+
+	for _, tc := range []struct {
+		username string
+		password string
+		err      error
+	}{
+		{username: "nikita", password: "wrong", err: ErrInvalidCredentials},
+		{username: "nikita", password: "", err: ErrInvalidCredentials},
+		{username: "unknown", password: userPassword, err: ErrUserNotFound},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tc.username, tc.password), func(t *testing.T) {
+			_, err := authenticator.Authenticate(ctx, tc.username, tc.password)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestLDAPAuthenticatorRejectsEntryWithoutSubject(t *testing.T) {
+	server := newStubServer(t)
+	authenticator := NewLDAPAuthenticator(Config{
+		URL:          server.url(),
+		BindDN:       serviceDN,
+		BindPassword: servicePassword,
+		BaseDN:       "dc=example,dc=com",
+		UserFilter:   "(uid=%s)",
+		Attributes:   Attributes{Subject: "objectGUID", Username: "uid"},
+	})
+
+	// The entry has no objectGUID, so it can't be told apart from other entries missing it.
+	_, err := authenticator.Authenticate(context.Background(), "nikita", userPassword)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "objectGUID")
+	assert.NotErrorIs(t, err, ErrUserNotFound)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+}
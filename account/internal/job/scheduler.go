@@ -0,0 +1,255 @@
+// Package job runs periodic jobs on cron schedules, each on a single instance at a time.
+package job
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type Job struct {
+	Name string
+	// Schedule is a standard five field cron expression, like "0 3 * * *", or a descriptor like "@hourly" or
+	// "@every 10m".
+	Schedule string
+	Run      func(ctx context.Context) error
+}
+
+// Locker elects the instance that runs a job when several of them share the storage.
+type Locker interface {
+	// TryLock runs fn while holding the lock of the job name, unless the run due at slot already happened. It
+	// reports false without running fn when another instance holds the lock or has run the slot. Instances whose
+	// clocks differ a little would otherwise run the same slot one after another.
+	TryLock(ctx context.Context, name string, slot time.Time, fn func(ctx context.Context) error) (bool, error)
+}
+
+// History reads the runs of the jobs recorded by every instance sharing the storage. Lockers that implement it
+// have the statuses report the last run of each job wherever it happened.
+type History interface {
+	// LastRuns returns the last run of each job by name. Runs that haven't finished yet have a zero FinishedAt.
+	LastRuns(ctx context.Context) (map[string]Run, error)
+}
+
+// LocalLocker runs every job right away, for storages that have a single instance.
+type LocalLocker struct{}
+
+func (LocalLocker) TryLock(ctx context.Context, _ string, _ time.Time, fn func(ctx context.Context) error) (bool, error) {
+	return true, fn(ctx)
+}
+
+type Result string
+
+const (
+	ResultOK     Result = "ok"
+	ResultFailed Result = "failed"
+	// ResultSkipped means another instance ran the job, or is running it, instead.
+	ResultSkipped Result = "skipped"
+)
+
+type Run struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Result     Result    `json:"result"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type Status struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run"`
+	// LastRun is nil until the job has run once. With a shared history it is the last finished run of any
+	// instance, it is nil while a run is going as only the latest run is recorded.
+	LastRun *Run `json:"last_run,omitempty"`
+}
+
+type entry struct {
+	job      Job
+	schedule cron.Schedule
+
+	// The fields below are guarded by the mutex of the scheduler.
+	running bool
+	next    time.Time
+	last    *Run
+}
+
+type Scheduler struct {
+	locker Locker
+	logger *zap.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mutex sync.Mutex
+	jobs  []*entry
+}
+
+func NewScheduler(locker Locker, logger *zap.Logger) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		locker: locker,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Add registers a job. Jobs added after Run are not started.
+func (s *Scheduler) Add(job Job) error {
+	schedule, err := cron.ParseStandard(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("parse schedule of %s: %w", job.Name, err)
+	}
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		schedule = alignedSchedule{every: every.Delay}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, e := range s.jobs {
+		if e.job.Name == job.Name {
+			return fmt.Errorf("job %s is already added", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, &entry{job: job, schedule: schedule})
+
+	return nil
+}
+
+// Run starts the jobs and blocks until Stop is called. A run that is due while the previous one is still going
+// is left out.
+func (s *Scheduler) Run() error {
+	s.mutex.Lock()
+	if s.ctx.Err() != nil {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.wg.Add(len(s.jobs))
+	for _, e := range s.jobs {
+		go s.loop(e)
+	}
+	s.mutex.Unlock()
+
+	<-s.ctx.Done()
+	return nil
+}
+
+// Stop cancels the running jobs and waits for them to return.
+func (s *Scheduler) Stop() error {
+	s.cancel()
+	// Run either has started its jobs by now or sees the canceled context.
+	s.mutex.Lock()
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// Statuses returns the jobs in the order they were added with their last run. The runs come from the history of
+// the locker when it keeps one, from this instance otherwise.
+func (s *Scheduler) Statuses(ctx context.Context) ([]Status, error) {
+	statuses := s.localStatuses()
+
+	history, ok := s.locker.(History)
+	if !ok {
+		return statuses, nil
+	}
+	runs, err := history.LastRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("last runs: %w", err)
+	}
+	for i := range statuses {
+		run, ok := runs[statuses[i].Name]
+		statuses[i].Running = ok && run.FinishedAt.IsZero()
+		statuses[i].LastRun = nil
+		if ok && !statuses[i].Running {
+			statuses[i].LastRun = &run
+		}
+	}
+
+	return statuses, nil
+}
+
+// localStatuses returns the jobs with their last run on this instance.
+func (s *Scheduler) localStatuses() []Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		status := Status{
+			Name:     e.job.Name,
+			Schedule: e.job.Schedule,
+			Running:  e.running,
+			NextRun:  e.next,
+		}
+		if e.last != nil {
+			last := *e.last
+			status.LastRun = &last
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// alignedSchedule runs "@every" jobs at multiples of the interval rather than an interval after the start, so that
+// instances started at different times agree on the slots.
+type alignedSchedule struct {
+	every time.Duration
+}
+
+func (s alignedSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.every).Add(s.every)
+}
+
+func (s *Scheduler) loop(e *entry) {
+	defer s.wg.Done()
+
+	for {
+		next := e.schedule.Next(time.Now())
+		s.mutex.Lock()
+		e.next = next
+		s.mutex.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(e, next)
+	}
+}
+
+func (s *Scheduler) run(e *entry, slot time.Time) {
+	s.mutex.Lock()
+	e.running = true
+	s.mutex.Unlock()
+
+	run := &Run{StartedAt: time.Now()}
+	locked, err := s.locker.TryLock(s.ctx, e.job.Name, slot, e.job.Run)
+	run.FinishedAt = time.Now()
+
+	switch {
+	case err != nil:
+		run.Result, run.Error = ResultFailed, err.Error()
+		s.logger.Sugar().Errorf("job %s: %v", e.job.Name, err)
+	case !locked:
+		run.Result = ResultSkipped
+		s.logger.Sugar().Debugf("job %s runs on another instance", e.job.Name)
+	default:
+		run.Result = ResultOK
+	}
+
+	s.mutex.Lock()
+	e.running = false
+	e.last = run
+	s.mutex.Unlock()
+}
@@ -0,0 +1,154 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+// heldLocker behaves as if another instance held every lock.
+type heldLocker struct{}
+
+func (heldLocker) TryLock(context.Context, string, time.Time, func(ctx context.Context) error) (bool, error) {
+	return false, nil
+}
+
+// historyLocker runs every job like the local locker and reports the runs recorded by other instances.
+type historyLocker struct {
+	LocalLocker
+	runs map[string]Run
+}
+
+func (l historyLocker) LastRuns(context.Context) (map[string]Run, error) {
+	return l.runs, nil
+}
+
+func TestAdd(t *testing.T) {
+	s := NewScheduler(LocalLocker{}, zap.NewNop())
+	noop := func(context.Context) error { return nil }
+
+	require.NoError(t, s.Add(Job{Name: "hourly", Schedule: "@hourly", Run: noop}))
+	require.NoError(t, s.Add(Job{Name: "nightly", Schedule: "0 3 * * *", Run: noop}))
+	require.NoError(t, s.Add(Job{Name: "often", Schedule: "@every 10s", Run: noop}))
+	assert.Error(t, s.Add(Job{Name: "hourly", Schedule: "@daily", Run: noop}))
+	assert.Error(t, s.Add(Job{Name: "broken", Schedule: "every minute", Run: noop}))
+	assert.Error(t, s.Add(Job{Name: "empty", Run: noop}))
+
+	statuses := listStatuses(t, s)
+	require.Len(t, statuses, 3)
+	assert.Equal(t, "nightly", statuses[1].Name)
+	assert.Equal(t, "0 3 * * *", statuses[1].Schedule)
+	assert.Nil(t, statuses[1].LastRun)
+}
+
+func TestEveryIsAligned(t *testing.T) {
+	s := NewScheduler(LocalLocker{}, zap.NewNop())
+	require.NoError(t, s.Add(Job{Name: "often", Schedule: "@every 10m", Run: func(context.Context) error { return nil }}))
+
+	// Instances started at different times run the same slots.
+	schedule := s.jobs[0].schedule
+	start := time.Date(2024, 8, 5, 9, 3, 27, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 8, 5, 9, 10, 0, 0, time.UTC), schedule.Next(start))
+	assert.Equal(t, time.Date(2024, 8, 5, 9, 10, 0, 0, time.UTC), schedule.Next(start.Add(5*time.Minute)))
+	assert.Equal(t, time.Date(2024, 8, 5, 9, 20, 0, 0, time.UTC), schedule.Next(time.Date(2024, 8, 5, 9, 10, 0, 0, time.UTC)))
+}
+
+func TestRunResults(t *testing.T) {
+	failure := errors.New("failure")
+	tests := []struct {
+		name   string
+		locker Locker
+		err    error
+		result Result
+		ran    bool
+	}{
+		{name: "ok", locker: LocalLocker{}, result: ResultOK, ran: true},
+		{name: "failed", locker: LocalLocker{}, err: failure, result: ResultFailed, ran: true},
+		{name: "skipped", locker: heldLocker{}, result: ResultSkipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScheduler(tt.locker, zap.NewNop())
+			ran := false
+			require.NoError(t, s.Add(Job{Name: tt.name, Schedule: "@hourly", Run: func(context.Context) error {
+				ran = true
+				return tt.err
+			}}))
+
+			s.run(s.jobs[0], time.Now())
+			assert.Equal(t, tt.ran, ran)
+
+			last := listStatuses(t, s)[0].LastRun
+			require.NotNil(t, last)
+			assert.Equal(t, tt.result, last.Result)
+			assert.False(t, last.FinishedAt.Before(last.StartedAt))
+			if tt.err != nil {
+				assert.Equal(t, tt.err.Error(), last.Error)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	s := NewScheduler(LocalLocker{}, zap.NewNop())
+	require.NoError(t, s.Add(Job{Name: "hourly", Schedule: "@hourly", Run: func(context.Context) error { return nil }}))
+
+	done := make(chan error)
+	go func() { done <- s.Run() }()
+
+	require.Eventually(t, func() bool { return !listStatuses(t, s)[0].NextRun.IsZero() }, time.Second, time.Millisecond)
+	assert.WithinDuration(t, time.Now(), listStatuses(t, s)[0].NextRun, time.Hour)
+
+	require.NoError(t, s.Stop())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("run did not return after stop")
+	}
+}
+
+func TestStopBeforeRun(t *testing.T) {
+	s := NewScheduler(LocalLocker{}, zap.NewNop())
+	require.NoError(t, s.Stop())
+	assert.NoError(t, s.Run())
+}
+
+func TestStatusesFromHistory(t *testing.T) {
+	startedAt := time.Date(2024, 8, 5, 3, 0, 0, 0, time.UTC)
+	locker := historyLocker{runs: map[string]Run{
+		"purge":   {StartedAt: startedAt, FinishedAt: startedAt.Add(time.Second), Result: ResultFailed, Error: "failure"},
+		"exports": {StartedAt: startedAt},
+	}}
+	s := NewScheduler(locker, zap.NewNop())
+	noop := func(context.Context) error { return nil }
+	require.NoError(t, s.Add(Job{Name: "purge", Schedule: "@hourly", Run: noop}))
+	require.NoError(t, s.Add(Job{Name: "exports", Schedule: "@hourly", Run: noop}))
+	require.NoError(t, s.Add(Job{Name: "cleanup", Schedule: "@hourly", Run: noop}))
+
+	// The history wins over what this instance saw, another instance may have run the job since.
+	s.run(s.jobs[0], time.Now())
+
+	statuses := listStatuses(t, s)
+	require.NotNil(t, statuses[0].LastRun)
+	assert.Equal(t, ResultFailed, statuses[0].LastRun.Result)
+	assert.Equal(t, "failure", statuses[0].LastRun.Error)
+	assert.False(t, statuses[0].Running)
+	assert.True(t, statuses[1].Running)
+	assert.Nil(t, statuses[1].LastRun)
+	assert.False(t, statuses[2].Running)
+	assert.Nil(t, statuses[2].LastRun)
+}
+
+func listStatuses(t *testing.T, s *Scheduler) []Status {
+	t.Helper()
+
+	statuses, err := s.Statuses(context.Background())
+	require.NoError(t, err)
+	return statuses
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// ScopeProfile lets a client read the profile of the user and the public profiles of others.
+const ScopeProfile = "profile"
+
+type ClientID string
+
+func (id ClientID) String() string {
+	return string(id)
+}
+
+type Client struct {
+	ClientID     ClientID
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+}
+
+// AllowsRedirectURI reports whether the redirect URI is registered for the client.
+func (c *Client) AllowsRedirectURI(redirectURI string) bool {
+	return slices.Contains(c.RedirectURIs, redirectURI)
+}
+
+// AllowsScopes reports whether every requested scope is registered for the client.
+func (c *Client) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Consent struct {
+	UserID    UserID
+	ClientID  ClientID
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MissingScopes returns requested scopes the user has not granted to the client yet.
+func (c *Consent) MissingScopes(scopes []string) []string {
+	var missing []string
+	for _, scope := range scopes {
+		if c == nil || !slices.Contains(c.Scopes, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+// ParseScopes splits a space-delimited scope string as defined by RFC 6749.
+func ParseScopes(scope string) []string {
+	scopes := strings.Fields(scope)
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+// FormatScopes joins scopes into a space-delimited scope string.
+func FormatScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type ExportID string
+
+func (id ExportID) String() string {
+	return string(id)
+}
+
+type ExportStatus string
+
+const (
+	ExportStatusPending ExportStatus = "pending"
+	ExportStatusRunning ExportStatus = "running"
+	ExportStatusReady   ExportStatus = "ready"
+	ExportStatusFailed  ExportStatus = "failed"
+)
+
+// Export is a request of the user to download the personal data we hold about them.
+// The archive itself is stored separately and only loaded for download.
+type Export struct {
+	ExportID  ExportID
+	UserID    UserID
+	Status    ExportStatus
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ExpiresAt is when a ready archive is removed. It is zero until the archive is built.
+	ExpiresAt time.Time
+}
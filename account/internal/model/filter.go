@@ -0,0 +1,113 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/validator.v2"
+)
+
+type UserField string
+
+const (
+	UserFieldID         UserField = "id"
+	UserFieldUsername   UserField = "username"
+	UserFieldFirstName  UserField = "first_name"
+	UserFieldSecondName UserField = "second_name"
+	UserFieldCity       UserField = "city"
+)
+
+type FilterOperator string
+
+const (
+	OperatorEqual      FilterOperator = "eq"
+	OperatorNotEqual   FilterOperator = "ne"
+	OperatorContains   FilterOperator = "co"
+	OperatorStartsWith FilterOperator = "sw"
+	OperatorEndsWith   FilterOperator = "ew"
+	OperatorPresent    FilterOperator = "pr"
+)
+
+// UserCondition compares a user field with a value, ignoring case.
+type UserCondition struct {
+	Field    UserField
+	Operator FilterOperator
+	Value    string
+}
+
+// UserListParams selects the users matching all conditions, ordered by creation time.
+type UserListParams struct {
+	Conditions []UserCondition
+	Offset     uint64
+	Limit      uint64
+}
+
+// UserCursor is the position after the last user of a search page in the order of second name, first name and id.
+type UserCursor struct {
+	SecondName string `json:"s"`
+	FirstName  string `json:"f"`
+	ID         UserID `json:"i"`
+}
+
+// UserSearchParams selects active users matching all the set filters, ordered by second name, first name and id.
+// Names match case-insensitively by prefix, city and sex match case-insensitively as a whole.
+type UserSearchParams struct {
+	FirstName  string
+	SecondName string
+	City       string
+	Sex        string
+	// BornAfter and BornBefore bound the birthdate, exclusively and inclusively. Zero means unbounded.
+	// Users without a birthdate don't match when either is set.
+	BornAfter  time.Time
+	BornBefore time.Time
+	// Queries switches to fuzzy search: users similar to any of the queries match, most relevant first,
+	// and pages are selected with Offset instead of After.
+	Queries []string
+	After   *UserCursor
+	Offset  uint64
+	Limit   uint64
+}
+
+// UserUpdate changes only the fields that are set. The limits are the ones of UserRegister.
+type UserUpdate struct {
+	ID         UserID  `validate:"nonzero"`
+	Username   *string `validate:"min=1,max=64"`
+	FirstName  *string `validate:"max=64"`
+	SecondName *string `validate:"max=64"`
+	Sex        *string `validate:"regexp=^(male|female|other)?$"`
+	Birthdate  *time.Time
+	Biography  *string `validate:"max=1024"`
+	City       *string `validate:"max=128"`
+
+	SexVisibility       *Visibility `validate:"regexp=^(public|authenticated|private)$"`
+	BirthdateVisibility *Visibility `validate:"regexp=^(public|authenticated|private)$"`
+	BiographyVisibility *Visibility `validate:"regexp=^(public|authenticated|private)$"`
+	CityVisibility      *Visibility `validate:"regexp=^(public|authenticated|private)$"`
+	Searchable          *bool
+}
+
+func (u *UserUpdate) Validate() error {
+	if err := validator.Validate(u); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	if u.Birthdate != nil {
+		if err := validateBirthdate(*u.Birthdate); err != nil {
+			return fmt.Errorf("validate: %w", err)
+		}
+	}
+
+	if u.Username != nil {
+		if err := ValidateUsername(*u.Username); err != nil {
+			return fmt.Errorf("validate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (u *UserUpdate) Empty() bool {
+	return u.Username == nil && u.FirstName == nil && u.SecondName == nil && u.Sex == nil &&
+		u.Birthdate == nil && u.Biography == nil && u.City == nil && u.SexVisibility == nil &&
+		u.BirthdateVisibility == nil && u.BiographyVisibility == nil && u.CityVisibility == nil && u.Searchable == nil
+}
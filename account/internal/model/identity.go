@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type IdentityID string
+
+func (id IdentityID) String() string {
+	return string(id)
+}
+
+// Identity links a subject of an external identity provider to a local user.
+type Identity struct {
+	IdentityID IdentityID
+	UserID     UserID
+	Provider   string
+	Subject    string
+	Email      string
+	CreatedAt  time.Time
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Device describes where a token was issued, as reported by the request that signed in.
+type Device struct {
+	UserAgent  string
+	IPAddress  string
+	ClientName string
+}
+
+// Session is a token together with the device it was issued to.
+type Session struct {
+	Token
+	Device    Device
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// RevokedAt is zero unless the session was signed out or revoked.
+	RevokedAt time.Time
+}
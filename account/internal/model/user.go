@@ -28,19 +28,61 @@ type User struct {
 	Birthdate  time.Time
 	Biography  string
 	City       string
+	// HasPassword is false for accounts that only sign in through external identities.
+	HasPassword bool
+	Roles       []string
+	Visibility  ProfileVisibility
+	// Searchable is false for users who opted out of appearing in search results.
+	Searchable bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// DeletedAt and DeletedBy are only set by the lookups that include deleted accounts.
+	DeletedAt time.Time
+	DeletedBy DeletedBy
 }
 
+// DeletedBy tells who deleted an account, and so who may restore it.
+type DeletedBy string
+
+const (
+	// DeletedByUser accounts were deleted by their owner, who may restore them within the grace period.
+	DeletedByUser DeletedBy = "user"
+	// DeletedByProvisioning accounts were deprovisioned by the identity system, only it may reactivate them.
+	DeletedByProvisioning DeletedBy = "provisioning"
+)
+
 type Token struct {
-	TokenID TokenID
-	UserID  UserID
-	Token   string
+	TokenID  TokenID
+	UserID   UserID
+	ClientID ClientID
+	// Scopes are what the user granted the client. Tokens of the user's own sessions have none and need none.
+	Scopes []string
+	Token  string
+	// AuthenticatedAt is when the user last proved their credentials in this session. It is zero for client
+	// tokens, which never count as a sign in.
+	AuthenticatedAt time.Time
+	LastUsedAt      time.Time
+}
+
+// IssuedToClient reports whether the token was issued to an OAuth client rather than to the user's own session.
+func (t *Token) IssuedToClient() bool {
+	return t.ClientID != ""
+}
+
+// AuthenticatedWithin reports whether the user proved their credentials recently enough for sensitive operations.
+func (t *Token) AuthenticatedWithin(window time.Duration) bool {
+	return !t.IssuedToClient() && !t.AuthenticatedAt.IsZero() && time.Since(t.AuthenticatedAt) <= window
 }
 
 type TokenWithMetadata struct {
-	TokenID  string    `validator:"nonzero"`
-	UserID   UserID    `validator:"nonzero"`
-	Token    string    `validator:"nonzero"`
-	AlivedAt time.Time `validator:"nonzero"`
+	TokenID  string    `validate:"nonzero"`
+	UserID   UserID    `validate:"nonzero"`
+	Token    string    `validate:"nonzero"`
+	AlivedAt time.Time `validate:"nonzero"`
+	ClientID ClientID
+	// Scopes are set together with ClientID.
+	Scopes []string
+	Device Device
 }
 
 func (t *TokenWithMetadata) Validate() error {
@@ -56,16 +98,22 @@ type UserLogin struct {
 	HashedPassword string
 }
 
+const (
+	SexMale   = "male"
+	SexFemale = "female"
+	SexOther  = "other"
+)
+
 type UserRegister struct {
-	ID             string `validator:"nonzero"`
-	Username       string `validator:"nonzero"`
-	HashedPassword string `validator:"nonzero"`
-	FirstName      string
-	SecondName     string
-	Sex            string
+	ID             string `validate:"nonzero"`
+	Username       string `validate:"nonzero,max=64"`
+	HashedPassword string
+	FirstName      string `validate:"max=64"`
+	SecondName     string `validate:"max=64"`
+	Sex            string `validate:"regexp=^(male|female|other)?$"`
 	Birthdate      time.Time
-	Biography      string
-	City           string
+	Biography      string `validate:"max=1024"`
+	City           string `validate:"max=128"`
 }
 
 func (u *UserRegister) Validate() error {
@@ -73,5 +121,22 @@ func (u *UserRegister) Validate() error {
 		return fmt.Errorf("validate: %w", err)
 	}
 
+	if err := validateBirthdate(u.Birthdate); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	if err := ValidateUsername(u.Username); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	return nil
+}
+
+// validateBirthdate accepts an unset birthdate or one in the past.
+func validateBirthdate(birthdate time.Time) error {
+	if birthdate.After(time.Now()) {
+		return fmt.Errorf("Birthdate: must be in the past")
+	}
+
 	return nil
 }
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserUpdateValidate(t *testing.T) {
+	empty, long, robot, female := "", strings.Repeat("я", 65), "robot", SexFemale
+	private, friends := VisibilityPrivate, Visibility("friends")
+	past, future := time.Now().AddDate(-20, 0, 0), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		update UserUpdate
+		valid  bool
+	}{
+		{name: "nothing to change", update: UserUpdate{ID: "id"}, valid: true},
+		{name: "valid fields", update: UserUpdate{ID: "id", Sex: &female, Birthdate: &past}, valid: true},
+		{name: "missing id", update: UserUpdate{Sex: &female}},
+		{name: "empty username", update: UserUpdate{ID: "id", Username: &empty}},
+		{name: "long first name", update: UserUpdate{ID: "id", FirstName: &long}},
+		{name: "unknown sex", update: UserUpdate{ID: "id", Sex: &robot}},
+		{name: "birthdate in the future", update: UserUpdate{ID: "id", Birthdate: &future}},
+		{name: "private city", update: UserUpdate{ID: "id", CityVisibility: &private}, valid: true},
+		{name: "unknown visibility", update: UserUpdate{ID: "id", SexVisibility: &friends}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.update.Validate()
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestUserRegisterValidate(t *testing.T) {
+	assert.NoError(t, (&UserRegister{ID: "id", Username: "nikita", HashedPassword: "hash"}).Validate())
+	// Accounts created by an external sign in have no password.
+	assert.NoError(t, (&UserRegister{ID: "id", Username: "nikita"}).Validate())
+	assert.Error(t, (&UserRegister{ID: "id"}).Validate())
+	assert.Error(t, (&UserRegister{ID: "id", Username: "nikita", Sex: "robot"}).Validate())
+	assert.Error(t, (&UserRegister{ID: "id", Username: "nikita", Birthdate: time.Now().Add(time.Hour)}).Validate())
+}
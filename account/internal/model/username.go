@@ -0,0 +1,92 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+)
+
+// UsernameRecord is a username the user had before. Until ReservedUntil nobody else can take it.
+type UsernameRecord struct {
+	UserID        UserID
+	Username      string
+	ChangedAt     time.Time
+	ReservedUntil time.Time
+}
+
+// scriptCombinations are the scripts that may be mixed in one username, as in the highly restrictive level of
+// Unicode Technical Standard #39. Any other mix, like Latin with Cyrillic, is how confusable usernames are made.
+var scriptCombinations = [][]*unicode.RangeTable{
+	{unicode.Latin, unicode.Han, unicode.Hiragana, unicode.Katakana},
+	{unicode.Latin, unicode.Han, unicode.Bopomofo},
+	{unicode.Latin, unicode.Han, unicode.Hangul},
+}
+
+// NormalizeUsername returns the canonical form usernames are compared and kept unique in: normalized to NFKC and
+// case mapped per the PRECIS UsernameCaseMapped profile of RFC 8265. Usernames mixing confusable scripts, like
+// "nikita" spelled with a Cyrillic "а", are rejected.
+func NormalizeUsername(username string) (string, error) {
+	normalized, err := precis.UsernameCaseMapped.String(norm.NFKC.String(username))
+	if err != nil {
+		return "", fmt.Errorf("Username: %w", err)
+	}
+
+	if normalized == "" {
+		return "", fmt.Errorf("Username: must not be empty")
+	}
+
+	if !singleScript(normalized) {
+		return "", fmt.Errorf("Username: mixes letters of different scripts")
+	}
+
+	return normalized, nil
+}
+
+// ValidateUsername checks that the username can be normalized. Whether it is reserved is up to the services.
+func ValidateUsername(username string) error {
+	_, err := NormalizeUsername(username)
+	return err
+}
+
+// singleScript reports whether the letters of the username come from one script or an allowed combination of them.
+func singleScript(username string) bool {
+	var scripts []*unicode.RangeTable
+	for _, r := range username {
+		if unicode.In(r, unicode.Common, unicode.Inherited) {
+			continue
+		}
+		script := scriptOf(r)
+		if script != nil && !slices.Contains(scripts, script) {
+			scripts = append(scripts, script)
+		}
+	}
+	if len(scripts) <= 1 {
+		return true
+	}
+
+	for _, combination := range scriptCombinations {
+		allowed := true
+		for _, script := range scripts {
+			allowed = allowed && slices.Contains(combination, script)
+		}
+		if allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scriptOf(r rune) *unicode.RangeTable {
+	for _, script := range unicode.Scripts {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		name       string
+		username   string
+		normalized string
+		valid      bool
+	}{
+		{name: "lower case", username: "nikita", normalized: "nikita", valid: true},
+		{name: "upper case", username: "Admin", normalized: "admin", valid: true},
+		{name: "full width", username: "ａｄｍｉｎ", normalized: "admin", valid: true},
+		{name: "ligature", username: "ﬁona", normalized: "fiona", valid: true},
+		{name: "cyrillic", username: "Никита", normalized: "никита", valid: true},
+		{name: "digits and punctuation", username: "nikita.ivanov_92", normalized: "nikita.ivanov_92", valid: true},
+		{name: "japanese", username: "山田たろう", normalized: "山田たろう", valid: true},
+		{name: "cyrillic a in latin", username: "nikitа"},
+		{name: "latin o in cyrillic", username: "никитo"},
+		{name: "latin and greek", username: "pαypal"},
+		{name: "space", username: "nikita ivanov"},
+		{name: "empty", username: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			normalized, err := NormalizeUsername(test.username)
+			if !test.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.normalized, normalized)
+		})
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	assert.NoError(t, ValidateUsername("Admin"))
+	assert.Error(t, ValidateUsername("nikita ivanov"))
+	assert.Error(t, (&UserRegister{ID: "id", Username: "nikitа"}).Validate())
+}
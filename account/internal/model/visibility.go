@@ -0,0 +1,49 @@
+package model
+
+// Visibility is who may see a profile field.
+type Visibility string
+
+const (
+	VisibilityPublic        Visibility = "public"
+	VisibilityAuthenticated Visibility = "authenticated"
+	VisibilityPrivate       Visibility = "private"
+)
+
+// Viewer is how the one looking at a profile relates to its owner.
+type Viewer int
+
+const (
+	ViewerAnonymous Viewer = iota
+	ViewerAuthenticated
+	ViewerSelf
+)
+
+// VisibleTo reports whether the viewer may see a field with this visibility.
+func (v Visibility) VisibleTo(viewer Viewer) bool {
+	switch v {
+	case VisibilityPublic:
+		return true
+	case VisibilityAuthenticated:
+		return viewer >= ViewerAuthenticated
+	default:
+		return viewer == ViewerSelf
+	}
+}
+
+// ProfileVisibility holds the visibility of the personal profile fields. Names and username are always visible.
+type ProfileVisibility struct {
+	Sex       Visibility
+	Birthdate Visibility
+	Biography Visibility
+	City      Visibility
+}
+
+// DefaultProfileVisibility shows the profile to signed in users, as every profile was before the settings existed.
+func DefaultProfileVisibility() ProfileVisibility {
+	return ProfileVisibility{
+		Sex:       VisibilityAuthenticated,
+		Birthdate: VisibilityAuthenticated,
+		Biography: VisibilityAuthenticated,
+		City:      VisibilityAuthenticated,
+	}
+}
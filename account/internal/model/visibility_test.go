@@ -0,0 +1,30 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisibilityVisibleTo(t *testing.T) {
+	tests := []struct {
+		visibility Visibility
+		visible    []Viewer
+		hidden     []Viewer
+	}{
+		{visibility: VisibilityPublic, visible: []Viewer{ViewerAnonymous, ViewerAuthenticated, ViewerSelf}},
+		{visibility: VisibilityAuthenticated, visible: []Viewer{ViewerAuthenticated, ViewerSelf}, hidden: []Viewer{ViewerAnonymous}},
+		{visibility: VisibilityPrivate, visible: []Viewer{ViewerSelf}, hidden: []Viewer{ViewerAnonymous, ViewerAuthenticated}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.visibility), func(t *testing.T) {
+			for _, viewer := range test.visible {
+				assert.True(t, test.visibility.VisibleTo(viewer), viewer)
+			}
+			for _, viewer := range test.hidden {
+				assert.False(t, test.visibility.VisibleTo(viewer), viewer)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID = "pulse"
+	testKeyID    = "test-key"
+)
+
+// stubProvider is a minimal identity provider issuing ID tokens for a single authorization code.
+type stubProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	code      string
+	challenge string
+	nonce     string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	stub := &stubProvider{key: key, code: "code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(discovery{
+			Issuer:                stub.server.URL,
+			AuthorizationEndpoint: stub.server.URL + "/authorize",
+			TokenEndpoint:         stub.server.URL + "/token",
+			JWKSURI:               stub.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jsonWebKeySet{Keys: []jsonWebKey{{
+			KeyID:   testKeyID,
+			KeyType: "RSA",
+			Use:     "sig",
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("code") != stub.code || CodeChallenge(r.Form.Get("code_verifier")) != stub.challenge {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":                stub.server.URL,
+			"aud":                testClientID,
+			"sub":                "subject",
+			"exp":                time.Now().Add(time.Minute).Unix(),
+			"nonce":              stub.nonce,
+			"email":              "nikita@example.com",
+			"email_verified":     true,
+			"preferred_username": "nikita",
+			"given_name":         "Никита",
+		})
+		idToken.Header["kid"] = testKeyID
+		signed, _ := idToken.SignedString(key)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+
+	return stub
+}
+
+func (s *stubProvider) authorize(t *testing.T, authURL string) {
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+
+	s.challenge = parsed.Query().Get("code_challenge")
+	s.nonce = parsed.Query().Get("nonce")
+}
+
+func TestProviderExchange(t *testing.T) {
+	ctx := context.Background()
+	stub := newStubProvider(t)
+	provider := NewProvider(Config{
+		Issuer:      stub.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/callback",
+	})
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	require.NoError(t, err)
+	stub.authorize(t, authURL)
+
+	claims, err := provider.Exchange(ctx, stub.code, "verifier", "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, &Claims{
+		Subject:           "subject",
+		Email:             "nikita@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "nikita",
+		GivenName:         "Никита",
+	}, claims)
+
+	_, err = provider.Exchange(ctx, stub.code, "verifier", "another nonce")
+	assert.ErrorContains(t, err, "nonce mismatch")
+
+	_, err = provider.Exchange(ctx, stub.code, "wrong verifier", "nonce")
+	assert.ErrorContains(t, err, "invalid_grant")
+}
@@ -0,0 +1,189 @@
+package publicapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"pulse-auth/internal/authentication"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/consent"
+)
+
+type consentScreenResponse struct {
+	ClientID        string   `json:"client_id"`
+	ClientName      string   `json:"client_name"`
+	RequestedScopes []string `json:"requested_scopes"`
+	MissingScopes   []string `json:"missing_scopes"`
+}
+
+type redirectResponse struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type consentResponse struct {
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// Authorize shows the consent screen, or redirects back to the client when the requested scopes are already granted.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*consent.AuthorizeResult, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		query := r.URL.Query()
+		result, err := h.ConsentService.Authorize(ctx, &consent.AuthorizeParams{
+			UserID:      userID,
+			ClientID:    model.ClientID(query.Get("client_id")),
+			RedirectURI: query.Get("redirect_uri"),
+			Scopes:      model.ParseScopes(query.Get("scope")),
+			State:       query.Get("state"),
+			Device:      h.deviceFromRequest(r),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("authorize: %w", err)
+		}
+
+		return result, nil
+	}
+
+	result, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("authorize: %w", err))
+		return
+	}
+	if !result.ConsentRequired {
+		http.Redirect(w, r, result.RedirectURI, http.StatusFound)
+		return
+	}
+	writeResponse(w, &consentScreenResponse{
+		ClientID:        result.Client.ClientID.String(),
+		ClientName:      result.Client.Name,
+		RequestedScopes: result.RequestedScopes,
+		MissingScopes:   result.MissingScopes,
+	})
+}
+
+type consentDecisionRequest struct {
+	ClientID    string `json:"client_id"`
+	RedirectURI string `json:"redirect_uri"`
+	Scope       string `json:"scope"`
+	State       string `json:"state"`
+	Approved    bool   `json:"approved"`
+}
+
+// DecideConsent accepts the answer from the consent screen.
+func (h *Handler) DecideConsent(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*redirectResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		request, err := parseJSONRequest[consentDecisionRequest](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse json request: %w", err)
+		}
+
+		result, err := h.ConsentService.Decide(ctx, &consent.DecideParams{
+			AuthorizeParams: consent.AuthorizeParams{
+				UserID:      userID,
+				ClientID:    model.ClientID(request.ClientID),
+				RedirectURI: request.RedirectURI,
+				Scopes:      model.ParseScopes(request.Scope),
+				State:       request.State,
+				Device:      h.deviceFromRequest(r),
+			},
+			Approved: request.Approved,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("decide: %w", err)
+		}
+
+		return &redirectResponse{RedirectURI: result.RedirectURI}, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("decide consent: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+func (h *Handler) ListConsents(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() ([]*consentResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		consents, err := h.ConsentService.ListConsents(ctx, &consent.ListConsentsParams{UserID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("list consents: %w", err)
+		}
+
+		response := make([]*consentResponse, 0, len(consents))
+		for _, c := range consents {
+			response = append(response, consentModelToResponse(c))
+		}
+
+		return response, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("list consents: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+func (h *Handler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() error {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return fmt.Errorf("user id from context: %w", err)
+		}
+
+		err = h.ConsentService.RevokeConsent(ctx, &consent.RevokeConsentParams{
+			UserID:   userID,
+			ClientID: model.ClientID(chi.URLParamFromCtx(ctx, "clientID")),
+		})
+		if err != nil {
+			return fmt.Errorf("revoke consent: %w", err)
+		}
+
+		return nil
+	}
+
+	err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("revoke consent: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func consentModelToResponse(c *consent.ClientConsent) *consentResponse {
+	response := &consentResponse{
+		ClientID:  c.Consent.ClientID.String(),
+		Scopes:    c.Consent.Scopes,
+		CreatedAt: c.Consent.CreatedAt.String(),
+		UpdatedAt: c.Consent.UpdatedAt.String(),
+	}
+	if c.Client != nil {
+		response.ClientName = c.Client.Name
+	}
+
+	return response
+}
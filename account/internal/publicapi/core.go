@@ -6,6 +6,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
+	"pulse-auth/internal/authentication"
+	"pulse-auth/internal/service/consent"
+	"pulse-auth/internal/service/export"
+	"pulse-auth/internal/service/federation"
+	"pulse-auth/internal/service/identity"
+	"pulse-auth/internal/service/session"
 	"pulse-auth/internal/service/user"
 	"pulse-auth/internal/utils"
 
@@ -14,8 +21,17 @@ import (
 )
 
 type Handler struct {
-	Logger      *zap.Logger
-	UserService user.Service
+	Logger            *zap.Logger
+	UserService       user.Service
+	ConsentService    consent.Service
+	FederationService federation.Service
+	IdentityService   identity.Service
+	SessionService    session.Service
+	ExportService     export.Service
+	// Cookies also hands signed in browsers a session cookie when the cookie mode is enabled.
+	Cookies *authentication.Cookies
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header tells the address of the client.
+	TrustedProxies []netip.Prefix
 }
 
 func (h *Handler) writeError(ctx context.Context, w http.ResponseWriter, err error) {
@@ -47,7 +63,9 @@ func writeResponse(w http.ResponseWriter, response any) {
 	}
 }
 
-func parseJSONRequest[T loginRequest | registerRequest](r *http.Request) (*T, error) {
+func parseJSONRequest[T loginRequest | registerRequest | consentDecisionRequest |
+	linkIdentityRequest | reauthenticateRequest | updateProfileRequest | batchUsersRequest |
+	changeUsernameRequest](r *http.Request) (*T, error) {
 	defer r.Body.Close()
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -0,0 +1,125 @@
+package publicapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/url"
+	"pulse-auth/internal/authentication"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/export"
+	"pulse-auth/internal/utils"
+	"strconv"
+
+	"github.com/go-http-utils/headers"
+)
+
+type exportResponse struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	// DownloadURL is a short-lived signed link, present once the archive is ready.
+	DownloadURL       string `json:"download_url,omitempty"`
+	DownloadExpiresAt string `json:"download_expires_at,omitempty"`
+}
+
+// RequestExport queues an archive of everything stored about the authenticated user.
+func (h *Handler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*exportResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		exportModel, err := h.ExportService.RequestExport(ctx, &export.RequestExportParams{UserID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("request export: %w", err)
+		}
+
+		return exportModelToResponse(exportModel, nil), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("request export: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// GetExport reports the progress of an export and hands out a download link once it is ready.
+func (h *Handler) GetExport(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*exportResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		exportModel, link, err := h.ExportService.GetExport(ctx, &export.GetExportParams{
+			UserID:   userID,
+			ExportID: model.ExportID(chi.URLParamFromCtx(ctx, "exportID")),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get export: %w", err)
+		}
+
+		return exportModelToResponse(exportModel, link), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("get export: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// DownloadExport serves the archive to whoever holds a valid signed link, so it works from a plain browser download.
+func (h *Handler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	exportID := chi.URLParamFromCtx(ctx, "exportID")
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		h.writeError(ctx, w, utils.WrapValidationError(fmt.Errorf("download export: parse expires: %w", err)))
+		return
+	}
+
+	archive, err := h.ExportService.Download(ctx, &export.DownloadParams{
+		ExportID:  model.ExportID(exportID),
+		Expires:   expires,
+		Signature: r.URL.Query().Get("signature"),
+	})
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("download export: %w", err))
+		return
+	}
+
+	w.Header().Set(headers.ContentType, "application/zip")
+	w.Header().Set(headers.ContentDisposition, fmt.Sprintf("attachment; filename=%q", "pulse-export-"+exportID+".zip"))
+	w.Header().Set(headers.CacheControl, "no-store")
+	_, err = w.Write(archive)
+	if err != nil {
+		h.Logger.Sugar().Warnf("download export: write archive: %v", err)
+	}
+}
+
+func exportModelToResponse(exportModel *model.Export, link *export.DownloadLink) *exportResponse {
+	response := &exportResponse{
+		ID:        exportModel.ExportID.String(),
+		Status:    string(exportModel.Status),
+		Error:     exportModel.Error,
+		CreatedAt: exportModel.CreatedAt.String(),
+	}
+	if link != nil {
+		query := url.Values{}
+		query.Set("expires", strconv.FormatInt(link.Expires.Unix(), 10))
+		query.Set("signature", link.Signature)
+		response.DownloadURL = "/exports/" + url.PathEscape(link.ExportID.String()) + "/download?" + query.Encode()
+		response.DownloadExpiresAt = link.Expires.String()
+	}
+
+	return response
+}
@@ -0,0 +1,85 @@
+package publicapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"pulse-auth/internal/service/federation"
+	"time"
+)
+
+const flowStateCookieName = "pulse_oidc_flow"
+
+// LoginWithProvider redirects the browser to the login page of the external identity provider.
+func (h *Handler) LoginWithProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	provider := chi.URLParamFromCtx(ctx, "provider")
+
+	result, err := h.FederationService.BeginLogin(ctx, &federation.BeginLoginParams{Provider: provider})
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("login with provider: %w", err))
+		return
+	}
+
+	setFlowStateCookie(w, provider, result.FlowState, result.ExpiresAt)
+	http.Redirect(w, r, result.AuthURL, http.StatusFound)
+}
+
+// ProviderCallback completes the login after the identity provider redirects back.
+func (h *Handler) ProviderCallback(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*jwtTokenResponse, error) {
+		ctx := r.Context()
+		provider := chi.URLParamFromCtx(ctx, "provider")
+
+		var flowState string
+		if cookie, err := r.Cookie(flowStateCookieName); err == nil {
+			flowState = cookie.Value
+		}
+
+		query := r.URL.Query()
+		tokenModel, err := h.FederationService.CompleteLogin(ctx, &federation.CompleteLoginParams{
+			Provider:  provider,
+			FlowState: flowState,
+			State:     query.Get("state"),
+			Code:      query.Get("code"),
+			Error:     query.Get("error"),
+			Device:    h.deviceFromRequest(r),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("complete login: %w", err)
+		}
+		h.Cookies.Set(w, tokenModel)
+
+		return &jwtTokenResponse{
+			Token:  tokenModel.Token,
+			UserID: tokenModel.UserID.String(),
+		}, nil
+	}
+
+	setFlowStateCookie(w, chi.URLParamFromCtx(r.Context(), "provider"), "", time.Unix(0, 0))
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("provider callback: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// setFlowStateCookie keeps the flow state for the callback of the provider only. An empty value clears it.
+func setFlowStateCookie(w http.ResponseWriter, provider, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     flowStateCookieName,
+		Value:    value,
+		Path:     "/login/" + provider,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(w, cookie)
+}
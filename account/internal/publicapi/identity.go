@@ -0,0 +1,187 @@
+package publicapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"pulse-auth/internal/authentication"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/identity"
+	"pulse-auth/internal/utils"
+)
+
+const (
+	identityTypePassword = "password"
+	identityTypeExternal = "external"
+)
+
+type identityResponse struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Provider  string `json:"provider,omitempty"`
+	Email     string `json:"email,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+func (h *Handler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() ([]*identityResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		identities, err := h.IdentityService.ListIdentities(ctx, &identity.ListIdentitiesParams{UserID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("list identities: %w", err)
+		}
+
+		response := make([]*identityResponse, 0, len(identities.External)+1)
+		if identities.HasPassword {
+			response = append(response, &identityResponse{
+				ID:   identity.PasswordIdentityID.String(),
+				Type: identityTypePassword,
+			})
+		}
+		for _, external := range identities.External {
+			response = append(response, &identityResponse{
+				ID:        external.IdentityID.String(),
+				Type:      identityTypeExternal,
+				Provider:  external.Provider,
+				Email:     external.Email,
+				CreatedAt: external.CreatedAt.String(),
+			})
+		}
+
+		return response, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("list identities: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+type linkIdentityRequest struct {
+	Type     string `json:"type"`
+	Password string `json:"password"`
+	Provider string `json:"provider"`
+}
+
+// LinkIdentity links a password right away, while an external identity returns the provider login page to visit.
+func (h *Handler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*redirectResponse, error) {
+		ctx := r.Context()
+		token, err := authentication.TokenFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("token from context: %w", err)
+		}
+
+		request, err := parseJSONRequest[linkIdentityRequest](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse json request: %w", err)
+		}
+
+		switch request.Type {
+		case identityTypePassword:
+			err = h.IdentityService.LinkPassword(ctx, &identity.LinkPasswordParams{
+				Token:    token,
+				Password: request.Password,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("link password: %w", err)
+			}
+
+			return nil, nil
+		case identityTypeExternal:
+			result, err := h.IdentityService.LinkProvider(ctx, &identity.LinkProviderParams{
+				Token:    token,
+				Provider: request.Provider,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("link provider: %w", err)
+			}
+
+			setFlowStateCookie(w, request.Provider, result.FlowState, result.ExpiresAt)
+			return &redirectResponse{RedirectURI: result.AuthURL}, nil
+		default:
+			return nil, utils.WrapValidationError(fmt.Errorf("unexpected identity type: %s", request.Type))
+		}
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("link identity: %w", err))
+		return
+	}
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeResponse(w, response)
+}
+
+func (h *Handler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() error {
+		ctx := r.Context()
+		token, err := authentication.TokenFromContext(ctx)
+		if err != nil {
+			return fmt.Errorf("token from context: %w", err)
+		}
+
+		err = h.IdentityService.UnlinkIdentity(ctx, &identity.UnlinkIdentityParams{
+			Token:      token,
+			IdentityID: model.IdentityID(chi.URLParamFromCtx(ctx, "identityID")),
+		})
+		if err != nil {
+			return fmt.Errorf("unlink identity: %w", err)
+		}
+
+		return nil
+	}
+
+	err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("unlink identity: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type reauthenticateRequest struct {
+	Password string `json:"password"`
+}
+
+func (h *Handler) Reauthenticate(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() error {
+		ctx := r.Context()
+		token, err := authentication.TokenFromContext(ctx)
+		if err != nil {
+			return fmt.Errorf("token from context: %w", err)
+		}
+
+		request, err := parseJSONRequest[reauthenticateRequest](r)
+		if err != nil {
+			return fmt.Errorf("parse json request: %w", err)
+		}
+
+		err = h.IdentityService.Reauthenticate(ctx, &identity.ReauthenticateParams{
+			Token:    token,
+			Password: request.Password,
+		})
+		if err != nil {
+			return fmt.Errorf("reauthenticate: %w", err)
+		}
+
+		return nil
+	}
+
+	err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("reauthenticate: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
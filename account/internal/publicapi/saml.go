@@ -0,0 +1,105 @@
+package publicapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"pulse-auth/internal/service/federation"
+	"pulse-auth/internal/utils"
+	"time"
+)
+
+const samlFlowStateCookieName = "pulse_saml_flow"
+
+// SAMLMetadata serves the service provider metadata for the identity provider.
+func (h *Handler) SAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	metadata, err := h.FederationService.SAMLMetadata(ctx, &federation.SAMLMetadataParams{
+		Provider: chi.URLParamFromCtx(ctx, "idp"),
+	})
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("saml metadata: %w", err))
+		return
+	}
+
+	w.Header().Set(headers.ContentType, "application/samlmetadata+xml")
+	_, _ = w.Write(metadata)
+}
+
+// LoginWithSAML redirects the browser to the identity provider with a new authentication request.
+func (h *Handler) LoginWithSAML(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	provider := chi.URLParamFromCtx(ctx, "idp")
+
+	result, err := h.FederationService.BeginSAMLLogin(ctx, &federation.BeginSAMLLoginParams{Provider: provider})
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("login with saml: %w", err))
+		return
+	}
+
+	setSAMLFlowStateCookie(w, provider, result.FlowState, result.ExpiresAt)
+	http.Redirect(w, r, result.AuthURL, http.StatusFound)
+}
+
+// SAMLAssertionConsumer accepts the response the identity provider posts back and issues our token.
+func (h *Handler) SAMLAssertionConsumer(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*jwtTokenResponse, error) {
+		ctx := r.Context()
+
+		if err := r.ParseForm(); err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("parse form: %w", err))
+		}
+
+		var flowState string
+		if cookie, err := r.Cookie(samlFlowStateCookieName); err == nil {
+			flowState = cookie.Value
+		}
+
+		tokenModel, err := h.FederationService.CompleteSAMLLogin(ctx, &federation.CompleteSAMLLoginParams{
+			Provider:     chi.URLParamFromCtx(ctx, "idp"),
+			FlowState:    flowState,
+			RelayState:   r.PostForm.Get("RelayState"),
+			SAMLResponse: r.PostForm.Get("SAMLResponse"),
+			Device:       h.deviceFromRequest(r),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("complete saml login: %w", err)
+		}
+		h.Cookies.Set(w, tokenModel)
+
+		return &jwtTokenResponse{
+			Token:  tokenModel.Token,
+			UserID: tokenModel.UserID.String(),
+		}, nil
+	}
+
+	setSAMLFlowStateCookie(w, chi.URLParamFromCtx(r.Context(), "idp"), "", time.Unix(0, 0))
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("saml assertion consumer: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// setSAMLFlowStateCookie differs from the OIDC one in SameSite: the response arrives as a cross-site POST,
+// which Lax cookies are not sent with.
+func setSAMLFlowStateCookie(w http.ResponseWriter, provider, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     samlFlowStateCookieName,
+		Value:    value,
+		Path:     "/saml/" + provider,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(w, cookie)
+}
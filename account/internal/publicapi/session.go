@@ -0,0 +1,221 @@
+package publicapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net"
+	"net/http"
+	"net/netip"
+	"pulse-auth/internal/authentication"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/session"
+	"pulse-auth/internal/utils"
+	"strings"
+)
+
+const (
+	clientNameHeader   = "X-Client-Name"
+	forwardedForHeader = "X-Forwarded-For"
+	exceptCurrent      = "current"
+
+	maxUserAgentLength  = 512
+	maxClientNameLength = 128
+)
+
+type sessionResponse struct {
+	ID         string `json:"id"`
+	Current    bool   `json:"current"`
+	ClientID   string `json:"client_id,omitempty"`
+	ClientName string `json:"client_name,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	IPAddress  string `json:"ip_address,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+// ListSessions returns the active sessions of the user and marks the one the request was made with.
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() ([]*sessionResponse, error) {
+		ctx := r.Context()
+		token, err := authentication.TokenFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("token from context: %w", err)
+		}
+
+		sessions, err := h.SessionService.ListSessions(ctx, &session.ListSessionsParams{UserID: token.UserID})
+		if err != nil {
+			return nil, fmt.Errorf("list sessions: %w", err)
+		}
+
+		response := make([]*sessionResponse, 0, len(sessions))
+		for _, s := range sessions {
+			response = append(response, &sessionResponse{
+				ID:         s.TokenID.String(),
+				Current:    s.TokenID == token.TokenID,
+				ClientID:   s.ClientID.String(),
+				ClientName: s.Device.ClientName,
+				UserAgent:  s.Device.UserAgent,
+				IPAddress:  s.Device.IPAddress,
+				CreatedAt:  s.CreatedAt.String(),
+				LastUsedAt: s.LastUsedAt.String(),
+				ExpiresAt:  s.ExpiresAt.String(),
+			})
+		}
+
+		return response, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("list sessions: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// RevokeSession signs a single session of the user out.
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := authentication.UserIDFromContext(ctx)
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("revoke session: user id from context: %w", err))
+		return
+	}
+
+	err = h.SessionService.RevokeSession(ctx, &session.RevokeSessionParams{
+		UserID:  userID,
+		TokenID: model.TokenID(chi.URLParamFromCtx(ctx, "sessionID")),
+	})
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("revoke session: %w", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSessions signs the user out everywhere, or everywhere else with ?except=current.
+func (h *Handler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token, err := authentication.TokenFromContext(ctx)
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("revoke sessions: token from context: %w", err))
+		return
+	}
+
+	params := &session.RevokeSessionsParams{UserID: token.UserID}
+	switch except := r.URL.Query().Get("except"); except {
+	case "":
+	case exceptCurrent:
+		params.ExceptTokenID = token.TokenID
+	default:
+		h.writeError(ctx, w, utils.WrapValidationError(fmt.Errorf("revoke sessions: unsupported except: %s", except)))
+		return
+	}
+
+	if err = h.SessionService.RevokeSessions(ctx, params); err != nil {
+		h.writeError(ctx, w, fmt.Errorf("revoke sessions: %w", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Logout revokes the current session and clears the session cookies.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token, err := authentication.TokenFromContext(ctx)
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("logout: token from context: %w", err))
+		return
+	}
+
+	err = h.SessionService.RevokeSession(ctx, &session.RevokeSessionParams{
+		UserID:  token.UserID,
+		TokenID: token.TokenID,
+	})
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("logout: %w", err))
+		return
+	}
+
+	h.Cookies.Clear(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deviceFromRequest describes the device signing in. Clients may name themselves with the X-Client-Name header.
+func (h *Handler) deviceFromRequest(r *http.Request) model.Device {
+	return model.Device{
+		UserAgent:  truncate(r.UserAgent(), maxUserAgentLength),
+		IPAddress:  h.clientIP(r),
+		ClientName: truncate(r.Header.Get(clientNameHeader), maxClientNameLength),
+	}
+}
+
+// clientIP returns the address the request came from. Behind trusted proxies it is the last address in
+// X-Forwarded-For that isn't a trusted proxy itself. Anything left of it could have been made up by the client.
+func (h *Handler) clientIP(r *http.Request) string {
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		address = r.RemoteAddr
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values(forwardedForHeader), ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && h.trustedProxy(address); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		address = hop
+	}
+
+	return address
+}
+
+func (h *Handler) trustedProxy(address string) bool {
+	ip, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+
+	for _, proxy := range h.TrustedProxies {
+		if proxy.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseTrustedProxies parses the addresses and CIDR ranges of the trusted proxies.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	proxies := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+
+	return string(runes[:length])
+}
@@ -0,0 +1,50 @@
+package publicapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+	handler := &Handler{TrustedProxies: proxies}
+
+	_, err = ParseTrustedProxies([]string{"proxy"})
+	assert.Error(t, err)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		expected     string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:5000", expected: "203.0.113.7"},
+		{name: "untrusted peer", remoteAddr: "203.0.113.7:5000", forwardedFor: []string{"198.51.100.1"}, expected: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:5000", forwardedFor: []string{"198.51.100.1"}, expected: "198.51.100.1"},
+		{
+			name:         "spoofed by the client",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"1.1.1.1, 198.51.100.1", "192.168.1.1"},
+			expected:     "198.51.100.1",
+		},
+		{name: "garbage", remoteAddr: "10.0.0.2:5000", forwardedFor: []string{"unknown"}, expected: "10.0.0.2"},
+		{name: "only proxies", remoteAddr: "10.0.0.2:5000", forwardedFor: []string{"10.0.0.3"}, expected: "10.0.0.3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/login", nil)
+			request.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwardedFor {
+				request.Header.Add(forwardedForHeader, value)
+			}
+
+			assert.Equal(t, test.expected, handler.clientIP(request))
+		})
+	}
+}
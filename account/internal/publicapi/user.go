@@ -1,12 +1,15 @@
 package publicapi
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"net/http"
+	"pulse-auth/internal/authentication"
 	"pulse-auth/internal/model"
 	"pulse-auth/internal/service/user"
 	"pulse-auth/internal/utils"
+	"strconv"
 	"time"
 )
 
@@ -15,15 +18,27 @@ type jwtTokenResponse struct {
 	UserID string `json:"user_id"`
 }
 
+// userResponse leaves out the fields the viewer isn't allowed to see.
 type userResponse struct {
 	UserID     string `json:"user_id"`
 	Username   string `json:"username"`
 	FirstName  string `json:"first_name"`
 	SecondName string `json:"second_name"`
-	Sex        string `json:"sex"`
-	Birthdate  string `json:"birthdate"`
-	Biography  string `json:"biography"`
-	City       string `json:"city"`
+	Sex        string `json:"sex,omitempty"`
+	Birthdate  string `json:"birthdate,omitempty"`
+	Biography  string `json:"biography,omitempty"`
+	City       string `json:"city,omitempty"`
+	// Visibility and Searchable are only shown to the owner of the profile.
+	Visibility *visibilitySettings `json:"visibility,omitempty"`
+	Searchable *bool               `json:"searchable,omitempty"`
+}
+
+// visibilitySettings is who may see each profile field: public, authenticated or private.
+type visibilitySettings struct {
+	Sex       *model.Visibility `json:"sex"`
+	Birthdate *model.Visibility `json:"birthdate"`
+	Biography *model.Visibility `json:"biography"`
+	City      *model.Visibility `json:"city"`
 }
 
 type loginRequest struct {
@@ -43,10 +58,12 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		tokenModel, err := h.UserService.Login(ctx, &user.LoginParams{
 			Username: request.Username,
 			Password: request.Password,
+			Device:   h.deviceFromRequest(r),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("login: %w", err)
 		}
+		h.Cookies.Set(w, tokenModel)
 
 		return &jwtTokenResponse{
 			Token:  tokenModel.Token,
@@ -84,12 +101,20 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		}
 
 		tokenModel, err := h.UserService.Register(ctx, &user.RegisterParams{
-			Username: request.Username,
-			Password: request.Password,
+			Username:   request.Username,
+			Password:   request.Password,
+			FirstName:  request.FirstName,
+			SecondName: request.SecondName,
+			Sex:        request.Sex,
+			Birthdate:  request.Birthdate,
+			Biography:  request.Biography,
+			City:       request.City,
+			Device:     h.deviceFromRequest(r),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("register: %w", err)
 		}
+		h.Cookies.Set(w, tokenModel)
 
 		return &jwtTokenResponse{
 			Token:  tokenModel.Token,
@@ -117,7 +142,7 @@ func (h *Handler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 			return nil, fmt.Errorf("get user by id: %w", err)
 		}
 
-		return userModelToResponse(userModel), nil
+		return userModelToResponse(userModel, viewerOf(ctx, userModel.UserID)), nil
 	}
 
 	response, err := handleRequest()
@@ -128,6 +153,57 @@ func (h *Handler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, response)
 }
 
+type batchUsersRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+type batchUsersResponse struct {
+	Users   map[string]*userResponse `json:"users"`
+	Missing []string                 `json:"missing"`
+}
+
+// GetUsersByIDs resolves many users at once for services that would otherwise call GetUserByID in a loop.
+func (h *Handler) GetUsersByIDs(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*batchUsersResponse, error) {
+		ctx := r.Context()
+
+		request, err := parseJSONRequest[batchUsersRequest](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse json request: %w", err)
+		}
+
+		userIDs := make([]model.UserID, 0, len(request.UserIDs))
+		for _, userID := range request.UserIDs {
+			userIDs = append(userIDs, model.UserID(userID))
+		}
+
+		batch, err := h.UserService.GetUsersByIDs(ctx, &user.GetUsersByIDsParams{UserIDs: userIDs})
+		if err != nil {
+			return nil, fmt.Errorf("get users by ids: %w", err)
+		}
+
+		response := &batchUsersResponse{
+			Users:   make(map[string]*userResponse, len(batch.Users)),
+			Missing: make([]string, 0, len(batch.Missing)),
+		}
+		for userID, userModel := range batch.Users {
+			response.Users[userID.String()] = userModelToResponse(userModel, viewerOf(ctx, userID))
+		}
+		for _, userID := range batch.Missing {
+			response.Missing = append(response.Missing, userID.String())
+		}
+
+		return response, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("get users by ids: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
 func (h *Handler) SearchUser(w http.ResponseWriter, r *http.Request) {
 	handleRequest := func() (*userResponse, error) {
 		ctx := r.Context()
@@ -148,7 +224,7 @@ func (h *Handler) SearchUser(w http.ResponseWriter, r *http.Request) {
 			return nil, fmt.Errorf("search user: %w", err)
 		}
 
-		return userModelToResponse(userModel), nil
+		return userModelToResponse(userModel, viewerOf(ctx, userModel.UserID)), nil
 	}
 
 	response, err := handleRequest()
@@ -159,15 +235,330 @@ func (h *Handler) SearchUser(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, response)
 }
 
-func userModelToResponse(user *model.User) *userResponse {
-	return &userResponse{
+type userPageResponse struct {
+	Users      []*userResponse `json:"users"`
+	Total      uint64          `json:"total"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// SearchUsers returns a page of users filtered by name prefixes, city, sex and age range, or searched fuzzily with q.
+// The next page is requested with the returned next_cursor.
+func (h *Handler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userPageResponse, error) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		params := &user.SearchUsersParams{
+			Query:      query.Get("q"),
+			FirstName:  query.Get("first_name"),
+			SecondName: query.Get("second_name"),
+			City:       query.Get("city"),
+			Sex:        query.Get("sex"),
+			Cursor:     query.Get("cursor"),
+		}
+		var err error
+		if params.MinAge, err = optionalInt(query.Get("min_age")); err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("parse min_age: %w", err))
+		}
+		if params.MaxAge, err = optionalInt(query.Get("max_age")); err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("parse max_age: %w", err))
+		}
+		if limit := query.Get("limit"); limit != "" {
+			if params.Limit, err = strconv.ParseUint(limit, 10, 64); err != nil {
+				return nil, utils.WrapValidationError(fmt.Errorf("parse limit: %w", err))
+			}
+		}
+
+		page, err := h.UserService.SearchUsers(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("search users: %w", err)
+		}
+
+		response := &userPageResponse{
+			Users:      make([]*userResponse, 0, len(page.Users)),
+			Total:      page.Total,
+			NextCursor: page.NextCursor,
+		}
+		for _, userModel := range page.Users {
+			response.Users = append(response.Users, userModelToResponse(userModel, viewerOf(ctx, userModel.UserID)))
+		}
+
+		return response, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("search users: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+func optionalInt(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
+
+// GetMe returns the profile of the authenticated user.
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		userModel, err := h.UserService.GetUserByID(ctx, &user.GetUserByIDParams{UserID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("get user by id: %w", err)
+		}
+
+		return userModelToResponse(userModel, viewerOf(ctx, userModel.UserID)), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("get me: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// updateProfileRequest is a partial update: omitted or null fields are left unchanged.
+type updateProfileRequest struct {
+	FirstName  *string    `json:"first_name"`
+	SecondName *string    `json:"second_name"`
+	Sex        *string    `json:"sex"`
+	Birthdate  *time.Time `json:"birthdate"`
+	Biography  *string    `json:"biography"`
+	City       *string    `json:"city"`
+	// Visibility changes who may see each field, omitted fields keep their visibility.
+	Visibility *visibilitySettings `json:"visibility"`
+	Searchable *bool               `json:"searchable"`
+}
+
+// UpdateMe changes the profile of the authenticated user.
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		request, err := parseJSONRequest[updateProfileRequest](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse json request: %w", err)
+		}
+
+		params := &user.UpdateProfileParams{
+			UserID:     userID,
+			FirstName:  request.FirstName,
+			SecondName: request.SecondName,
+			Sex:        request.Sex,
+			Birthdate:  request.Birthdate,
+			Biography:  request.Biography,
+			City:       request.City,
+			Searchable: request.Searchable,
+		}
+		if request.Visibility != nil {
+			params.SexVisibility = request.Visibility.Sex
+			params.BirthdateVisibility = request.Visibility.Birthdate
+			params.BiographyVisibility = request.Visibility.Biography
+			params.CityVisibility = request.Visibility.City
+		}
+
+		userModel, err := h.UserService.UpdateProfile(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("update profile: %w", err)
+		}
+
+		return userModelToResponse(userModel, model.ViewerSelf), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("update me: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+type changeUsernameRequest struct {
+	Username string `json:"username"`
+}
+
+// ChangeUsername renames the authenticated user.
+func (h *Handler) ChangeUsername(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResponse, error) {
+		ctx := r.Context()
+		userID, err := authentication.UserIDFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("user id from context: %w", err)
+		}
+
+		request, err := parseJSONRequest[changeUsernameRequest](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse json request: %w", err)
+		}
+
+		userModel, err := h.UserService.ChangeUsername(ctx, &user.ChangeUsernameParams{
+			UserID:   userID,
+			Username: request.Username,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("change username: %w", err)
+		}
+
+		return userModelToResponse(userModel, model.ViewerSelf), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("change username: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// GetUserByUsername finds the user by the current username or one they used before.
+func (h *Handler) GetUserByUsername(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResponse, error) {
+		ctx := r.Context()
+		username := chi.URLParamFromCtx(ctx, "username")
+
+		userModel, err := h.UserService.GetUserByUsername(ctx, &user.GetUserByUsernameParams{Username: username})
+		if err != nil {
+			return nil, fmt.Errorf("get user by username: %w", err)
+		}
+
+		return userModelToResponse(userModel, viewerOf(ctx, userModel.UserID)), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("get user by username: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+type deleteAccountResponse struct {
+	RestoreUntil string `json:"restore_until"`
+}
+
+// DeleteMe deletes the account of the authenticated user. It requires a recent reauthentication.
+func (h *Handler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*deleteAccountResponse, error) {
+		ctx := r.Context()
+		token, err := authentication.TokenFromContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("token from context: %w", err)
+		}
+
+		deleted, err := h.UserService.DeleteAccount(ctx, &user.DeleteAccountParams{Token: token})
+		if err != nil {
+			return nil, fmt.Errorf("delete account: %w", err)
+		}
+		h.Cookies.Clear(w)
+
+		return &deleteAccountResponse{
+			RestoreUntil: deleted.RestoreUntil.String(),
+		}, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("delete me: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// Restore undeletes an account within the deletion grace period and signs the user in.
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*jwtTokenResponse, error) {
+		ctx := r.Context()
+
+		request, err := parseJSONRequest[loginRequest](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse json request: %w", err)
+		}
+
+		tokenModel, err := h.UserService.RestoreAccount(ctx, &user.RestoreAccountParams{
+			Username: request.Username,
+			Password: request.Password,
+			Device:   h.deviceFromRequest(r),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("restore account: %w", err)
+		}
+		h.Cookies.Set(w, tokenModel)
+
+		return &jwtTokenResponse{
+			Token:  tokenModel.Token,
+			UserID: tokenModel.UserID.String(),
+		}, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("restore: %w", err))
+		return
+	}
+	writeResponse(w, response)
+}
+
+// viewerOf tells how the authenticated user of the request, if any, relates to the owner of the profile.
+func viewerOf(ctx context.Context, owner model.UserID) model.Viewer {
+	userID, err := authentication.UserIDFromContext(ctx)
+	switch {
+	case err != nil:
+		return model.ViewerAnonymous
+	case userID == owner:
+		return model.ViewerSelf
+	default:
+		return model.ViewerAuthenticated
+	}
+}
+
+func userModelToResponse(user *model.User, viewer model.Viewer) *userResponse {
+	response := &userResponse{
 		UserID:     user.UserID.String(),
 		Username:   user.Username,
 		FirstName:  user.FirstName,
 		SecondName: user.SecondName,
-		Sex:        user.Sex,
-		Birthdate:  user.Birthdate.String(),
-		Biography:  user.Biography,
-		City:       user.City,
 	}
+	if user.Visibility.Sex.VisibleTo(viewer) {
+		response.Sex = user.Sex
+	}
+	if user.Visibility.Birthdate.VisibleTo(viewer) {
+		response.Birthdate = user.Birthdate.String()
+	}
+	if user.Visibility.Biography.VisibleTo(viewer) {
+		response.Biography = user.Biography
+	}
+	if user.Visibility.City.VisibleTo(viewer) {
+		response.City = user.City
+	}
+	if viewer == model.ViewerSelf {
+		visibility := user.Visibility
+		response.Visibility = &visibilitySettings{
+			Sex:       &visibility.Sex,
+			Birthdate: &visibility.Birthdate,
+			Biography: &visibility.Biography,
+			City:      &visibility.City,
+		}
+		response.Searchable = &user.Searchable
+	}
+
+	return response
 }
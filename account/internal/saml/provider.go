@@ -0,0 +1,258 @@
+package saml
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	crewjam "github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+)
+
+const httpTimeout = 10 * time.Second
+
+type Attributes struct {
+	Username   string
+	Email      string
+	FirstName  string
+	SecondName string
+	City       string
+}
+
+// DefaultAttributes are the LDAP style names most identity providers release.
+var DefaultAttributes = Attributes{
+	Username:   "uid",
+	Email:      "mail",
+	FirstName:  "givenName",
+	SecondName: "sn",
+	City:       "l",
+}
+
+type Config struct {
+	Name              string
+	RootURL           string
+	MetadataURL       string
+	MetadataPath      string
+	Key               *rsa.PrivateKey
+	Certificate       *x509.Certificate
+	AllowIDPInitiated bool
+	Attributes        Attributes
+}
+
+// Profile is the subject of a validated assertion mapped onto the fields of a local account.
+type Profile struct {
+	Subject    string
+	Username   string
+	Email      string
+	FirstName  string
+	SecondName string
+	City       string
+}
+
+// Provider is the service provider side of a single SAML 2.0 identity provider.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mutex       sync.Mutex
+	sp          *crewjam.ServiceProvider
+	idpMetadata *crewjam.EntityDescriptor
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	root, err := url.Parse(strings.TrimSuffix(config.RootURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse root url: %w", err)
+	}
+
+	config.Attributes = config.Attributes.withDefaults()
+
+	metadataURL := root.JoinPath("saml", config.Name, "metadata")
+	acsURL := root.JoinPath("saml", config.Name, "acs")
+
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: httpTimeout},
+		sp: &crewjam.ServiceProvider{
+			EntityID:          metadataURL.String(),
+			Key:               config.Key,
+			Certificate:       config.Certificate,
+			MetadataURL:       *metadataURL,
+			AcsURL:            *acsURL,
+			AuthnNameIDFormat: crewjam.PersistentNameIDFormat,
+			AllowIDPInitiated: config.AllowIDPInitiated,
+		},
+	}, nil
+}
+
+// Metadata returns the service provider metadata to register at the identity provider.
+func (p *Provider) Metadata() ([]byte, error) {
+	metadata, err := xml.MarshalIndent(p.sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+
+	return append([]byte(xml.Header), metadata...), nil
+}
+
+// AuthnRequestURL returns the identity provider login URL and the request ID the response must answer.
+func (p *Provider) AuthnRequestURL(ctx context.Context, relayState string) (string, string, error) {
+	sp, err := p.serviceProvider(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("service provider: %w", err)
+	}
+
+	request, err := sp.MakeAuthenticationRequest(
+		sp.GetSSOBindingLocation(crewjam.HTTPRedirectBinding), crewjam.HTTPRedirectBinding, crewjam.HTTPPostBinding,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("make authentication request: %w", err)
+	}
+
+	redirectURL, err := request.Redirect(url.QueryEscape(relayState), sp)
+	if err != nil {
+		return "", "", fmt.Errorf("redirect: %w", err)
+	}
+
+	return redirectURL.String(), request.ID, nil
+}
+
+// ParseResponse validates the signed response posted to the assertion consumer service.
+func (p *Provider) ParseResponse(ctx context.Context, encodedResponse string, requestIDs []string) (*Profile, error) {
+	sp, err := p.serviceProvider(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service provider: %w", err)
+	}
+
+	response, err := base64.StdEncoding.DecodeString(encodedResponse)
+	if err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	assertion, err := sp.ParseXMLResponse(response, requestIDs)
+	if err != nil {
+		// The public error is deliberately vague, the reason is kept in the private one.
+		if invalid, ok := err.(*crewjam.InvalidResponseError); ok {
+			return nil, fmt.Errorf("invalid response: %w", invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, fmt.Errorf("assertion has no subject")
+	}
+
+	return p.mapAssertion(assertion), nil
+}
+
+func (p *Provider) mapAssertion(assertion *crewjam.Assertion) *Profile {
+	values := make(map[string]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if len(attribute.Values) == 0 {
+				continue
+			}
+			values[attribute.Name] = attribute.Values[0].Value
+			if attribute.FriendlyName != "" {
+				values[attribute.FriendlyName] = attribute.Values[0].Value
+			}
+		}
+	}
+
+	attributes := p.config.Attributes
+	return &Profile{
+		Subject:    assertion.Subject.NameID.Value,
+		Username:   values[attributes.Username],
+		Email:      values[attributes.Email],
+		FirstName:  values[attributes.FirstName],
+		SecondName: values[attributes.SecondName],
+		City:       values[attributes.City],
+	}
+}
+
+// LoadKeyPair reads the PEM encoded certificate and RSA key the service provider signs requests with.
+func LoadKeyPair(certificatePath, keyPath string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(certificatePath, keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load key pair: %w", err)
+	}
+
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("key is not an RSA key")
+	}
+
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse certificate: %w", err)
+	}
+
+	return key, certificate, nil
+}
+
+func (a Attributes) withDefaults() Attributes {
+	if a.Username == "" {
+		a.Username = DefaultAttributes.Username
+	}
+	if a.Email == "" {
+		a.Email = DefaultAttributes.Email
+	}
+	if a.FirstName == "" {
+		a.FirstName = DefaultAttributes.FirstName
+	}
+	if a.SecondName == "" {
+		a.SecondName = DefaultAttributes.SecondName
+	}
+	if a.City == "" {
+		a.City = DefaultAttributes.City
+	}
+
+	return a
+}
+
+// serviceProvider loads the identity provider metadata on first use so startup doesn't depend on the provider.
+func (p *Provider) serviceProvider(ctx context.Context) (*crewjam.ServiceProvider, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.idpMetadata != nil {
+		return p.sp, nil
+	}
+
+	metadata, err := p.loadMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load identity provider metadata: %w", err)
+	}
+
+	p.idpMetadata = metadata
+	p.sp.IDPMetadata = metadata
+
+	return p.sp, nil
+}
+
+func (p *Provider) loadMetadata(ctx context.Context) (*crewjam.EntityDescriptor, error) {
+	if p.config.MetadataPath != "" {
+		data, err := os.ReadFile(p.config.MetadataPath)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		return samlsp.ParseMetadata(data)
+	}
+
+	metadataURL, err := url.Parse(p.config.MetadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse metadata url: %w", err)
+	}
+
+	return samlsp.FetchMetadata(ctx, p.client, *metadataURL)
+}
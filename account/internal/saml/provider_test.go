@@ -0,0 +1,143 @@
+package saml
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	crewjam "github.com/crewjam/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubServiceProviders struct {
+	provider *Provider
+}
+
+func (s stubServiceProviders) GetServiceProvider(*http.Request, string) (*crewjam.EntityDescriptor, error) {
+	return s.provider.sp.Metadata(), nil
+}
+
+func newIdentityProvider(t *testing.T) *crewjam.IdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &crewjam.IdentityProvider{
+		Key:         key,
+		Certificate: certificate,
+		MetadataURL: url.URL{Scheme: "https", Host: "idp.example.com", Path: "/metadata"},
+		SSOURL:      url.URL{Scheme: "https", Host: "idp.example.com", Path: "/sso"},
+	}
+}
+
+func newProvider(t *testing.T, idp *crewjam.IdentityProvider) *Provider {
+	metadata, err := xml.Marshal(idp.Metadata())
+	require.NoError(t, err)
+
+	metadataPath := filepath.Join(t.TempDir(), "idp.xml")
+	require.NoError(t, os.WriteFile(metadataPath, metadata, 0o600))
+
+	provider, err := NewProvider(Config{
+		Name:         "corp",
+		RootURL:      "https://pulse.example.com/",
+		MetadataPath: metadataPath,
+		Attributes:   Attributes{City: "locality"},
+	})
+	require.NoError(t, err)
+
+	idp.ServiceProviderProvider = stubServiceProviders{provider: provider}
+	return provider
+}
+
+// respond plays the identity provider side of the redirect binding.
+func respond(t *testing.T, idp *crewjam.IdentityProvider, authURL string) string {
+	request, err := crewjam.NewIdpAuthnRequest(idp, httptest.NewRequest(http.MethodGet, authURL, nil))
+	require.NoError(t, err)
+	require.NoError(t, request.Validate())
+
+	err = crewjam.DefaultAssertionMaker{}.MakeAssertion(request, &crewjam.Session{
+		ID:            "session",
+		CreateTime:    time.Now(),
+		ExpireTime:    time.Now().Add(time.Hour),
+		NameID:        "employee-42",
+		UserName:      "nikita",
+		UserGivenName: "Nikita",
+		UserSurname:   "Ivanov",
+		CustomAttributes: []crewjam.Attribute{
+			{Name: "mail", Values: []crewjam.AttributeValue{{Value: "nikita@example.com"}}},
+			{Name: "locality", Values: []crewjam.AttributeValue{{Value: "Moscow"}}},
+		},
+	})
+	require.NoError(t, err)
+
+	form, err := request.PostBinding()
+	require.NoError(t, err)
+	return form.SAMLResponse
+}
+
+func TestProvider(t *testing.T) {
+	ctx := context.Background()
+	idp := newIdentityProvider(t)
+	provider := newProvider(t, idp)
+
+	metadata, err := provider.Metadata()
+	require.NoError(t, err)
+	assert.Contains(t, string(metadata), "https://pulse.example.com/saml/corp/acs")
+
+	authURL, requestID, err := provider.AuthnRequestURL(ctx, "relay")
+	require.NoError(t, err)
+	assert.NotEmpty(t, requestID)
+
+	response := respond(t, idp, authURL)
+
+	_, err = provider.ParseResponse(ctx, response, []string{"another-request"})
+	assert.Error(t, err)
+
+	profile, err := provider.ParseResponse(ctx, response, []string{requestID})
+	require.NoError(t, err)
+	assert.Equal(t, &Profile{
+		Subject:    "employee-42",
+		Username:   "nikita",
+		Email:      "nikita@example.com",
+		FirstName:  "Nikita",
+		SecondName: "Ivanov",
+		City:       "Moscow",
+	}, profile)
+}
+
+func TestProviderRejectsForeignSignature(t *testing.T) {
+	ctx := context.Background()
+	provider := newProvider(t, newIdentityProvider(t))
+
+	// The impostor signs with its own key but answers the request of our provider.
+	impostor := newIdentityProvider(t)
+	impostor.ServiceProviderProvider = stubServiceProviders{provider: provider}
+
+	authURL, requestID, err := provider.AuthnRequestURL(ctx, "relay")
+	require.NoError(t, err)
+
+	_, err = provider.ParseResponse(ctx, respond(t, impostor, authURL), []string{requestID})
+	assert.Error(t, err)
+}
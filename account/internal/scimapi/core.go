@@ -0,0 +1,151 @@
+package scimapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"pulse-auth/internal/service/provisioning"
+	"pulse-auth/internal/utils"
+	"strconv"
+	"strings"
+
+	"github.com/go-http-utils/headers"
+	"go.uber.org/zap"
+)
+
+const (
+	contentType = "application/scim+json"
+
+	schemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	schemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+type Handler struct {
+	Logger              *zap.Logger
+	ProvisioningService provisioning.Service
+	// BaseURL is the absolute URL of the SCIM root used in resource locations.
+	BaseURL string
+	// TokenHashes maps the hex SHA-256 of each client bearer token to the client name.
+	TokenHashes map[string]string
+	MaxResults  uint64
+}
+
+type clientContextKey struct{}
+
+// AuthenticationInterceptor admits provisioning clients presenting one of the configured bearer tokens.
+func (h *Handler) AuthenticationInterceptor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		token, ok := strings.CutPrefix(r.Header.Get(headers.Authorization), "Bearer ")
+		if !ok || token == "" {
+			h.writeError(ctx, w, utils.WrapUnauthorizedError(fmt.Errorf("missing bearer token"), utils.UnauthorizedMessage))
+			return
+		}
+
+		sum := sha256.Sum256([]byte(token))
+		presented := hex.EncodeToString(sum[:])
+
+		var client string
+		for hash, name := range h.TokenHashes {
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(strings.ToLower(hash))) == 1 {
+				client = name
+			}
+		}
+		if client == "" {
+			h.writeError(ctx, w, utils.WrapUnauthorizedError(fmt.Errorf("unknown bearer token"), utils.UnauthorizedMessage))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, clientContextKey{}, client)))
+	})
+}
+
+// ServiceProviderConfig describes the supported SCIM features to provisioning clients.
+func (h *Handler) ServiceProviderConfig(w http.ResponseWriter, _ *http.Request) {
+	writeResponse(w, http.StatusOK, map[string]any{
+		"schemas":        []string{schemaServiceProviderConfig},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": h.MaxResults},
+		"changePassword": map[string]any{"supported": true},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Static bearer token issued to the provisioning client",
+		}},
+	})
+}
+
+// Groups answers every request for the Groups resource. Pulse has no groups yet, only roles granted per user, so
+// clients are told the resource isn't implemented rather than getting an empty list they would try to fill.
+func (h *Handler) Groups(w http.ResponseWriter, r *http.Request) {
+	err := utils.WrapError(fmt.Errorf("groups are not supported"), "Groups are not supported", http.StatusNotImplemented)
+	h.writeError(r.Context(), w, err)
+}
+
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// writeError renders errors in the SCIM error format instead of the one of the public API.
+func (h *Handler) writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	h.Logger.Sugar().Warnf("scim response error: client %v: %v", ctx.Value(clientContextKey{}), err)
+
+	errorResult, ok := utils.FromError(err)
+	if !ok {
+		errorResult = utils.WrapInternalError(err)
+	}
+
+	response := errorResponse{
+		Schemas: []string{schemaError},
+		Status:  strconv.Itoa(errorResult.StatusCode),
+		Detail:  errorResult.Msg,
+	}
+	switch errorResult.StatusCode {
+	case http.StatusConflict:
+		response.ScimType = "uniqueness"
+	case http.StatusBadRequest:
+		response.ScimType = "invalidValue"
+	}
+
+	writeResponse(w, errorResult.StatusCode, response)
+}
+
+func writeResponse(w http.ResponseWriter, status int, response any) {
+	w.Header().Set(headers.ContentType, contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, utils.InternalErrorMessage, http.StatusInternalServerError)
+	}
+}
+
+func parseJSONRequest[T userResource | patchRequest](r *http.Request) (*T, error) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("read body: %w", err)
+		return nil, utils.WrapInternalError(err)
+	}
+
+	var request T
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		err = fmt.Errorf("unmarshal request body: %w", err)
+		return nil, utils.WrapValidationError(err)
+	}
+	return &request, nil
+}
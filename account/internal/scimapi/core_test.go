@@ -0,0 +1,54 @@
+package scimapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/provisioning"
+	"pulse-auth/internal/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// racingProvisioning fails every creation the way the unique index does when another user took the username
+// between the availability check and the insert.
+type racingProvisioning struct {
+	provisioning.Service
+}
+
+func (racingProvisioning) CreateUser(context.Context, *provisioning.CreateUserParams) (*model.User, error) {
+	err := utils.WrapError(errors.New("duplicate key value violates unique constraint"), utils.ConflictMessage, http.StatusConflict)
+	return nil, fmt.Errorf("create user: %w", err)
+}
+
+func TestCreateUserConflict(t *testing.T) {
+	handler := &Handler{Logger: zap.NewNop(), ProvisioningService: racingProvisioning{}}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/Users", strings.NewReader(`{"userName":"john"}`))
+	handler.CreateUser(recorder, request)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	var response errorResponse
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	assert.Equal(t, "uniqueness", response.ScimType)
+	assert.Equal(t, "409", response.Status)
+}
+
+func TestGroups(t *testing.T) {
+	handler := &Handler{Logger: zap.NewNop()}
+
+	recorder := httptest.NewRecorder()
+	handler.Groups(recorder, httptest.NewRequest(http.MethodGet, "/Groups", nil))
+
+	assert.Equal(t, http.StatusNotImplemented, recorder.Code)
+	assert.Equal(t, contentType, recorder.Header().Get("Content-Type"))
+}
@@ -0,0 +1,119 @@
+package scimapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"pulse-auth/internal/model"
+	"strings"
+	"unicode"
+)
+
+// filterAttributes maps the lowercased SCIM attribute paths that can be filtered on to user fields.
+var filterAttributes = map[string]model.UserField{
+	"id":                 model.UserFieldID,
+	"username":           model.UserFieldUsername,
+	"name.givenname":     model.UserFieldFirstName,
+	"name.familyname":    model.UserFieldSecondName,
+	"addresses.locality": model.UserFieldCity,
+	"urn:ietf:params:scim:schemas:core:2.0:user:username": model.UserFieldUsername,
+}
+
+var filterOperators = map[model.FilterOperator]bool{
+	model.OperatorEqual:      true,
+	model.OperatorNotEqual:   true,
+	model.OperatorContains:   true,
+	model.OperatorStartsWith: true,
+	model.OperatorEndsWith:   true,
+	model.OperatorPresent:    true,
+}
+
+// parseFilter supports the subset of RFC 7644 filters that maps onto plain conditions:
+// attribute comparisons joined with "and", e.g. `userName sw "ni" and name.familyName pr`.
+func parseFilter(filter string) ([]model.UserCondition, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []model.UserCondition
+	for len(tokens) > 0 {
+		if len(conditions) > 0 {
+			if !strings.EqualFold(tokens[0], "and") {
+				return nil, fmt.Errorf("unsupported logical operator: %s", tokens[0])
+			}
+			tokens = tokens[1:]
+		}
+		if len(tokens) < 2 {
+			return nil, fmt.Errorf("incomplete expression")
+		}
+
+		field, ok := filterAttributes[strings.ToLower(tokens[0])]
+		if !ok {
+			return nil, fmt.Errorf("unsupported attribute: %s", tokens[0])
+		}
+		operator := model.FilterOperator(strings.ToLower(tokens[1]))
+		if !filterOperators[operator] {
+			return nil, fmt.Errorf("unsupported operator: %s", tokens[1])
+		}
+
+		condition := model.UserCondition{Field: field, Operator: operator}
+		tokens = tokens[2:]
+		if operator != model.OperatorPresent {
+			if len(tokens) == 0 || !strings.HasPrefix(tokens[0], `"`) {
+				return nil, fmt.Errorf("expected a string value for %s", condition.Field)
+			}
+			if err = json.Unmarshal([]byte(tokens[0]), &condition.Value); err != nil {
+				return nil, fmt.Errorf("value: %w", err)
+			}
+			tokens = tokens[1:]
+		}
+
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, nil
+}
+
+// tokenizeFilter splits the filter on whitespace, keeping quoted strings with their quotes as one token.
+func tokenizeFilter(filter string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+		escaped bool
+	)
+	for _, r := range filter {
+		switch {
+		case quoted:
+			current.WriteRune(r)
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = false
+			}
+		case r == '"':
+			quoted = true
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		case strings.ContainsRune("()[]", r):
+			return nil, fmt.Errorf("grouping is not supported")
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens, nil
+}
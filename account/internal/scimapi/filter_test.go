@@ -0,0 +1,67 @@
+package scimapi
+
+import (
+	"pulse-auth/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		expected []model.UserCondition
+	}{
+		{
+			name:   "empty",
+			filter: "",
+		},
+		{
+			name:   "equal",
+			filter: `userName eq "nikita"`,
+			expected: []model.UserCondition{
+				{Field: model.UserFieldUsername, Operator: model.OperatorEqual, Value: "nikita"},
+			},
+		},
+		{
+			name:   "case insensitive attribute and operator with escaped quotes",
+			filter: `NAME.givenName SW "say \"hi\" to"`,
+			expected: []model.UserCondition{
+				{Field: model.UserFieldFirstName, Operator: model.OperatorStartsWith, Value: `say "hi" to`},
+			},
+		},
+		{
+			name:   "conjunction with present",
+			filter: `addresses.locality co "Mos" and name.familyName pr`,
+			expected: []model.UserCondition{
+				{Field: model.UserFieldCity, Operator: model.OperatorContains, Value: "Mos"},
+				{Field: model.UserFieldSecondName, Operator: model.OperatorPresent},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conditions, err := parseFilter(test.filter)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, conditions)
+		})
+	}
+}
+
+func TestParseFilterRejectsUnsupported(t *testing.T) {
+	for _, filter := range []string{
+		`userName eq "a" or userName eq "b"`,
+		`emails.value eq "a@example.com"`,
+		`userName gt "a"`,
+		`userName eq nikita`,
+		`userName eq "nikita`,
+		`(userName eq "nikita")`,
+		`userName`,
+	} {
+		_, err := parseFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
@@ -0,0 +1,129 @@
+package scimapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// addressLocalityPath matches the value filter identity systems use to address the city, e.g. addresses[type eq "work"].locality.
+var addressLocalityPath = regexp.MustCompile(`^addresses\[type eq "([^"]*)"\]\.locality$`)
+
+// apply runs the operations against the resource in order. Failing operations leave it partially patched,
+// so callers must discard it on error.
+func (p *patchRequest) apply(resource *userResource) error {
+	if !slices.Contains(p.Schemas, schemaPatchOp) {
+		return fmt.Errorf("missing schema %s", schemaPatchOp)
+	}
+
+	for _, operation := range p.Operations {
+		var err error
+		switch strings.ToLower(operation.Op) {
+		case "add", "replace":
+			err = replace(resource, strings.ToLower(operation.Path), operation.Value)
+		case "remove":
+			err = remove(resource, strings.ToLower(operation.Path))
+		default:
+			err = fmt.Errorf("unsupported operation")
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", operation.Op, operation.Path, err)
+		}
+	}
+
+	return nil
+}
+
+func replace(resource *userResource, path string, value json.RawMessage) error {
+	if match := addressLocalityPath.FindStringSubmatch(path); match != nil {
+		var locality string
+		if err := json.Unmarshal(value, &locality); err != nil {
+			return err
+		}
+		setAddressLocality(resource, match[1], locality)
+		return nil
+	}
+
+	var target any
+	switch path {
+	case "":
+		// Without a path the value holds attributes to merge into the resource.
+		target = resource
+	case "username":
+		target = &resource.UserName
+	case "password":
+		target = &resource.Password
+	case "active":
+		target = &resource.Active
+	case "addresses":
+		target = &resource.Addresses
+	case "name":
+		target = resource.name()
+	case "name.givenname":
+		target = &resource.name().GivenName
+	case "name.familyname":
+		target = &resource.name().FamilyName
+	default:
+		return fmt.Errorf("unsupported path")
+	}
+
+	return json.Unmarshal(value, target)
+}
+
+func remove(resource *userResource, path string) error {
+	if match := addressLocalityPath.FindStringSubmatch(path); match != nil {
+		setAddressLocality(resource, match[1], "")
+		return nil
+	}
+
+	switch path {
+	case "name":
+		resource.Name = nil
+	case "name.givenname":
+		resource.name().GivenName = ""
+	case "name.familyname":
+		resource.name().FamilyName = ""
+	case "addresses":
+		resource.Addresses = nil
+	default:
+		return fmt.Errorf("unsupported path")
+	}
+
+	return nil
+}
+
+func (u *userResource) name() *userName {
+	if u.Name == nil {
+		u.Name = &userName{}
+	}
+	return u.Name
+}
+
+// setAddressLocality updates the address of the type, adding it when missing.
+func setAddressLocality(resource *userResource, addressType, locality string) {
+	for i := range resource.Addresses {
+		if strings.EqualFold(resource.Addresses[i].Type, addressType) {
+			resource.Addresses[i].Locality = locality
+			return
+		}
+	}
+
+	resource.Addresses = append(resource.Addresses, address{
+		Type:     addressType,
+		Locality: locality,
+		Primary:  len(resource.Addresses) == 0,
+	})
+}
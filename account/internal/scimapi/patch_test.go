@@ -0,0 +1,51 @@
+package scimapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchApply(t *testing.T) {
+	active := true
+	resource := &userResource{
+		UserName:  "nikita",
+		Name:      &userName{GivenName: "Nikita", FamilyName: "Ivanov"},
+		Addresses: []address{{Type: addressTypeHome, Locality: "Moscow", Primary: true}},
+		Active:    &active,
+	}
+
+	var request patchRequest
+	err := json.Unmarshal([]byte(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "name.familyName", "value": "Petrov"},
+			{"op": "replace", "path": "addresses[type eq \"home\"].locality", "value": "Kazan"},
+			{"op": "replace", "value": {"userName": "n.petrov", "active": false}},
+			{"op": "remove", "path": "name.givenName"}
+		]
+	}`), &request)
+	require.NoError(t, err)
+
+	require.NoError(t, request.apply(resource))
+	assert.Equal(t, "n.petrov", resource.UserName)
+	assert.Equal(t, &userName{FamilyName: "Petrov"}, resource.Name)
+	assert.Equal(t, "Kazan", resource.city())
+	require.NotNil(t, resource.Active)
+	assert.False(t, *resource.Active)
+}
+
+func TestPatchApplyRejectsUnsupported(t *testing.T) {
+	for _, body := range []string{
+		`{"Operations": [{"op": "replace", "path": "userName", "value": "a"}]}`,
+		`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "move", "path": "userName"}]}`,
+		`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "emails", "value": []}]}`,
+		`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "remove", "path": "userName"}]}`,
+	} {
+		var request patchRequest
+		require.NoError(t, json.Unmarshal([]byte(body), &request))
+		assert.Error(t, request.apply(&userResource{UserName: "nikita"}), body)
+	}
+}
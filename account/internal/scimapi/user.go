@@ -0,0 +1,338 @@
+package scimapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/provisioning"
+	"pulse-auth/internal/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	resourceTypeUser = "User"
+	addressTypeHome  = "home"
+)
+
+type userResource struct {
+	Schemas  []string  `json:"schemas"`
+	ID       string    `json:"id,omitempty"`
+	UserName string    `json:"userName"`
+	Name     *userName `json:"name,omitempty"`
+	// Addresses carry the city, the only part of an address Pulse keeps.
+	Addresses []address `json:"addresses,omitempty"`
+	Active    *bool     `json:"active,omitempty"`
+	Password  string    `json:"password,omitempty"`
+	Meta      *meta     `json:"meta,omitempty"`
+}
+
+type userName struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	Formatted  string `json:"formatted,omitempty"`
+}
+
+type address struct {
+	Type     string `json:"type,omitempty"`
+	Locality string `json:"locality,omitempty"`
+	Primary  bool   `json:"primary,omitempty"`
+}
+
+type meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type listResponse struct {
+	Schemas      []string        `json:"schemas"`
+	TotalResults uint64          `json:"totalResults"`
+	StartIndex   uint64          `json:"startIndex"`
+	ItemsPerPage int             `json:"itemsPerPage"`
+	Resources    []*userResource `json:"Resources"`
+}
+
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*listResponse, error) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		conditions, err := parseFilter(query.Get("filter"))
+		if err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("parse filter: %w", err))
+		}
+
+		startIndex, count, err := h.parsePagination(query.Get("startIndex"), query.Get("count"))
+		if err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("parse pagination: %w", err))
+		}
+
+		page, err := h.ProvisioningService.ListUsers(ctx, &provisioning.ListUsersParams{
+			Conditions: conditions,
+			Offset:     startIndex - 1,
+			Limit:      count,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+
+		resources := make([]*userResource, 0, len(page.Users))
+		for _, user := range page.Users {
+			resources = append(resources, h.userModelToResource(user))
+		}
+
+		return &listResponse{
+			Schemas:      []string{schemaListResponse},
+			TotalResults: page.Total,
+			StartIndex:   startIndex,
+			ItemsPerPage: len(resources),
+			Resources:    resources,
+		}, nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("list users: %w", err))
+		return
+	}
+	writeResponse(w, http.StatusOK, response)
+}
+
+// parsePagination applies the SCIM defaults: indexes start at 1 and count is capped by MaxResults.
+func (h *Handler) parsePagination(startIndexParam, countParam string) (uint64, uint64, error) {
+	startIndex, count := uint64(1), h.MaxResults
+	if startIndexParam != "" {
+		value, err := strconv.ParseInt(startIndexParam, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("start index: %w", err)
+		}
+		if value > 1 {
+			startIndex = uint64(value)
+		}
+	}
+	if countParam != "" {
+		value, err := strconv.ParseInt(countParam, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("count: %w", err)
+		}
+		count = uint64(max(value, 0))
+	}
+
+	return startIndex, min(count, h.MaxResults), nil
+}
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResource, error) {
+		ctx := r.Context()
+
+		user, err := h.ProvisioningService.GetUser(ctx, &provisioning.GetUserParams{
+			UserID: model.UserID(chi.URLParamFromCtx(ctx, "userID")),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+
+		return h.userModelToResource(user), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("get user: %w", err))
+		return
+	}
+	writeResponse(w, http.StatusOK, response)
+}
+
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResource, error) {
+		ctx := r.Context()
+
+		request, err := parseJSONRequest[userResource](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse request: %w", err)
+		}
+		if err = request.validate(); err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("validate: %w", err))
+		}
+
+		user, err := h.ProvisioningService.CreateUser(ctx, &provisioning.CreateUserParams{
+			Username:   request.UserName,
+			Password:   request.Password,
+			FirstName:  request.givenName(),
+			SecondName: request.familyName(),
+			City:       request.city(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create user: %w", err)
+		}
+
+		return h.userModelToResource(user), nil
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("create user: %w", err))
+		return
+	}
+	writeResponse(w, http.StatusCreated, response)
+}
+
+// ReplaceUser handles PUT: attributes missing from the request are cleared.
+func (h *Handler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResource, error) {
+		ctx := r.Context()
+
+		request, err := parseJSONRequest[userResource](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse request: %w", err)
+		}
+		if err = request.validate(); err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("validate: %w", err))
+		}
+
+		return h.updateUser(r, model.UserID(chi.URLParamFromCtx(ctx, "userID")), request)
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("replace user: %w", err))
+		return
+	}
+	writeResponse(w, http.StatusOK, response)
+}
+
+func (h *Handler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	handleRequest := func() (*userResource, error) {
+		ctx := r.Context()
+		userID := model.UserID(chi.URLParamFromCtx(ctx, "userID"))
+
+		request, err := parseJSONRequest[patchRequest](r)
+		if err != nil {
+			return nil, fmt.Errorf("parse request: %w", err)
+		}
+
+		user, err := h.ProvisioningService.GetUser(ctx, &provisioning.GetUserParams{UserID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+
+		resource := h.userModelToResource(user)
+		if err = request.apply(resource); err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("apply patch: %w", err))
+		}
+		if err = resource.validate(); err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("validate: %w", err))
+		}
+
+		return h.updateUser(r, userID, resource)
+	}
+
+	response, err := handleRequest()
+	if err != nil {
+		h.writeError(r.Context(), w, fmt.Errorf("patch user: %w", err))
+		return
+	}
+	writeResponse(w, http.StatusOK, response)
+}
+
+func (h *Handler) updateUser(r *http.Request, userID model.UserID, resource *userResource) (*userResource, error) {
+	firstName, secondName, city := resource.givenName(), resource.familyName(), resource.city()
+	params := &provisioning.UpdateUserParams{
+		Update: model.UserUpdate{
+			ID:         userID,
+			Username:   &resource.UserName,
+			FirstName:  &firstName,
+			SecondName: &secondName,
+			City:       &city,
+		},
+		Active: resource.Active,
+	}
+	if resource.Password != "" {
+		params.Password = &resource.Password
+	}
+
+	user, err := h.ProvisioningService.UpdateUser(r.Context(), params)
+	if err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	return h.userModelToResource(user), nil
+}
+
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	err := h.ProvisioningService.DeleteUser(ctx, &provisioning.DeleteUserParams{
+		UserID: model.UserID(chi.URLParamFromCtx(ctx, "userID")),
+	})
+	if err != nil {
+		h.writeError(ctx, w, fmt.Errorf("delete user: %w", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) userModelToResource(user *model.User) *userResource {
+	// Deactivated users are deleted accounts the identity system can still reactivate.
+	active := user.DeletedAt.IsZero()
+	resource := &userResource{
+		Schemas:  []string{schemaUser},
+		ID:       user.UserID.String(),
+		UserName: user.Username,
+		Active:   &active,
+		Meta: &meta{
+			ResourceType: resourceTypeUser,
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     h.BaseURL + "/Users/" + user.UserID.String(),
+		},
+	}
+	if user.FirstName != "" || user.SecondName != "" {
+		resource.Name = &userName{GivenName: user.FirstName, FamilyName: user.SecondName}
+	}
+	if user.City != "" {
+		resource.Addresses = []address{{Type: addressTypeHome, Locality: user.City, Primary: true}}
+	}
+
+	return resource
+}
+
+func (u *userResource) validate() error {
+	if u.UserName == "" {
+		return fmt.Errorf("userName is required")
+	}
+
+	return nil
+}
+
+func (u *userResource) givenName() string {
+	if u.Name == nil {
+		return ""
+	}
+	return u.Name.GivenName
+}
+
+func (u *userResource) familyName() string {
+	if u.Name == nil {
+		return ""
+	}
+	return u.Name.FamilyName
+}
+
+// city takes the locality of the primary address, or of the first one when none is primary.
+func (u *userResource) city() string {
+	for _, address := range u.Addresses {
+		if address.Primary {
+			return address.Locality
+		}
+	}
+	if len(u.Addresses) > 0 {
+		return u.Addresses[0].Locality
+	}
+
+	return ""
+}
@@ -0,0 +1,90 @@
+package scimapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/provisioning"
+	"pulse-auth/internal/service/username"
+	"pulse-auth/internal/storage/memory"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDeactivateAndReactivateUser(t *testing.T) {
+	store := memory.NewStorage()
+	usernames, err := username.NewChecker(nil)
+	require.NoError(t, err)
+	handler := &Handler{
+		Logger: zap.NewNop(),
+		ProvisioningService: &provisioning.ServiceImpl{
+			Storage:             store,
+			Usernames:           usernames,
+			DeletionGracePeriod: time.Hour,
+			Logger:              zap.NewNop(),
+		},
+	}
+	router := chi.NewRouter()
+	router.Post("/Users", handler.CreateUser)
+	router.Get("/Users/{userID}", handler.GetUser)
+	router.Patch("/Users/{userID}", handler.PatchUser)
+	router.Delete("/Users/{userID}", handler.DeleteUser)
+
+	serve := func(method, path, body string) (int, *userResource) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var resource userResource
+		if recorder.Code < http.StatusMultipleChoices && recorder.Body.Len() > 0 {
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&resource))
+		}
+		return recorder.Code, &resource
+	}
+	patch := func(operation string) string {
+		return `{"schemas":["` + schemaPatchOp + `"],"Operations":[` + operation + `]}`
+	}
+	active := func(resource *userResource) bool {
+		require.NotNil(t, resource.Active)
+		return *resource.Active
+	}
+
+	status, created := serve(http.MethodPost, "/Users", `{"userName":"john"}`)
+	require.Equal(t, http.StatusCreated, status)
+	assert.True(t, active(created))
+	path := "/Users/" + created.ID
+
+	status, _ = serve(http.MethodDelete, path, "")
+	require.Equal(t, http.StatusNoContent, status)
+
+	// The deactivated user is still there for the identity system, reported inactive.
+	status, got := serve(http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, active(got))
+
+	// Updates that don't reactivate the user leave it deactivated.
+	status, got = serve(http.MethodPatch, path, patch(`{"op":"replace","path":"name.givenName","value":"John"}`))
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, active(got))
+
+	status, got = serve(http.MethodPatch, path, patch(`{"op":"replace","path":"active","value":true}`))
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, active(got))
+	_, err = store.User().GetUserByID(context.Background(), model.UserID(created.ID))
+	require.NoError(t, err)
+
+	status, got = serve(http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, active(got))
+
+	// An account the user deleted themselves isn't brought back by the identity system.
+	require.NoError(t, store.User().DeleteUser(context.Background(), model.UserID(created.ID), model.DeletedByUser))
+	status, _ = serve(http.MethodPatch, path, patch(`{"op":"replace","path":"active","value":true}`))
+	assert.Equal(t, http.StatusForbidden, status)
+}
@@ -0,0 +1,239 @@
+package consent
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/token"
+	"pulse-auth/internal/utils"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	errorAccessDenied = "access_denied"
+	tokenTypeBearer   = "Bearer"
+)
+
+type Service interface {
+	Authorize(ctx context.Context, params *AuthorizeParams) (*AuthorizeResult, error)
+	Decide(ctx context.Context, params *DecideParams) (*AuthorizeResult, error)
+	ListConsents(ctx context.Context, params *ListConsentsParams) ([]*ClientConsent, error)
+	RevokeConsent(ctx context.Context, params *RevokeConsentParams) error
+}
+
+type ServiceImpl struct {
+	Storage        storage.Storage
+	TokenGenerator *token.Generator
+	Clients        map[model.ClientID]*model.Client
+	Logger         *zap.Logger
+}
+
+type AuthorizeParams struct {
+	UserID      model.UserID
+	ClientID    model.ClientID
+	RedirectURI string
+	Scopes      []string
+	State       string
+	Device      model.Device
+}
+
+// AuthorizeResult either asks the user for consent or carries the URI to send the user back to the client.
+type AuthorizeResult struct {
+	ConsentRequired bool
+	Client          *model.Client
+	RequestedScopes []string
+	MissingScopes   []string
+	RedirectURI     string
+}
+
+// Authorize skips the consent screen when the user has already granted every requested scope to the client.
+func (s *ServiceImpl) Authorize(ctx context.Context, params *AuthorizeParams) (*AuthorizeResult, error) {
+	client, err := s.validateRequest(params)
+	if err != nil {
+		return nil, fmt.Errorf("validate request: %w", err)
+	}
+
+	consent, err := s.Storage.Consent().GetConsent(ctx, params.UserID, params.ClientID)
+	if err != nil && !utils.IsNotFoundError(err) {
+		return nil, fmt.Errorf("get consent: %w", err)
+	}
+
+	missingScopes := consent.MissingScopes(params.Scopes)
+	if len(missingScopes) > 0 {
+		return &AuthorizeResult{
+			ConsentRequired: true,
+			Client:          client,
+			RequestedScopes: params.Scopes,
+			MissingScopes:   missingScopes,
+		}, nil
+	}
+
+	return s.issueToken(ctx, client, params)
+}
+
+type DecideParams struct {
+	AuthorizeParams
+	Approved bool
+}
+
+// Decide records the answer given on the consent screen and completes the authorization.
+func (s *ServiceImpl) Decide(ctx context.Context, params *DecideParams) (*AuthorizeResult, error) {
+	client, err := s.validateRequest(&params.AuthorizeParams)
+	if err != nil {
+		return nil, fmt.Errorf("validate request: %w", err)
+	}
+
+	if !params.Approved {
+		return &AuthorizeResult{
+			Client:      client,
+			RedirectURI: redirectURI(params.RedirectURI, url.Values{"error": {errorAccessDenied}, "state": {params.State}}),
+		}, nil
+	}
+
+	consent, err := s.Storage.Consent().GetConsent(ctx, params.UserID, params.ClientID)
+	if err != nil && !utils.IsNotFoundError(err) {
+		return nil, fmt.Errorf("get consent: %w", err)
+	}
+
+	var scopes []string
+	if consent != nil {
+		scopes = consent.Scopes
+	}
+	scopes = append(slices.Clone(scopes), params.Scopes...)
+
+	_, err = s.Storage.Consent().GrantConsent(ctx, &model.Consent{
+		UserID:   params.UserID,
+		ClientID: params.ClientID,
+		Scopes:   model.ParseScopes(model.FormatScopes(scopes)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("grant consent: %w", err)
+	}
+
+	return s.issueToken(ctx, client, &params.AuthorizeParams)
+}
+
+type ListConsentsParams struct {
+	UserID model.UserID
+}
+
+// ClientConsent is a consent together with the client it was granted to, if the client is still registered.
+type ClientConsent struct {
+	Consent *model.Consent
+	Client  *model.Client
+}
+
+func (s *ServiceImpl) ListConsents(ctx context.Context, params *ListConsentsParams) ([]*ClientConsent, error) {
+	consents, err := s.Storage.Consent().ListConsents(ctx, params.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("list consents: %w", err)
+	}
+
+	result := make([]*ClientConsent, 0, len(consents))
+	for _, consent := range consents {
+		result = append(result, &ClientConsent{
+			Consent: consent,
+			Client:  s.Clients[consent.ClientID],
+		})
+	}
+
+	return result, nil
+}
+
+type RevokeConsentParams struct {
+	UserID   model.UserID
+	ClientID model.ClientID
+}
+
+// RevokeConsent withdraws the consent and revokes every token the client holds for the user.
+func (s *ServiceImpl) RevokeConsent(ctx context.Context, params *RevokeConsentParams) error {
+	// A consent withdrawn without its tokens would leave the client with access the user took back.
+	// Tokens issued without a stored consent, or left over from one revoked before, are revoked all the same.
+	return s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		err := tx.Consent().RevokeConsent(ctx, params.UserID, params.ClientID)
+		if err != nil && !utils.IsNotFoundError(err) {
+			return fmt.Errorf("revoke consent: %w", err)
+		}
+
+		err = tx.Token().RevokeClientTokens(ctx, params.UserID, params.ClientID)
+		if err != nil {
+			return fmt.Errorf("revoke client tokens: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (s *ServiceImpl) validateRequest(params *AuthorizeParams) (*model.Client, error) {
+	client, ok := s.Clients[params.ClientID]
+	if !ok {
+		return nil, utils.WrapValidationError(fmt.Errorf("unknown client: %s", params.ClientID))
+	}
+	if !client.AllowsRedirectURI(params.RedirectURI) {
+		return nil, utils.WrapValidationError(fmt.Errorf("redirect uri is not registered for client: %s", params.ClientID))
+	}
+	if len(params.Scopes) == 0 {
+		return nil, utils.WrapValidationError(fmt.Errorf("scope cannot be empty"))
+	}
+	if !client.AllowsScopes(params.Scopes) {
+		return nil, utils.WrapValidationError(fmt.Errorf("scope is not allowed for client: %s", params.ClientID))
+	}
+
+	return client, nil
+}
+
+// issueToken creates a token bound to the client and returns it to the redirect URI fragment.
+func (s *ServiceImpl) issueToken(ctx context.Context, client *model.Client, params *AuthorizeParams) (*AuthorizeResult, error) {
+	user, err := s.Storage.User().GetUserByID(ctx, params.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	generatedToken, err := s.TokenGenerator.GenerateToken(user)
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	// The token belongs to the client, whatever the browser that approved it calls itself.
+	device := params.Device
+	device.ClientName = client.Name
+
+	alivedAt := s.TokenGenerator.GetExpirationDate()
+	createdToken, err := s.Storage.Token().CreateToken(ctx, &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   params.UserID,
+		ClientID: client.ClientID,
+		Scopes:   params.Scopes,
+		Token:    generatedToken,
+		AlivedAt: alivedAt,
+		Device:   device,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	return &AuthorizeResult{
+		Client: client,
+		RedirectURI: redirectURI(params.RedirectURI, url.Values{
+			"access_token": {createdToken.Token},
+			"token_type":   {tokenTypeBearer},
+			"expires_in":   {strconv.Itoa(int(time.Until(alivedAt).Seconds()))},
+			"scope":        {model.FormatScopes(params.Scopes)},
+			"state":        {params.State},
+		}),
+	}, nil
+}
+
+// redirectURI appends the authorization response to the fragment as the implicit grant does.
+func redirectURI(base string, values url.Values) string {
+	if values.Get("state") == "" {
+		values.Del("state")
+	}
+
+	return base + "#" + values.Encode()
+}
@@ -0,0 +1,86 @@
+package consent
+
+import (
+	"context"
+	"net/url"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/token"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRevokeConsent(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	client := &model.Client{
+		ClientID:     "app",
+		Name:         "App",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"openid", "profile"},
+	}
+	service := &ServiceImpl{
+		Storage:        store,
+		TokenGenerator: token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		Clients:        map[model.ClientID]*model.Client{client.ClientID: client},
+		Logger:         zap.NewNop(),
+	}
+
+	user, err := store.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "consenting"})
+	require.NoError(t, err)
+	params := AuthorizeParams{
+		UserID:      user.UserID,
+		ClientID:    client.ClientID,
+		RedirectURI: client.RedirectURIs[0],
+		Scopes:      []string{"openid"},
+	}
+
+	result, err := service.Authorize(ctx, &params)
+	require.NoError(t, err)
+	assert.True(t, result.ConsentRequired)
+
+	result, err = service.Decide(ctx, &DecideParams{AuthorizeParams: params, Approved: true})
+	require.NoError(t, err)
+	granted := accessToken(t, result.RedirectURI)
+	issued, err := store.Token().GetToken(ctx, granted)
+	require.NoError(t, err)
+	// The token carries the granted scopes and never counts as a fresh sign-in of the user.
+	assert.Equal(t, []string{"openid"}, issued.Scopes)
+	assert.True(t, issued.IssuedToClient())
+	assert.True(t, issued.AuthenticatedAt.IsZero())
+	assert.False(t, issued.AuthenticatedWithin(time.Hour))
+
+	require.NoError(t, service.RevokeConsent(ctx, &RevokeConsentParams{UserID: user.UserID, ClientID: client.ClientID}))
+	_, err = store.Token().GetToken(ctx, granted)
+	assert.True(t, utils.IsNotFoundError(err), err)
+	consents, err := service.ListConsents(ctx, &ListConsentsParams{UserID: user.UserID})
+	require.NoError(t, err)
+	assert.Empty(t, consents)
+
+	// A client token without a stored consent is revoked too.
+	orphan, err := store.Token().CreateToken(ctx, &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   user.UserID,
+		ClientID: client.ClientID,
+		Token:    utils.GenerateUUID(),
+		AlivedAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, service.RevokeConsent(ctx, &RevokeConsentParams{UserID: user.UserID, ClientID: client.ClientID}))
+	_, err = store.Token().GetToken(ctx, orphan.Token)
+	assert.True(t, utils.IsNotFoundError(err), err)
+}
+
+func accessToken(t *testing.T, redirectURI string) string {
+	parsed, err := url.Parse(redirectURI)
+	require.NoError(t, err)
+	fragment, err := url.ParseQuery(parsed.Fragment)
+	require.NoError(t, err)
+	return fragment.Get("access_token")
+}
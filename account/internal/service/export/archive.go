@@ -0,0 +1,216 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"pulse-auth/internal/model"
+	"time"
+)
+
+type profileRecord struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	FirstName   string    `json:"first_name"`
+	SecondName  string    `json:"second_name"`
+	Sex         string    `json:"sex"`
+	Birthdate   time.Time `json:"birthdate"`
+	Biography   string    `json:"biography"`
+	City        string    `json:"city"`
+	HasPassword bool      `json:"has_password"`
+	Roles       []string  `json:"roles"`
+	Visibility  struct {
+		Sex       model.Visibility `json:"sex"`
+		Birthdate model.Visibility `json:"birthdate"`
+		Biography model.Visibility `json:"biography"`
+		City      model.Visibility `json:"city"`
+	} `json:"visibility"`
+	Searchable bool      `json:"searchable"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// sessionRecord leaves out the token itself: the archive may be stored insecurely and must not sign anybody in.
+type sessionRecord struct {
+	ID              string     `json:"id"`
+	ClientID        string     `json:"client_id,omitempty"`
+	ClientName      string     `json:"client_name,omitempty"`
+	UserAgent       string     `json:"user_agent,omitempty"`
+	IPAddress       string     `json:"ip_address,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	AuthenticatedAt time.Time  `json:"authenticated_at"`
+	LastUsedAt      time.Time  `json:"last_used_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+}
+
+type identityRecord struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type consentRecord struct {
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type usernameRecord struct {
+	Username  string    `json:"username"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// buildArchive collects everything stored about the user into a ZIP archive with a JSON file per kind of data.
+// Audit events are missing: they are only written to the logs, not stored per user, and have to be added here once
+// they are.
+func (s *ServiceImpl) buildArchive(ctx context.Context, userID model.UserID) ([]byte, error) {
+	user, err := s.Storage.User().GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+	roles, err := s.Storage.User().GetRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get roles: %w", err)
+	}
+	// Signed out and expired sessions are data about the user as well, as long as they are stored.
+	sessions, err := s.Storage.Token().ListSessionHistory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list session history: %w", err)
+	}
+	identities, err := s.Storage.Identity().ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list identities: %w", err)
+	}
+	consents, err := s.Storage.Consent().ListConsents(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list consents: %w", err)
+	}
+	usernames, err := s.Storage.User().ListUsernameHistory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list username history: %w", err)
+	}
+
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", profileToRecord(user, roles)},
+		{"sessions.json", sessionsToRecords(sessions)},
+		{"identities.json", identitiesToRecords(identities)},
+		{"consents.json", consentsToRecords(consents)},
+		{"usernames.json", usernamesToRecords(usernames)},
+	}
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, fmt.Errorf("create %s: %w", file.name, err)
+		}
+
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(file.content); err != nil {
+			return nil, fmt.Errorf("encode %s: %w", file.name, err)
+		}
+	}
+	if err = archive.Close(); err != nil {
+		return nil, fmt.Errorf("close archive: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func profileToRecord(user *model.User, roles []string) *profileRecord {
+	record := &profileRecord{
+		UserID:      user.UserID.String(),
+		Username:    user.Username,
+		FirstName:   user.FirstName,
+		SecondName:  user.SecondName,
+		Sex:         user.Sex,
+		Birthdate:   user.Birthdate,
+		Biography:   user.Biography,
+		City:        user.City,
+		HasPassword: user.HasPassword,
+		Roles:       roles,
+		Searchable:  user.Searchable,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+	record.Visibility.Sex = user.Visibility.Sex
+	record.Visibility.Birthdate = user.Visibility.Birthdate
+	record.Visibility.Biography = user.Visibility.Biography
+	record.Visibility.City = user.Visibility.City
+
+	return record
+}
+
+func sessionsToRecords(sessions []*model.Session) []*sessionRecord {
+	records := make([]*sessionRecord, 0, len(sessions))
+	for _, session := range sessions {
+		record := &sessionRecord{
+			ID:              session.TokenID.String(),
+			ClientID:        session.ClientID.String(),
+			ClientName:      session.Device.ClientName,
+			UserAgent:       session.Device.UserAgent,
+			IPAddress:       session.Device.IPAddress,
+			CreatedAt:       session.CreatedAt,
+			AuthenticatedAt: session.AuthenticatedAt,
+			LastUsedAt:      session.LastUsedAt,
+			ExpiresAt:       session.ExpiresAt,
+		}
+		if !session.RevokedAt.IsZero() {
+			record.RevokedAt = &session.RevokedAt
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func identitiesToRecords(identities []*model.Identity) []*identityRecord {
+	records := make([]*identityRecord, 0, len(identities))
+	for _, identity := range identities {
+		records = append(records, &identityRecord{
+			ID:        identity.IdentityID.String(),
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	return records
+}
+
+func consentsToRecords(consents []*model.Consent) []*consentRecord {
+	records := make([]*consentRecord, 0, len(consents))
+	for _, consent := range consents {
+		records = append(records, &consentRecord{
+			ClientID:  consent.ClientID.String(),
+			Scopes:    consent.Scopes,
+			CreatedAt: consent.CreatedAt,
+			UpdatedAt: consent.UpdatedAt,
+		})
+	}
+
+	return records
+}
+
+func usernamesToRecords(history []*model.UsernameRecord) []*usernameRecord {
+	records := make([]*usernameRecord, 0, len(history))
+	for _, record := range history {
+		records = append(records, &usernameRecord{
+			Username:  record.Username,
+			ChangedAt: record.ChangedAt,
+		})
+	}
+
+	return records
+}
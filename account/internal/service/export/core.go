@@ -0,0 +1,161 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"go.uber.org/zap"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"strconv"
+	"time"
+)
+
+// failureReason is shown to the user instead of the internal error that failed the export.
+const failureReason = "the archive could not be built, request a new export"
+
+type Service interface {
+	RequestExport(ctx context.Context, params *RequestExportParams) (*model.Export, error)
+	GetExport(ctx context.Context, params *GetExportParams) (*model.Export, *DownloadLink, error)
+	Download(ctx context.Context, params *DownloadParams) ([]byte, error)
+	ProcessExports(ctx context.Context) (int, error)
+	DeleteExpiredExports(ctx context.Context) (int64, error)
+}
+
+type ServiceImpl struct {
+	Storage storage.Storage
+	// Key signs download links, so a link can't be forged for another export or extended.
+	Key          []byte
+	LinkLifetime time.Duration
+	// Retention is how long a built archive is kept before it is deleted.
+	Retention time.Duration
+	// StaleTimeout is after how long a running export is considered abandoned and built again.
+	StaleTimeout time.Duration
+	Logger       *zap.Logger
+}
+
+// DownloadLink grants access to the archive of a ready export until Expires.
+type DownloadLink struct {
+	ExportID  model.ExportID
+	Expires   time.Time
+	Signature string
+}
+
+type RequestExportParams struct {
+	UserID model.UserID
+}
+
+// RequestExport queues an export of the user's data. The archive is built in the background.
+func (s *ServiceImpl) RequestExport(ctx context.Context, params *RequestExportParams) (*model.Export, error) {
+	export, err := s.Storage.Export().CreateExport(ctx, &model.Export{
+		ExportID: model.ExportID(utils.GenerateUUID()),
+		UserID:   params.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create export: %w", err)
+	}
+
+	return export, nil
+}
+
+type GetExportParams struct {
+	UserID   model.UserID
+	ExportID model.ExportID
+}
+
+// GetExport returns the export of the user and, once the archive is ready, a fresh download link.
+func (s *ServiceImpl) GetExport(ctx context.Context, params *GetExportParams) (*model.Export, *DownloadLink, error) {
+	export, err := s.Storage.Export().GetExport(ctx, params.ExportID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get export: %w", err)
+	}
+	if export.UserID != params.UserID {
+		return nil, nil, utils.WrapNotFoundError(fmt.Errorf("export of another user"), utils.NotFoundMessage)
+	}
+	if export.Status != model.ExportStatusReady {
+		return export, nil, nil
+	}
+
+	expires := time.Now().Add(s.LinkLifetime).Truncate(time.Second)
+	if expires.After(export.ExpiresAt) {
+		expires = export.ExpiresAt.Truncate(time.Second)
+	}
+
+	return export, &DownloadLink{
+		ExportID:  export.ExportID,
+		Expires:   expires,
+		Signature: s.sign(export.ExportID, expires),
+	}, nil
+}
+
+type DownloadParams struct {
+	ExportID  model.ExportID
+	Expires   int64
+	Signature string
+}
+
+// Download checks the signed link and returns the ZIP archive of the export.
+func (s *ServiceImpl) Download(ctx context.Context, params *DownloadParams) ([]byte, error) {
+	expires := time.Unix(params.Expires, 0)
+	if !hmac.Equal([]byte(params.Signature), []byte(s.sign(params.ExportID, expires))) {
+		return nil, utils.WrapForbiddenError(fmt.Errorf("invalid signature"), utils.InvalidLinkMessage)
+	}
+	if time.Now().After(expires) {
+		return nil, utils.WrapForbiddenError(fmt.Errorf("link expired at %s", expires), utils.InvalidLinkMessage)
+	}
+
+	archive, err := s.Storage.Export().GetExportArchive(ctx, params.ExportID)
+	if err != nil {
+		return nil, fmt.Errorf("get export archive: %w", err)
+	}
+
+	return archive, nil
+}
+
+// ProcessExports builds archives for queued exports until none are left and returns how many were handled.
+func (s *ServiceImpl) ProcessExports(ctx context.Context) (int, error) {
+	processed := 0
+	for ctx.Err() == nil {
+		export, err := s.Storage.Export().ClaimExport(ctx, time.Now().Add(-s.StaleTimeout))
+		if err != nil {
+			if utils.IsNotFoundError(err) {
+				break
+			}
+			return processed, fmt.Errorf("claim export: %w", err)
+		}
+
+		expiresAt := time.Now().Add(s.Retention)
+		archive, err := s.buildArchive(ctx, export.UserID)
+		if err != nil {
+			s.Logger.Sugar().Errorf("build archive of export %s: %v", export.ExportID, err)
+			err = s.Storage.Export().FailExport(ctx, export.ExportID, failureReason, expiresAt)
+		} else {
+			err = s.Storage.Export().CompleteExport(ctx, export.ExportID, archive, expiresAt)
+		}
+		if err != nil {
+			return processed, fmt.Errorf("finish export %s: %w", export.ExportID, err)
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// DeleteExpiredExports removes archives whose retention has passed.
+func (s *ServiceImpl) DeleteExpiredExports(ctx context.Context) (int64, error) {
+	deleted, err := s.Storage.Export().DeleteExpiredExports(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired exports: %w", err)
+	}
+
+	return deleted, nil
+}
+
+func (s *ServiceImpl) sign(id model.ExportID, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(id.String() + "." + strconv.FormatInt(expires.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
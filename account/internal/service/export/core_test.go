@@ -0,0 +1,162 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{
+		Storage:      store,
+		Key:          []byte("key"),
+		LinkLifetime: time.Minute,
+		Retention:    time.Hour,
+		StaleTimeout: time.Minute,
+		Logger:       zap.NewNop(),
+	}
+
+	user, err := store.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "nikita"})
+	require.NoError(t, err)
+	active := createToken(t, store, user.UserID, time.Hour)
+	signedOut := createToken(t, store, user.UserID, time.Hour)
+	require.NoError(t, store.Token().RevokeToken(ctx, signedOut))
+	createToken(t, store, user.UserID, -time.Hour)
+
+	requested, err := service.RequestExport(ctx, &RequestExportParams{UserID: user.UserID})
+	require.NoError(t, err)
+
+	export, link, err := service.GetExport(ctx, &GetExportParams{UserID: user.UserID, ExportID: requested.ExportID})
+	require.NoError(t, err)
+	assert.NotEqual(t, model.ExportStatusReady, export.Status)
+	assert.Nil(t, link)
+
+	processed, err := service.ProcessExports(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed)
+
+	_, _, err = service.GetExport(ctx, &GetExportParams{UserID: "someone-else", ExportID: requested.ExportID})
+	assertStatus(t, http.StatusNotFound, err)
+
+	export, link, err = service.GetExport(ctx, &GetExportParams{UserID: user.UserID, ExportID: requested.ExportID})
+	require.NoError(t, err)
+	assert.Equal(t, model.ExportStatusReady, export.Status)
+	require.NotNil(t, link)
+
+	archive, err := service.Download(ctx, &DownloadParams{
+		ExportID:  link.ExportID,
+		Expires:   link.Expires.Unix(),
+		Signature: link.Signature,
+	})
+	require.NoError(t, err)
+
+	// Every session still stored is exported, the signed out and the expired ones too.
+	var sessions []sessionRecord
+	readFile(t, archive, "sessions.json", &sessions)
+	require.Len(t, sessions, 3)
+	revoked := map[string]bool{}
+	for _, session := range sessions {
+		revoked[session.ID] = session.RevokedAt != nil
+	}
+	assert.False(t, revoked[active.TokenID.String()])
+	assert.True(t, revoked[signedOut.TokenID.String()])
+
+	var profile profileRecord
+	readFile(t, archive, "profile.json", &profile)
+	assert.Equal(t, "nikita", profile.Username)
+}
+
+func TestDownloadRejectsInvalidLinks(t *testing.T) {
+	ctx := context.Background()
+	service := &ServiceImpl{Storage: memory.NewStorage(), Key: []byte("key"), Logger: zap.NewNop()}
+	exportID := model.ExportID(utils.GenerateUUID())
+	expires := time.Now().Add(time.Minute).Truncate(time.Second)
+	signature := service.sign(exportID, expires)
+
+	tests := []struct {
+		name   string
+		params *DownloadParams
+	}{
+		{
+			name:   "tampered signature",
+			params: &DownloadParams{ExportID: exportID, Expires: expires.Unix(), Signature: signature + "x"},
+		},
+		{
+			name:   "extended expiry",
+			params: &DownloadParams{ExportID: exportID, Expires: expires.Add(time.Hour).Unix(), Signature: signature},
+		},
+		{
+			name:   "another export",
+			params: &DownloadParams{ExportID: model.ExportID(utils.GenerateUUID()), Expires: expires.Unix(), Signature: signature},
+		},
+		{
+			name: "expired",
+			params: &DownloadParams{
+				ExportID:  exportID,
+				Expires:   time.Now().Add(-time.Minute).Unix(),
+				Signature: service.sign(exportID, time.Unix(time.Now().Add(-time.Minute).Unix(), 0)),
+			},
+		},
+		{
+			name: "signed with another key",
+			params: &DownloadParams{
+				ExportID:  exportID,
+				Expires:   expires.Unix(),
+				Signature: (&ServiceImpl{Key: []byte("other")}).sign(exportID, expires),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := service.Download(ctx, test.params)
+			assertStatus(t, http.StatusForbidden, err)
+		})
+	}
+}
+
+func createToken(t *testing.T, store *memory.Storage, userID model.UserID, lifetime time.Duration) *model.Token {
+	t.Helper()
+
+	token, err := store.Token().CreateToken(context.Background(), &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   userID,
+		Token:    utils.GenerateUUID(),
+		AlivedAt: time.Now().Add(lifetime),
+	})
+	require.NoError(t, err)
+
+	return token
+}
+
+func readFile(t *testing.T, archive []byte, name string, content any) {
+	t.Helper()
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	file, err := reader.Open(name)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, json.NewDecoder(file).Decode(content))
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+
+	result, ok := utils.FromError(err)
+	require.True(t, ok, "error %v is not an ErrorResult", err)
+	assert.Equal(t, status, result.StatusCode)
+}
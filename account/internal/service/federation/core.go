@@ -0,0 +1,335 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/oidc"
+	"pulse-auth/internal/saml"
+	"pulse-auth/internal/service/username"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/token"
+	"pulse-auth/internal/utils"
+	"strings"
+	"time"
+)
+
+const flowStateLifetime = 10 * time.Minute
+
+type Service interface {
+	BeginLogin(ctx context.Context, params *BeginLoginParams) (*BeginLoginResult, error)
+	CompleteLogin(ctx context.Context, params *CompleteLoginParams) (*model.Token, error)
+	SAMLMetadata(ctx context.Context, params *SAMLMetadataParams) ([]byte, error)
+	BeginSAMLLogin(ctx context.Context, params *BeginSAMLLoginParams) (*BeginLoginResult, error)
+	CompleteSAMLLogin(ctx context.Context, params *CompleteSAMLLoginParams) (*model.Token, error)
+}
+
+type ServiceImpl struct {
+	Storage        storage.Storage
+	TokenGenerator *token.Generator
+	Providers      map[string]*oidc.Provider
+	SAMLProviders  map[string]*saml.Provider
+	StateKey       []byte
+	// Usernames allocates the usernames of accounts created on the first sign in.
+	Usernames username.Checker
+	// DeletionGracePeriod is how long signing in with a linked identity restores a deleted account.
+	DeletionGracePeriod time.Duration
+	Logger              *zap.Logger
+}
+
+type BeginLoginParams struct {
+	Provider   string
+	LinkUserID model.UserID
+}
+
+// BeginLoginResult carries the provider login page and the flow state the caller must keep until the callback.
+type BeginLoginResult struct {
+	AuthURL   string
+	FlowState string
+	ExpiresAt time.Time
+}
+
+func (s *ServiceImpl) BeginLogin(ctx context.Context, params *BeginLoginParams) (*BeginLoginResult, error) {
+	provider, err := s.provider(params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &flowState{
+		Provider:   params.Provider,
+		ExpiresAt:  time.Now().Add(flowStateLifetime),
+		LinkUserID: params.LinkUserID.String(),
+	}
+	for _, value := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		*value, err = randomString()
+		if err != nil {
+			return nil, utils.WrapInternalError(fmt.Errorf("random string: %w", err))
+		}
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("auth code url: %w", err))
+	}
+
+	sealed, err := seal(s.StateKey, state)
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("seal flow state: %w", err))
+	}
+
+	return &BeginLoginResult{
+		AuthURL:   authURL,
+		FlowState: sealed,
+		ExpiresAt: state.ExpiresAt,
+	}, nil
+}
+
+type CompleteLoginParams struct {
+	Provider  string
+	FlowState string
+	State     string
+	Code      string
+	Error     string
+	Device    model.Device
+}
+
+// CompleteLogin handles the provider callback and signs the user in, creating the account on first login.
+// When the flow was started for linking, the identity is attached to the user who started it.
+func (s *ServiceImpl) CompleteLogin(ctx context.Context, params *CompleteLoginParams) (*model.Token, error) {
+	provider, err := s.provider(params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Error != "" {
+		return nil, utils.WrapUnauthorizedError(fmt.Errorf("provider error: %s", params.Error), utils.UnauthorizedMessage)
+	}
+
+	state, err := open(s.StateKey, params.FlowState)
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("open flow state: %w", err))
+	}
+	if state.Provider != params.Provider || state.State != params.State {
+		return nil, utils.WrapValidationError(fmt.Errorf("state mismatch"))
+	}
+
+	claims, err := provider.Exchange(ctx, params.Code, state.Verifier, state.Nonce)
+	if err != nil {
+		return nil, utils.WrapUnauthorizedError(fmt.Errorf("exchange: %w", err), utils.UnauthorizedMessage)
+	}
+
+	return s.signIn(ctx, params.Provider, state, profileFromClaims(params.Provider, claims), params.Device)
+}
+
+// signIn links the external identity or signs the user in, and issues our own token either way.
+func (s *ServiceImpl) signIn(
+	ctx context.Context, provider string, state *flowState, profile *externalProfile, device model.Device,
+) (*model.Token, error) {
+	// The user may be created or linked just before it is read back.
+	ctx = storage.WithPrimary(ctx)
+	var (
+		user *model.User
+		err  error
+	)
+	if state != nil && state.LinkUserID != "" {
+		user, err = s.linkIdentity(ctx, model.UserID(state.LinkUserID), provider, profile)
+		if err != nil {
+			return nil, fmt.Errorf("link identity: %w", err)
+		}
+	} else {
+		user, err = s.findOrCreateUser(ctx, provider, profile)
+		if err != nil {
+			return nil, fmt.Errorf("find or create user: %w", err)
+		}
+	}
+
+	generatedToken, err := s.TokenGenerator.GenerateToken(user)
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	token, err := s.Storage.Token().CreateToken(ctx, &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   user.UserID,
+		Token:    generatedToken,
+		AlivedAt: s.TokenGenerator.GetExpirationDate(),
+		Device:   device,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	return token, nil
+}
+
+// externalProfile is what an identity provider told us about the user, whatever the protocol.
+type externalProfile struct {
+	Subject    string
+	Username   string
+	Email      string
+	FirstName  string
+	SecondName string
+	City       string
+}
+
+func profileFromClaims(provider string, claims *oidc.Claims) *externalProfile {
+	profile := &externalProfile{
+		Subject:    claims.Subject,
+		Username:   claims.PreferredUsername,
+		FirstName:  claims.GivenName,
+		SecondName: claims.FamilyName,
+		Email:      claims.Email,
+		City:       claims.Locality,
+	}
+	if profile.Username == "" && claims.EmailVerified {
+		profile.Username = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	profile.Username = candidateUsername(provider, profile)
+
+	return profile
+}
+
+// provisionAttempts bounds how often a just in time account is retried after a concurrent sign in took its username.
+const provisionAttempts = 3
+
+// linkedUser returns the user an identity belongs to. An account deleted within the grace period is restored, the
+// linked identity is how accounts without a password prove they are theirs.
+func (s *ServiceImpl) linkedUser(ctx context.Context, userID model.UserID) (*model.User, error) {
+	user, err := s.Storage.User().GetUserByID(ctx, userID)
+	if err == nil {
+		return user, nil
+	}
+	if !utils.IsNotFoundError(err) {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	user, err = s.Storage.User().RestoreUserByID(ctx, userID, time.Now().Add(-s.DeletionGracePeriod), model.DeletedByUser)
+	if err != nil {
+		return nil, fmt.Errorf("restore user by id: %w", err)
+	}
+	s.Logger.Info("restored deleted account on sign in", zap.String("user_id", userID.String()))
+
+	return user, nil
+}
+
+// findOrCreateUser returns the user linked to the external subject, provisioning a new account just in time.
+func (s *ServiceImpl) findOrCreateUser(ctx context.Context, provider string, profile *externalProfile) (*model.User, error) {
+	// A username taken moments ago must not be handed out again.
+	ctx = storage.WithPrimary(ctx)
+
+	var err error
+	for attempt := 0; attempt < provisionAttempts; attempt++ {
+		var user *model.User
+		user, err = s.provisionUser(ctx, provider, profile)
+		if err == nil {
+			return user, nil
+		}
+		// Either another account took the username or the same subject signed in twice at once, the next
+		// attempt picks another username or finds the identity.
+		if result, ok := utils.FromError(err); !ok || result.StatusCode != http.StatusConflict {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// provisionUser returns the user linked to the external subject or creates the user and the identity together.
+func (s *ServiceImpl) provisionUser(ctx context.Context, provider string, profile *externalProfile) (*model.User, error) {
+	identity, err := s.Storage.Identity().GetIdentity(ctx, provider, profile.Subject)
+	if err == nil {
+		return s.linkedUser(ctx, identity.UserID)
+	}
+	if !utils.IsNotFoundError(err) {
+		return nil, fmt.Errorf("get identity: %w", err)
+	}
+
+	var user *model.User
+	err = s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		name, err := s.Usernames.Allocate(ctx, tx.User(), profile.Username)
+		if err != nil {
+			return fmt.Errorf("allocate username: %w", err)
+		}
+
+		// Federated accounts have no password until the user links one.
+		user, err = tx.User().CreateUser(ctx, &model.UserRegister{
+			ID:         utils.GenerateUUID(),
+			Username:   name,
+			FirstName:  profile.FirstName,
+			SecondName: profile.SecondName,
+			City:       profile.City,
+		})
+		if err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+
+		_, err = tx.Identity().CreateIdentity(ctx, &model.Identity{
+			IdentityID: model.IdentityID(utils.GenerateUUID()),
+			UserID:     user.UserID,
+			Provider:   provider,
+			Subject:    profile.Subject,
+			Email:      profile.Email,
+		})
+		if err != nil {
+			return fmt.Errorf("create identity: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Logger.Sugar().Infof("provisioned user %s from identity provider %s", user.UserID, provider)
+
+	return user, nil
+}
+
+// linkIdentity attaches the external subject to the user unless it already belongs to another account.
+func (s *ServiceImpl) linkIdentity(ctx context.Context, userID model.UserID, provider string, profile *externalProfile) (*model.User, error) {
+	identity, err := s.Storage.Identity().GetIdentity(ctx, provider, profile.Subject)
+	switch {
+	case err == nil && identity.UserID != userID:
+		return nil, utils.WrapError(fmt.Errorf("identity is linked to another user"), utils.ConflictMessage, http.StatusConflict)
+	case err != nil && !utils.IsNotFoundError(err):
+		return nil, fmt.Errorf("get identity: %w", err)
+	case err != nil:
+		_, err = s.Storage.Identity().CreateIdentity(ctx, &model.Identity{
+			IdentityID: model.IdentityID(utils.GenerateUUID()),
+			UserID:     userID,
+			Provider:   provider,
+			Subject:    profile.Subject,
+			Email:      profile.Email,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create identity: %w", err)
+		}
+	}
+
+	user, err := s.Storage.User().GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *ServiceImpl) provider(name string) (*oidc.Provider, error) {
+	provider, ok := s.Providers[name]
+	if !ok {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("unknown identity provider: %s", name), utils.NotFoundMessage)
+	}
+
+	return provider, nil
+}
+
+// candidateUsername falls back to a provider scoped name when the provider gave us nothing readable.
+func candidateUsername(provider string, profile *externalProfile) string {
+	if profile.Username != "" {
+		return profile.Username
+	}
+
+	return provider + "_" + profile.Subject
+}
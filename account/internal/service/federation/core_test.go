@@ -0,0 +1,159 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/username"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/token"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFindOrCreateUser(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	usernames, err := username.NewChecker([]string{"admin"})
+	require.NoError(t, err)
+	service := &ServiceImpl{Storage: store, Usernames: usernames, Logger: zap.NewNop()}
+
+	_, err = store.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "john"})
+	require.NoError(t, err)
+
+	john, err := service.findOrCreateUser(ctx, "google", &externalProfile{Subject: "1", Username: "John"})
+	require.NoError(t, err)
+	assert.Equal(t, "John2", john.Username)
+	assert.False(t, john.HasPassword)
+
+	again, err := service.findOrCreateUser(ctx, "google", &externalProfile{Subject: "1", Username: "John"})
+	require.NoError(t, err)
+	assert.Equal(t, john.UserID, again.UserID)
+
+	admin, err := service.findOrCreateUser(ctx, "google", &externalProfile{Subject: "2", Username: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, "admin2", admin.Username)
+
+	invalid, err := service.findOrCreateUser(ctx, "google", &externalProfile{Subject: "3", Username: "nikit\u0430"})
+	require.NoError(t, err)
+	assert.Equal(t, "user", invalid.Username)
+
+	identity, err := store.Identity().GetIdentity(ctx, "google", "3")
+	require.NoError(t, err)
+	assert.Equal(t, invalid.UserID, identity.UserID)
+}
+
+// failingIdentities is a storage whose identity inserts fail.
+type failingIdentities struct {
+	storage.Storage
+}
+
+func (s failingIdentities) Identity() storage.IdentityRepository {
+	return failingIdentityRepository{s.Storage.Identity()}
+}
+
+func (s failingIdentities) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	return s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		return fn(failingIdentities{tx})
+	})
+}
+
+type failingIdentityRepository struct {
+	storage.IdentityRepository
+}
+
+func (failingIdentityRepository) CreateIdentity(context.Context, *model.Identity) (*model.Identity, error) {
+	return nil, utils.WrapInternalError(errors.New("create identity failed"))
+}
+
+func TestFindOrCreateUserRollsBackUser(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{Storage: failingIdentities{store}, Logger: zap.NewNop()}
+
+	_, err := service.findOrCreateUser(ctx, "google", &externalProfile{Subject: "1", Username: "john"})
+	require.Error(t, err)
+
+	_, err = store.User().GetUserByUsername(ctx, "john")
+	assert.True(t, utils.IsNotFoundError(err), err)
+}
+
+func TestSignInKeepsProtocolsApart(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{
+		Storage:        store,
+		TokenGenerator: token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		Logger:         zap.NewNop(),
+	}
+
+	// The same subject at an OpenID Connect and a SAML provider both called "corp" are different people.
+	profile := &externalProfile{Subject: "1", Username: "john"}
+	oidcToken, err := service.signIn(ctx, "corp", nil, profile, model.Device{})
+	require.NoError(t, err)
+	samlToken, err := service.signIn(ctx, samlIdentityProvider("corp"), nil, profile, model.Device{})
+	require.NoError(t, err)
+	assert.NotEqual(t, oidcToken.UserID, samlToken.UserID)
+
+	identity, err := store.Identity().GetIdentity(ctx, "saml:corp", "1")
+	require.NoError(t, err)
+	assert.Equal(t, samlToken.UserID, identity.UserID)
+}
+
+func TestSignInRestoresDeletedAccount(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{
+		Storage:             store,
+		TokenGenerator:      token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		DeletionGracePeriod: time.Hour,
+		Logger:              zap.NewNop(),
+	}
+
+	// Federated accounts have no password to restore them with, signing in with the provider does instead.
+	profile := &externalProfile{Subject: "1", Username: "john"}
+	created, err := service.signIn(ctx, "google", nil, profile, model.Device{})
+	require.NoError(t, err)
+	require.NoError(t, store.User().DeleteUser(ctx, created.UserID, model.DeletedByUser))
+
+	restored, err := service.signIn(ctx, "google", nil, profile, model.Device{})
+	require.NoError(t, err)
+	assert.Equal(t, created.UserID, restored.UserID)
+	_, err = store.User().GetUserByID(ctx, created.UserID)
+	assert.NoError(t, err)
+
+	// Once the grace period is over the account stays deleted.
+	require.NoError(t, store.User().DeleteUser(ctx, created.UserID, model.DeletedByUser))
+	service.DeletionGracePeriod = 0
+	_, err = service.signIn(ctx, "google", nil, profile, model.Device{})
+	assert.True(t, utils.IsNotFoundError(err), err)
+}
+
+func TestSignInRefusesDeprovisionedAccount(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{
+		Storage:             store,
+		TokenGenerator:      token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		DeletionGracePeriod: time.Hour,
+		Logger:              zap.NewNop(),
+	}
+
+	// Only the identity system that deprovisioned the account may bring it back.
+	profile := &externalProfile{Subject: "1", Username: "john"}
+	created, err := service.signIn(ctx, "google", nil, profile, model.Device{})
+	require.NoError(t, err)
+	require.NoError(t, store.User().DeleteUser(ctx, created.UserID, model.DeletedByProvisioning))
+
+	_, err = service.signIn(ctx, "google", nil, profile, model.Device{})
+	assert.True(t, utils.IsNotFoundError(err), err)
+	_, err = store.User().GetUserByID(ctx, created.UserID)
+	assert.True(t, utils.IsNotFoundError(err), err)
+}
@@ -0,0 +1,136 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/saml"
+	"pulse-auth/internal/utils"
+	"strings"
+	"time"
+)
+
+type SAMLMetadataParams struct {
+	Provider string
+}
+
+// SAMLMetadata returns the service provider metadata the identity provider administrator registers.
+func (s *ServiceImpl) SAMLMetadata(ctx context.Context, params *SAMLMetadataParams) ([]byte, error) {
+	provider, err := s.samlProvider(params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := provider.Metadata()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("metadata: %w", err))
+	}
+
+	return metadata, nil
+}
+
+type BeginSAMLLoginParams struct {
+	Provider string
+}
+
+func (s *ServiceImpl) BeginSAMLLogin(ctx context.Context, params *BeginSAMLLoginParams) (*BeginLoginResult, error) {
+	provider, err := s.samlProvider(params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &flowState{
+		Provider:  params.Provider,
+		ExpiresAt: time.Now().Add(flowStateLifetime),
+	}
+	state.State, err = randomString()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("random string: %w", err))
+	}
+
+	authURL, requestID, err := provider.AuthnRequestURL(ctx, state.State)
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("authn request url: %w", err))
+	}
+	state.RequestID = requestID
+
+	sealed, err := seal(s.StateKey, state)
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("seal flow state: %w", err))
+	}
+
+	return &BeginLoginResult{
+		AuthURL:   authURL,
+		FlowState: sealed,
+		ExpiresAt: state.ExpiresAt,
+	}, nil
+}
+
+type CompleteSAMLLoginParams struct {
+	Provider     string
+	FlowState    string
+	RelayState   string
+	SAMLResponse string
+	Device       model.Device
+}
+
+// CompleteSAMLLogin validates the response posted to the assertion consumer service and signs the user in.
+// Without a flow state the response is only accepted if the provider allows IdP-initiated login.
+func (s *ServiceImpl) CompleteSAMLLogin(ctx context.Context, params *CompleteSAMLLoginParams) (*model.Token, error) {
+	provider, err := s.samlProvider(params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var requestIDs []string
+	if params.FlowState != "" {
+		state, err := open(s.StateKey, params.FlowState)
+		if err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("open flow state: %w", err))
+		}
+		if state.Provider != params.Provider || state.State != params.RelayState {
+			return nil, utils.WrapValidationError(fmt.Errorf("state mismatch"))
+		}
+		requestIDs = []string{state.RequestID}
+	}
+
+	assertion, err := provider.ParseResponse(ctx, params.SAMLResponse, requestIDs)
+	if err != nil {
+		return nil, utils.WrapUnauthorizedError(fmt.Errorf("parse response: %w", err), utils.UnauthorizedMessage)
+	}
+
+	return s.signIn(ctx, samlIdentityProvider(params.Provider), nil, profileFromAssertion(params.Provider, assertion), params.Device)
+}
+
+// samlIdentityProvider is the provider of SAML identities in identity_table. OpenID Connect identities keep the bare
+// provider name, so subjects of a SAML and an OpenID Connect provider configured under the same name never mix.
+func samlIdentityProvider(name string) string {
+	return "saml:" + name
+}
+
+func profileFromAssertion(provider string, assertion *saml.Profile) *externalProfile {
+	profile := &externalProfile{
+		Subject:    assertion.Subject,
+		Username:   assertion.Username,
+		Email:      assertion.Email,
+		FirstName:  assertion.FirstName,
+		SecondName: assertion.SecondName,
+		City:       assertion.City,
+	}
+	// The assertion is signed by the provider, so its email is as trustworthy as the rest of it.
+	if profile.Username == "" && profile.Email != "" {
+		profile.Username = strings.SplitN(profile.Email, "@", 2)[0]
+	}
+	profile.Username = candidateUsername(provider, profile)
+
+	return profile
+}
+
+func (s *ServiceImpl) samlProvider(name string) (*saml.Provider, error) {
+	provider, ok := s.SAMLProviders[name]
+	if !ok {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("unknown saml identity provider: %s", name), utils.NotFoundMessage)
+	}
+
+	return provider, nil
+}
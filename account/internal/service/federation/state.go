@@ -0,0 +1,74 @@
+package federation
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// flowState is kept by the browser between the redirect to the provider and the callback.
+type flowState struct {
+	Provider  string    `json:"provider"`
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// RequestID is the SAML AuthnRequest the response has to answer.
+	RequestID string `json:"request_id,omitempty"`
+	// LinkUserID is set when an authenticated user links the identity to their account instead of signing in.
+	LinkUserID string `json:"link_user_id,omitempty"`
+}
+
+// seal serializes the flow state and signs it so the browser can't tamper with it.
+func seal(key []byte, state *flowState) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(key, encoded), nil
+}
+
+// open verifies the signature and expiration of a sealed flow state.
+func open(key []byte, sealed string) (*flowState, error) {
+	encoded, signature, ok := strings.Cut(sealed, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(key, encoded))) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	var state flowState
+	if err = json.Unmarshal(payload, &state); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, fmt.Errorf("flow state expired")
+	}
+
+	return &state, nil
+}
+
+func sign(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomString() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,230 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/federation"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"time"
+)
+
+// PasswordIdentityID addresses the password credential among the login identities of a user.
+const PasswordIdentityID model.IdentityID = "password"
+
+type Service interface {
+	ListIdentities(ctx context.Context, params *ListIdentitiesParams) (*Identities, error)
+	LinkPassword(ctx context.Context, params *LinkPasswordParams) error
+	LinkProvider(ctx context.Context, params *LinkProviderParams) (*federation.BeginLoginResult, error)
+	UnlinkIdentity(ctx context.Context, params *UnlinkIdentityParams) error
+	Reauthenticate(ctx context.Context, params *ReauthenticateParams) error
+}
+
+type ServiceImpl struct {
+	Storage                 storage.Storage
+	FederationService       federation.Service
+	ReauthenticationTimeout time.Duration
+	Logger                  *zap.Logger
+}
+
+// Identities are all the ways a user can sign in to the account.
+type Identities struct {
+	HasPassword bool
+	External    []*model.Identity
+}
+
+func (i *Identities) count() int {
+	count := len(i.External)
+	if i.HasPassword {
+		count++
+	}
+
+	return count
+}
+
+type ListIdentitiesParams struct {
+	UserID model.UserID
+}
+
+func (s *ServiceImpl) ListIdentities(ctx context.Context, params *ListIdentitiesParams) (*Identities, error) {
+	// The identities are listed right after linking or unlinking one, a lagging replica would still show the old set.
+	return listIdentities(storage.WithPrimary(ctx), s.Storage, params.UserID)
+}
+
+func listIdentities(ctx context.Context, store storage.Storage, userID model.UserID) (*Identities, error) {
+	user, err := store.User().GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	external, err := store.Identity().ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list identities: %w", err)
+	}
+
+	return &Identities{
+		HasPassword: user.HasPassword,
+		External:    external,
+	}, nil
+}
+
+type LinkPasswordParams struct {
+	Token    *model.Token
+	Password string
+}
+
+// LinkPassword lets an account created through an external provider sign in with a password as well.
+func (s *ServiceImpl) LinkPassword(ctx context.Context, params *LinkPasswordParams) error {
+	if err := s.requireReauthentication(params.Token); err != nil {
+		return err
+	}
+	if params.Password == "" {
+		return utils.WrapValidationError(fmt.Errorf("password cannot be empty"))
+	}
+
+	hashedPassword, err := utils.HashPassword(params.Password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	return s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		// A concurrent link must not overwrite the password this one checked was missing.
+		if err := tx.User().LockUser(ctx, params.Token.UserID); err != nil {
+			return fmt.Errorf("lock user: %w", err)
+		}
+
+		user, err := tx.User().GetUserByID(ctx, params.Token.UserID)
+		if err != nil {
+			return fmt.Errorf("get user by id: %w", err)
+		}
+		if user.HasPassword {
+			return utils.WrapError(fmt.Errorf("password is already linked"), utils.ConflictMessage, http.StatusConflict)
+		}
+
+		if err = tx.User().UpdatePassword(ctx, user.UserID, hashedPassword); err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+
+		return nil
+	})
+}
+
+type LinkProviderParams struct {
+	Token    *model.Token
+	Provider string
+}
+
+// LinkProvider starts an external login whose identity is attached to the current account on callback.
+func (s *ServiceImpl) LinkProvider(ctx context.Context, params *LinkProviderParams) (*federation.BeginLoginResult, error) {
+	if err := s.requireReauthentication(params.Token); err != nil {
+		return nil, err
+	}
+
+	result, err := s.FederationService.BeginLogin(ctx, &federation.BeginLoginParams{
+		Provider:   params.Provider,
+		LinkUserID: params.Token.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin login: %w", err)
+	}
+
+	return result, nil
+}
+
+type UnlinkIdentityParams struct {
+	Token      *model.Token
+	IdentityID model.IdentityID
+}
+
+// UnlinkIdentity detaches a login identity unless it is the last one left on the account.
+func (s *ServiceImpl) UnlinkIdentity(ctx context.Context, params *UnlinkIdentityParams) error {
+	if err := s.requireReauthentication(params.Token); err != nil {
+		return err
+	}
+	// The last identity guard must count the identities linked a moment ago.
+	ctx = storage.WithPrimary(ctx)
+
+	// Two unlinks running at once could each see the other identity left and remove both. The user lock makes the
+	// second one count again after the first committed.
+	return s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.User().LockUser(ctx, params.Token.UserID); err != nil {
+			return fmt.Errorf("lock user: %w", err)
+		}
+
+		identities, err := listIdentities(ctx, tx, params.Token.UserID)
+		if err != nil {
+			return fmt.Errorf("list identities: %w", err)
+		}
+		if identities.count() <= 1 {
+			return utils.WrapError(fmt.Errorf("cannot unlink the last identity"), utils.ConflictMessage, http.StatusConflict)
+		}
+
+		if params.IdentityID == PasswordIdentityID {
+			if !identities.HasPassword {
+				return utils.WrapNotFoundError(fmt.Errorf("password is not linked"), utils.NotFoundMessage)
+			}
+
+			if err = tx.User().UpdatePassword(ctx, params.Token.UserID, ""); err != nil {
+				return fmt.Errorf("update password: %w", err)
+			}
+
+			return nil
+		}
+
+		if err = tx.Identity().DeleteIdentity(ctx, params.Token.UserID, params.IdentityID); err != nil {
+			return fmt.Errorf("delete identity: %w", err)
+		}
+
+		return nil
+	})
+}
+
+type ReauthenticateParams struct {
+	Token    *model.Token
+	Password string
+}
+
+// Reauthenticate confirms the password in the current session before sensitive operations.
+// Accounts without a password re-authenticate by signing in with a linked provider again.
+func (s *ServiceImpl) Reauthenticate(ctx context.Context, params *ReauthenticateParams) error {
+	// A password linked a moment ago must already be accepted.
+	ctx = storage.WithPrimary(ctx)
+	user, err := s.Storage.User().GetUserByID(ctx, params.Token.UserID)
+	if err != nil {
+		return fmt.Errorf("get user by id: %w", err)
+	}
+	if !user.HasPassword {
+		return utils.WrapValidationError(fmt.Errorf("account has no password, sign in with a linked identity"))
+	}
+
+	hashedPassword, err := utils.HashPassword(params.Password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	loggedIn, err := s.Storage.User().LoginUser(ctx, &model.UserLogin{
+		Username:       user.Username,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil || loggedIn.UserID != user.UserID {
+		return utils.WrapUnauthorizedError(fmt.Errorf("incorrect password"), utils.UnauthorizedMessage)
+	}
+
+	err = s.Storage.Token().ReauthenticateToken(ctx, params.Token.TokenID)
+	if err != nil {
+		return fmt.Errorf("reauthenticate token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ServiceImpl) requireReauthentication(token *model.Token) error {
+	if !token.AuthenticatedWithin(s.ReauthenticationTimeout) {
+		return utils.WrapForbiddenError(fmt.Errorf("session authenticated at %s", token.AuthenticatedAt), utils.ReauthenticationRequiredMessage)
+	}
+
+	return nil
+}
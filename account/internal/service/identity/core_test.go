@@ -0,0 +1,73 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestUnlinkIdentity(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{Storage: store, ReauthenticationTimeout: time.Minute, Logger: zap.NewNop()}
+
+	user, err := store.User().CreateUser(ctx, &model.UserRegister{
+		ID:             utils.GenerateUUID(),
+		Username:       "linked",
+		HashedPassword: "hash",
+	})
+	require.NoError(t, err)
+	google, err := store.Identity().CreateIdentity(ctx, &model.Identity{
+		IdentityID: model.IdentityID(utils.GenerateUUID()),
+		UserID:     user.UserID,
+		Provider:   "google",
+		Subject:    "1",
+	})
+	require.NoError(t, err)
+
+	fresh := &model.Token{UserID: user.UserID, AuthenticatedAt: time.Now()}
+	stale := &model.Token{UserID: user.UserID, AuthenticatedAt: time.Now().Add(-time.Hour)}
+
+	err = service.UnlinkIdentity(ctx, &UnlinkIdentityParams{Token: stale, IdentityID: PasswordIdentityID})
+	assertStatus(t, http.StatusForbidden, err)
+
+	// The password goes, the external identity is left to sign in with.
+	require.NoError(t, service.UnlinkIdentity(ctx, &UnlinkIdentityParams{Token: fresh, IdentityID: PasswordIdentityID}))
+	identities, err := service.ListIdentities(ctx, &ListIdentitiesParams{UserID: user.UserID})
+	require.NoError(t, err)
+	assert.False(t, identities.HasPassword)
+	assert.Len(t, identities.External, 1)
+
+	err = service.UnlinkIdentity(ctx, &UnlinkIdentityParams{Token: fresh, IdentityID: google.IdentityID})
+	assertStatus(t, http.StatusConflict, err)
+	err = service.UnlinkIdentity(ctx, &UnlinkIdentityParams{Token: fresh, IdentityID: PasswordIdentityID})
+	assertStatus(t, http.StatusConflict, err)
+
+	// With a password linked again the external identity can go.
+	require.NoError(t, service.LinkPassword(ctx, &LinkPasswordParams{Token: fresh, Password: "secret"}))
+	err = service.LinkPassword(ctx, &LinkPasswordParams{Token: fresh, Password: "other"})
+	assertStatus(t, http.StatusConflict, err)
+	require.NoError(t, service.UnlinkIdentity(ctx, &UnlinkIdentityParams{Token: fresh, IdentityID: google.IdentityID}))
+
+	identities, err = service.ListIdentities(ctx, &ListIdentitiesParams{UserID: user.UserID})
+	require.NoError(t, err)
+	assert.True(t, identities.HasPassword)
+	assert.Empty(t, identities.External)
+	err = service.UnlinkIdentity(ctx, &UnlinkIdentityParams{Token: fresh, IdentityID: PasswordIdentityID})
+	assertStatus(t, http.StatusConflict, err)
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	result, ok := utils.FromError(err)
+	require.True(t, ok, err)
+	assert.Equal(t, status, result.StatusCode)
+}
@@ -0,0 +1,232 @@
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/username"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"time"
+)
+
+// Service provisions accounts on behalf of an enterprise identity system.
+type Service interface {
+	ListUsers(ctx context.Context, params *ListUsersParams) (*UserPage, error)
+	GetUser(ctx context.Context, params *GetUserParams) (*model.User, error)
+	CreateUser(ctx context.Context, params *CreateUserParams) (*model.User, error)
+	UpdateUser(ctx context.Context, params *UpdateUserParams) (*model.User, error)
+	DeleteUser(ctx context.Context, params *DeleteUserParams) error
+}
+
+type ServiceImpl struct {
+	Storage storage.Storage
+	// Usernames rejects the reserved usernames.
+	Usernames username.Checker
+	// DeletionGracePeriod is how long a deprovisioned user can be reactivated before the purge.
+	DeletionGracePeriod time.Duration
+	Logger              *zap.Logger
+}
+
+type ListUsersParams struct {
+	Conditions []model.UserCondition
+	Offset     uint64
+	Limit      uint64
+}
+
+type UserPage struct {
+	Users []*model.User
+	Total uint64
+}
+
+func (s *ServiceImpl) ListUsers(ctx context.Context, params *ListUsersParams) (*UserPage, error) {
+	users, total, err := s.Storage.User().ListUsers(ctx, &model.UserListParams{
+		Conditions: params.Conditions,
+		Offset:     params.Offset,
+		Limit:      params.Limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+
+	return &UserPage{Users: users, Total: total}, nil
+}
+
+type GetUserParams struct {
+	UserID model.UserID
+}
+
+// GetUser returns the user, deactivated ones too until they are purged.
+func (s *ServiceImpl) GetUser(ctx context.Context, params *GetUserParams) (*model.User, error) {
+	user, err := s.Storage.User().GetUserWithDeleted(ctx, params.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user with deleted: %w", err)
+	}
+
+	return user, nil
+}
+
+type CreateUserParams struct {
+	Username   string
+	Password   string
+	FirstName  string
+	SecondName string
+	City       string
+}
+
+// CreateUser provisions an account. Without a password the user can only sign in through federation.
+func (s *ServiceImpl) CreateUser(ctx context.Context, params *CreateUserParams) (*model.User, error) {
+	// The availability check must not miss a username taken moments ago.
+	ctx = storage.WithPrimary(ctx)
+	if err := s.Usernames.CheckAvailable(ctx, s.Storage.User(), "", params.Username); err != nil {
+		return nil, err
+	}
+
+	var hashedPassword string
+	if params.Password != "" {
+		var err error
+		hashedPassword, err = utils.HashPassword(params.Password)
+		if err != nil {
+			return nil, fmt.Errorf("hash password: %w", err)
+		}
+	}
+
+	user, err := s.Storage.User().CreateUser(ctx, &model.UserRegister{
+		ID:             utils.GenerateUUID(),
+		Username:       params.Username,
+		HashedPassword: hashedPassword,
+		FirstName:      params.FirstName,
+		SecondName:     params.SecondName,
+		City:           params.City,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+
+	s.Logger.Sugar().Infof("provisioned user %s", user.UserID)
+
+	return user, nil
+}
+
+type UpdateUserParams struct {
+	Update   model.UserUpdate
+	Password *string
+	// Active set to false deprovisions the user after applying the update, set to true it reactivates a
+	// deprovisioned user first.
+	Active *bool
+}
+
+// UpdateUser applies the update to the user. A deactivated user is left as it is unless the update reactivates it.
+func (s *ServiceImpl) UpdateUser(ctx context.Context, params *UpdateUserParams) (*model.User, error) {
+	// The username is compared with the current one before it is written.
+	ctx = storage.WithPrimary(ctx)
+	update := params.Update
+	current, err := s.Storage.User().GetUserWithDeleted(ctx, update.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get user with deleted: %w", err)
+	}
+
+	reactivate := !current.DeletedAt.IsZero() && params.Active != nil && *params.Active
+	if !current.DeletedAt.IsZero() && !reactivate {
+		return current, nil
+	}
+	// Users who deleted their account themselves are not brought back by the identity system.
+	if reactivate && current.DeletedBy != model.DeletedByProvisioning {
+		return nil, utils.WrapForbiddenError(fmt.Errorf("user %s deleted the account", update.ID), utils.DeletedByUserMessage)
+	}
+
+	if update.Username != nil {
+		// Identity systems resend the username on every update. Usernames that are invalid by today's rules are
+		// kept unless they actually change.
+		if current.Username == *update.Username {
+			update.Username = nil
+		} else if err = s.Usernames.CheckAvailable(ctx, s.Storage.User(), update.ID, *update.Username); err != nil {
+			return nil, err
+		}
+	}
+
+	var hashedPassword string
+	if params.Password != nil {
+		hashedPassword, err = utils.HashPassword(*params.Password)
+		if err != nil {
+			return nil, fmt.Errorf("hash password: %w", err)
+		}
+	}
+
+	// The identity system sends the whole user at once, it is applied completely or not at all.
+	var user *model.User
+	err = s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		if reactivate {
+			_, err := tx.User().RestoreUserByID(ctx, update.ID, time.Now().Add(-s.DeletionGracePeriod), model.DeletedByProvisioning)
+			if err != nil {
+				return fmt.Errorf("restore user by id: %w", err)
+			}
+		}
+
+		var err error
+		user, err = tx.User().UpdateUser(ctx, &update)
+		if err != nil {
+			return fmt.Errorf("update user: %w", err)
+		}
+
+		if params.Password != nil {
+			if err = tx.User().UpdatePassword(ctx, user.UserID, hashedPassword); err != nil {
+				return fmt.Errorf("update password: %w", err)
+			}
+			user.HasPassword = true
+		}
+
+		if params.Active != nil && !*params.Active {
+			if err = deprovision(ctx, tx, user.UserID); err != nil {
+				return err
+			}
+			user.DeletedAt = time.Now()
+			user.DeletedBy = model.DeletedByProvisioning
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reactivate {
+		s.Logger.Sugar().Infof("reactivated user %s", user.UserID)
+	}
+	if params.Active != nil && !*params.Active {
+		s.Logger.Sugar().Infof("deprovisioned user %s", user.UserID)
+	}
+
+	return user, nil
+}
+
+type DeleteUserParams struct {
+	UserID model.UserID
+}
+
+func (s *ServiceImpl) DeleteUser(ctx context.Context, params *DeleteUserParams) error {
+	err := s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		return deprovision(ctx, tx, params.UserID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.Logger.Sugar().Infof("deprovisioned user %s", params.UserID)
+
+	return nil
+}
+
+// deprovision soft-deletes the user and signs them out everywhere.
+func deprovision(ctx context.Context, tx storage.Storage, userID model.UserID) error {
+	if err := tx.User().DeleteUser(ctx, userID, model.DeletedByProvisioning); err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+
+	if err := tx.Token().RevokeUserTokens(ctx, userID); err != nil {
+		return fmt.Errorf("revoke user tokens: %w", err)
+	}
+
+	return nil
+}
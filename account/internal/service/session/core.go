@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"time"
+)
+
+type Service interface {
+	ListSessions(ctx context.Context, params *ListSessionsParams) ([]*model.Session, error)
+	RevokeSession(ctx context.Context, params *RevokeSessionParams) error
+	RevokeSessions(ctx context.Context, params *RevokeSessionsParams) error
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+}
+
+type ServiceImpl struct {
+	Storage storage.Storage
+	// Retention is how long expired and revoked sessions are kept before they are deleted.
+	Retention time.Duration
+	Logger    *zap.Logger
+}
+
+type ListSessionsParams struct {
+	UserID model.UserID
+}
+
+func (s *ServiceImpl) ListSessions(ctx context.Context, params *ListSessionsParams) ([]*model.Session, error) {
+	sessions, err := s.Storage.Token().ListSessions(ctx, params.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+type RevokeSessionParams struct {
+	UserID  model.UserID
+	TokenID model.TokenID
+}
+
+// RevokeSession signs out a single session of the user. Sessions of other users are reported as not found.
+func (s *ServiceImpl) RevokeSession(ctx context.Context, params *RevokeSessionParams) error {
+	sessions, err := s.Storage.Token().ListSessions(ctx, params.UserID)
+	if err != nil {
+		return fmt.Errorf("list sessions: %w", err)
+	}
+
+	for _, session := range sessions {
+		if session.TokenID != params.TokenID {
+			continue
+		}
+
+		if err = s.Storage.Token().RevokeToken(ctx, &session.Token); err != nil {
+			return fmt.Errorf("revoke token: %w", err)
+		}
+		return nil
+	}
+
+	return utils.WrapNotFoundError(fmt.Errorf("session not found"), utils.NotFoundMessage)
+}
+
+type RevokeSessionsParams struct {
+	UserID model.UserID
+	// ExceptTokenID keeps one session, usually the one making the request, signed in.
+	ExceptTokenID model.TokenID
+}
+
+// RevokeSessions signs the user out everywhere.
+func (s *ServiceImpl) RevokeSessions(ctx context.Context, params *RevokeSessionsParams) error {
+	sessions, err := s.Storage.Token().ListSessions(ctx, params.UserID)
+	if err != nil {
+		return fmt.Errorf("list sessions: %w", err)
+	}
+
+	for _, session := range sessions {
+		if session.TokenID == params.ExceptTokenID {
+			continue
+		}
+
+		// A session may expire or be revoked concurrently, which is what we want anyway.
+		err = s.Storage.Token().RevokeToken(ctx, &session.Token)
+		if err != nil && !utils.IsNotFoundError(err) {
+			return fmt.Errorf("revoke token: %w", err)
+		}
+	}
+
+	s.Logger.Sugar().Infof("revoked sessions of user %s", params.UserID)
+
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions that expired or were revoked longer than the retention ago and
+// returns how many were deleted.
+func (s *ServiceImpl) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	deleted, err := s.Storage.Token().DeleteExpiredTokens(ctx, time.Now().Add(-s.Retention))
+	if err != nil {
+		return 0, fmt.Errorf("delete expired tokens: %w", err)
+	}
+
+	return deleted, nil
+}
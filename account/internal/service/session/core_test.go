@@ -0,0 +1,109 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{Storage: store, Logger: zap.NewNop()}
+
+	user := createUser(t, store)
+	other := createUser(t, store)
+	current := createToken(t, store, user.UserID, time.Hour)
+	laptop := createToken(t, store, user.UserID, time.Hour)
+	foreign := createToken(t, store, other.UserID, time.Hour)
+	expired := createToken(t, store, user.UserID, -time.Hour)
+
+	// Sessions of other users and the ones that are over can't be revoked.
+	err := service.RevokeSession(ctx, &RevokeSessionParams{UserID: user.UserID, TokenID: foreign.TokenID})
+	assertStatus(t, http.StatusNotFound, err)
+	err = service.RevokeSession(ctx, &RevokeSessionParams{UserID: user.UserID, TokenID: expired.TokenID})
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = store.Token().GetToken(ctx, foreign.Token)
+	require.NoError(t, err)
+
+	require.NoError(t, service.RevokeSession(ctx, &RevokeSessionParams{UserID: user.UserID, TokenID: laptop.TokenID}))
+	_, err = store.Token().GetToken(ctx, laptop.Token)
+	assertStatus(t, http.StatusNotFound, err)
+
+	err = service.RevokeSession(ctx, &RevokeSessionParams{UserID: user.UserID, TokenID: laptop.TokenID})
+	assertStatus(t, http.StatusNotFound, err)
+
+	sessions, err := service.ListSessions(ctx, &ListSessionsParams{UserID: user.UserID})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.TokenID, sessions[0].TokenID)
+}
+
+func TestRevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{Storage: store, Logger: zap.NewNop()}
+
+	user := createUser(t, store)
+	other := createUser(t, store)
+	current := createToken(t, store, user.UserID, time.Hour)
+	createToken(t, store, user.UserID, time.Hour)
+	createToken(t, store, user.UserID, time.Hour)
+	foreign := createToken(t, store, other.UserID, time.Hour)
+
+	require.NoError(t, service.RevokeSessions(ctx, &RevokeSessionsParams{UserID: user.UserID, ExceptTokenID: current.TokenID}))
+
+	sessions, err := service.ListSessions(ctx, &ListSessionsParams{UserID: user.UserID})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.TokenID, sessions[0].TokenID)
+	_, err = store.Token().GetToken(ctx, foreign.Token)
+	assert.NoError(t, err)
+
+	require.NoError(t, service.RevokeSessions(ctx, &RevokeSessionsParams{UserID: user.UserID}))
+	sessions, err = service.ListSessions(ctx, &ListSessionsParams{UserID: user.UserID})
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func createUser(t *testing.T, store *memory.Storage) *model.User {
+	t.Helper()
+
+	user, err := store.User().CreateUser(context.Background(), &model.UserRegister{
+		ID:       utils.GenerateUUID(),
+		Username: "user" + utils.GenerateUUID(),
+	})
+	require.NoError(t, err)
+
+	return user
+}
+
+func createToken(t *testing.T, store *memory.Storage, userID model.UserID, lifetime time.Duration) *model.Token {
+	t.Helper()
+
+	token, err := store.Token().CreateToken(context.Background(), &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   userID,
+		Token:    utils.GenerateUUID(),
+		AlivedAt: time.Now().Add(lifetime),
+	})
+	require.NoError(t, err)
+
+	return token
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+
+	result, ok := utils.FromError(err)
+	require.True(t, ok, "error %v is not an ErrorResult", err)
+	assert.Equal(t, status, result.StatusCode)
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/token"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAccountLifecycle(t *testing.T) {
+	ctx := context.Background()
+	service := &ServiceImpl{
+		Storage:                 memory.NewStorage(),
+		TokenGenerator:          token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		ReauthenticationTimeout: time.Minute,
+		DeletionGracePeriod:     time.Hour,
+		Logger:                  zap.NewNop(),
+	}
+
+	registered, err := service.Register(ctx, &RegisterParams{Username: "Nikita", Password: "secret"})
+	require.NoError(t, err)
+
+	_, err = service.Register(ctx, &RegisterParams{Username: "nikita", Password: "secret"})
+	assertStatus(t, http.StatusConflict, err)
+
+	authenticated, err := service.Authenticate(ctx, &AuthenticateParams{Token: registered.Token})
+	require.NoError(t, err)
+	assert.Equal(t, registered.UserID, authenticated.UserID)
+
+	loggedIn, err := service.Login(ctx, &LoginParams{Username: "Nikita", Password: "secret"})
+	require.NoError(t, err)
+
+	_, err = service.DeleteAccount(ctx, &DeleteAccountParams{Token: authenticated})
+	require.NoError(t, err)
+
+	// Deleting the account signs it out of every session.
+	_, err = service.Authenticate(ctx, &AuthenticateParams{Token: loggedIn.Token})
+	assertStatus(t, http.StatusUnauthorized, err)
+
+	restored, err := service.RestoreAccount(ctx, &RestoreAccountParams{Username: "Nikita", Password: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, registered.UserID, restored.UserID)
+}
+
+func TestDeleteAccountRequiresReauthentication(t *testing.T) {
+	ctx := context.Background()
+	service := &ServiceImpl{
+		Storage:                 memory.NewStorage(),
+		TokenGenerator:          token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		ReauthenticationTimeout: time.Minute,
+		DeletionGracePeriod:     time.Hour,
+		Logger:                  zap.NewNop(),
+	}
+
+	registered, err := service.Register(ctx, &RegisterParams{Username: "nikita", Password: "secret"})
+	require.NoError(t, err)
+	stale := *registered
+	stale.AuthenticatedAt = time.Now().Add(-time.Hour)
+
+	_, err = service.DeleteAccount(ctx, &DeleteAccountParams{Token: &stale})
+	assertStatus(t, http.StatusForbidden, err)
+
+	_, err = service.Authenticate(ctx, &AuthenticateParams{Token: registered.Token})
+	assert.NoError(t, err)
+}
+
+func TestRestoreAccount(t *testing.T) {
+	ctx := context.Background()
+	service := &ServiceImpl{
+		Storage:                 memory.NewStorage(),
+		TokenGenerator:          token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		ReauthenticationTimeout: time.Minute,
+		DeletionGracePeriod:     time.Hour,
+		Logger:                  zap.NewNop(),
+	}
+
+	registered, err := service.Register(ctx, &RegisterParams{Username: "nikita", Password: "secret"})
+	require.NoError(t, err)
+	deleted, err := service.DeleteAccount(ctx, &DeleteAccountParams{Token: registered})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deleted.RestoreUntil, time.Minute)
+
+	_, err = service.Login(ctx, &LoginParams{Username: "nikita", Password: "secret"})
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = service.RestoreAccount(ctx, &RestoreAccountParams{Username: "nikita", Password: "wrong"})
+	assertStatus(t, http.StatusUnauthorized, err)
+
+	// Past the grace period the purge anonymizes the account and it can't be restored anymore.
+	service.DeletionGracePeriod = 0
+	purged, err := service.PurgeDeletedAccounts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	service.DeletionGracePeriod = time.Hour
+	_, err = service.RestoreAccount(ctx, &RestoreAccountParams{Username: "nikita", Password: "secret"})
+	assertStatus(t, http.StatusUnauthorized, err)
+}
+
+func TestRestoreAccountRefusesDeprovisionedAccount(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{
+		Storage:             store,
+		TokenGenerator:      token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		DeletionGracePeriod: time.Hour,
+		Logger:              zap.NewNop(),
+	}
+
+	registered, err := service.Register(ctx, &RegisterParams{Username: "nikita", Password: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, store.User().DeleteUser(ctx, registered.UserID, model.DeletedByProvisioning))
+
+	_, err = service.RestoreAccount(ctx, &RestoreAccountParams{Username: "nikita", Password: "secret"})
+	assertStatus(t, http.StatusUnauthorized, err)
+	_, err = store.User().GetUserByID(ctx, registered.UserID)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+
+	result, ok := utils.FromError(err)
+	require.True(t, ok, "error %v is not an ErrorResult", err)
+	assert.Equal(t, status, result.StatusCode)
+}
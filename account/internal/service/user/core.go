@@ -2,43 +2,77 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"pulse-auth/internal/directory"
 	"pulse-auth/internal/model"
+	"pulse-auth/internal/service/username"
 	"pulse-auth/internal/storage"
 	"pulse-auth/internal/token"
 	"pulse-auth/internal/utils"
+	"time"
 )
 
+// lastUsedResolution limits how often authenticated requests write the last use of their session.
+const lastUsedResolution = time.Minute
+
 type Service interface {
 	Login(ctx context.Context, params *LoginParams) (*model.Token, error)
 	Register(ctx context.Context, params *RegisterParams) (*model.Token, error)
 	GetUserByID(ctx context.Context, params *GetUserByIDParams) (*model.User, error)
+	GetUsersByIDs(ctx context.Context, params *GetUsersByIDsParams) (*UserBatch, error)
 	SearchUser(ctx context.Context, params *SearchUserParams) (*model.User, error)
+	SearchUsers(ctx context.Context, params *SearchUsersParams) (*UserPage, error)
+	UpdateProfile(ctx context.Context, params *UpdateProfileParams) (*model.User, error)
+	ChangeUsername(ctx context.Context, params *ChangeUsernameParams) (*model.User, error)
+	GetUserByUsername(ctx context.Context, params *GetUserByUsernameParams) (*model.User, error)
+	Authenticate(ctx context.Context, params *AuthenticateParams) (*model.Token, error)
+	DeleteAccount(ctx context.Context, params *DeleteAccountParams) (*DeletedAccount, error)
+	RestoreAccount(ctx context.Context, params *RestoreAccountParams) (*model.Token, error)
+	PurgeDeletedAccounts(ctx context.Context) (int64, error)
 }
 
 type ServiceImpl struct {
 	Storage        storage.Storage
 	TokenGenerator *token.Generator
-	Logger         *zap.Logger
+	// Directory is consulted before local passwords when directory authentication is enabled.
+	Directory directory.Authenticator
+	// ReauthenticationTimeout is how recently the user must have entered credentials to delete the account.
+	ReauthenticationTimeout time.Duration
+	// DeletionGracePeriod is how long a deleted account can be restored before its data is purged.
+	DeletionGracePeriod time.Duration
+	// MaxBatchSize caps the number of users looked up at once.
+	MaxBatchSize int
+	// UsernameChangeCooldown is how long after a username change the next one is allowed.
+	UsernameChangeCooldown time.Duration
+	// UsernameReservation is how long a previous username stays reserved for its former owner.
+	UsernameReservation time.Duration
+	// Usernames rejects the reserved usernames.
+	Usernames username.Checker
+	Logger    *zap.Logger
 }
 
 type LoginParams struct {
 	Username string
 	Password string
+	Device   model.Device
 }
 
 func (s *ServiceImpl) Login(ctx context.Context, params *LoginParams) (*model.Token, error) {
-	hashedPassword, err := utils.HashPassword(params.Password)
-	if err != nil {
-		return nil, fmt.Errorf("hash password: %w", err)
+	// A directory login may have just provisioned the user.
+	ctx = storage.WithPrimary(ctx)
+	user, err := s.loginWithDirectory(ctx, params)
+	if errors.Is(err, errDirectoryUnavailable) {
+		// Local accounts must keep working while the directory is down. Directory users have no local password,
+		// so they still can't sign in.
+		s.Logger.Sugar().Warnf("directory login failed, falling back to the local password: %v", err)
+	}
+	if errors.Is(err, errDirectoryDisabled) || errors.Is(err, directory.ErrUserNotFound) || errors.Is(err, errDirectoryUnavailable) {
+		user, err = s.loginWithPassword(ctx, params)
 	}
-	user, err := s.Storage.User().LoginUser(ctx, &model.UserLogin{
-		Username:       params.Username,
-		HashedPassword: hashedPassword,
-	})
 	if err != nil {
-		return nil, fmt.Errorf("login user: %w", err)
+		return nil, err
 	}
 
 	generatedToken, err := s.TokenGenerator.GenerateToken(user)
@@ -51,6 +85,7 @@ func (s *ServiceImpl) Login(ctx context.Context, params *LoginParams) (*model.To
 		UserID:   user.UserID,
 		Token:    generatedToken,
 		AlivedAt: s.TokenGenerator.GetExpirationDate(),
+		Device:   params.Device,
 	})
 
 	if err != nil {
@@ -60,40 +95,89 @@ func (s *ServiceImpl) Login(ctx context.Context, params *LoginParams) (*model.To
 	return token, nil
 }
 
-type RegisterParams struct {
-	Username string
-	Password string
-}
-
-func (s *ServiceImpl) Register(ctx context.Context, params *RegisterParams) (*model.Token, error) {
+func (s *ServiceImpl) loginWithPassword(ctx context.Context, params *LoginParams) (*model.User, error) {
 	hashedPassword, err := utils.HashPassword(params.Password)
 	if err != nil {
 		return nil, fmt.Errorf("hash password: %w", err)
 	}
-
-	user, err := s.Storage.User().CreateUser(ctx, &model.UserRegister{
-		ID:             utils.GenerateUUID(),
+	user, err := s.Storage.User().LoginUser(ctx, &model.UserLogin{
 		Username:       params.Username,
 		HashedPassword: hashedPassword,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("create user: %w", err)
+		return nil, fmt.Errorf("login user: %w", err)
 	}
 
-	generatedToken, err := s.TokenGenerator.GenerateToken(user)
+	user.Roles, err = s.Storage.User().GetRoles(ctx, user.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("generate token: %w", err)
+		return nil, fmt.Errorf("get roles: %w", err)
 	}
 
-	token, err := s.Storage.Token().CreateToken(ctx, &model.TokenWithMetadata{
-		TokenID:  utils.GenerateUUID(),
-		UserID:   user.UserID,
-		Token:    generatedToken,
-		AlivedAt: s.TokenGenerator.GetExpirationDate(),
-	})
+	return user, nil
+}
+
+type RegisterParams struct {
+	Username   string
+	Password   string
+	FirstName  string
+	SecondName string
+	Sex        string
+	Birthdate  time.Time
+	Biography  string
+	City       string
+	Device     model.Device
+}
+
+func (s *ServiceImpl) Register(ctx context.Context, params *RegisterParams) (*model.Token, error) {
+	// The availability check must not miss a username taken moments ago.
+	ctx = storage.WithPrimary(ctx)
+	if err := s.Usernames.CheckAvailable(ctx, s.Storage.User(), "", params.Username); err != nil {
+		return nil, err
+	}
 
+	hashedPassword, err := utils.HashPassword(params.Password)
 	if err != nil {
-		return nil, fmt.Errorf("create token: %w", err)
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	// The user is only created together with their first session, a failure in between leaves no account behind.
+	var token *model.Token
+	err = s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		user, err := tx.User().CreateUser(ctx, &model.UserRegister{
+			ID:             utils.GenerateUUID(),
+			Username:       params.Username,
+			HashedPassword: hashedPassword,
+			FirstName:      params.FirstName,
+			SecondName:     params.SecondName,
+			Sex:            params.Sex,
+			Birthdate:      params.Birthdate,
+			Biography:      params.Biography,
+			City:           params.City,
+		})
+		if err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+
+		generatedToken, err := s.TokenGenerator.GenerateToken(user)
+		if err != nil {
+			return fmt.Errorf("generate token: %w", err)
+		}
+
+		token, err = tx.Token().CreateToken(ctx, &model.TokenWithMetadata{
+			TokenID:  utils.GenerateUUID(),
+			UserID:   user.UserID,
+			Token:    generatedToken,
+			AlivedAt: s.TokenGenerator.GetExpirationDate(),
+			Device:   params.Device,
+		})
+		if err != nil {
+			return fmt.Errorf("create token: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return token, nil
@@ -112,6 +196,50 @@ func (s *ServiceImpl) GetUserByID(ctx context.Context, params *GetUserByIDParams
 	return user, nil
 }
 
+type GetUsersByIDsParams struct {
+	UserIDs []model.UserID
+}
+
+type UserBatch struct {
+	Users map[model.UserID]*model.User
+	// Missing lists the requested ids of unknown or deleted users.
+	Missing []model.UserID
+}
+
+func (s *ServiceImpl) GetUsersByIDs(ctx context.Context, params *GetUsersByIDsParams) (*UserBatch, error) {
+	ids := make([]model.UserID, 0, len(params.UserIDs))
+	seen := make(map[model.UserID]bool, len(params.UserIDs))
+	for _, id := range params.UserIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > s.MaxBatchSize {
+		return nil, utils.WrapValidationError(fmt.Errorf("%d user ids exceed the batch size of %d", len(ids), s.MaxBatchSize))
+	}
+
+	batch := &UserBatch{Users: make(map[model.UserID]*model.User, len(ids))}
+	if len(ids) == 0 {
+		return batch, nil
+	}
+
+	users, err := s.Storage.User().GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get users by ids: %w", err)
+	}
+	for _, user := range users {
+		batch.Users[user.UserID] = user
+	}
+	for _, id := range ids {
+		if _, ok := batch.Users[id]; !ok {
+			batch.Missing = append(batch.Missing, id)
+		}
+	}
+
+	return batch, nil
+}
+
 type SearchUserParams struct {
 	FirstName string
 	LastName  string
@@ -125,3 +253,162 @@ func (s *ServiceImpl) SearchUser(ctx context.Context, params *SearchUserParams)
 
 	return user, nil
 }
+
+// UpdateProfileParams holds the fields to change. Nil fields are left as they are.
+type UpdateProfileParams struct {
+	UserID     model.UserID
+	FirstName  *string
+	SecondName *string
+	Sex        *string
+	Birthdate  *time.Time
+	Biography  *string
+	City       *string
+
+	SexVisibility       *model.Visibility
+	BirthdateVisibility *model.Visibility
+	BiographyVisibility *model.Visibility
+	CityVisibility      *model.Visibility
+	Searchable          *bool
+}
+
+func (s *ServiceImpl) UpdateProfile(ctx context.Context, params *UpdateProfileParams) (*model.User, error) {
+	user, err := s.Storage.User().UpdateUser(ctx, &model.UserUpdate{
+		ID:         params.UserID,
+		FirstName:  params.FirstName,
+		SecondName: params.SecondName,
+		Sex:        params.Sex,
+		Birthdate:  params.Birthdate,
+		Biography:  params.Biography,
+		City:       params.City,
+
+		SexVisibility:       params.SexVisibility,
+		BirthdateVisibility: params.BirthdateVisibility,
+		BiographyVisibility: params.BiographyVisibility,
+		CityVisibility:      params.CityVisibility,
+		Searchable:          params.Searchable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	return user, nil
+}
+
+type AuthenticateParams struct {
+	Token string
+}
+
+func (s *ServiceImpl) Authenticate(ctx context.Context, params *AuthenticateParams) (*model.Token, error) {
+	token, err := s.Storage.Token().GetToken(ctx, params.Token)
+	if err != nil {
+		if utils.IsNotFoundError(err) {
+			return nil, utils.WrapUnauthorizedError(err, utils.UnauthorizedMessage)
+		}
+		return nil, fmt.Errorf("get token: %w", err)
+	}
+
+	if time.Since(token.LastUsedAt) > lastUsedResolution {
+		// The session list is informational, so a failed write must not fail the request.
+		if err = s.Storage.Token().TouchToken(ctx, token.TokenID); err != nil {
+			s.Logger.Sugar().Warnf("touch token %s: %v", token.TokenID, err)
+		}
+	}
+
+	return token, nil
+}
+
+type DeleteAccountParams struct {
+	Token *model.Token
+}
+
+type DeletedAccount struct {
+	UserID       model.UserID
+	RestoreUntil time.Time
+}
+
+// DeleteAccount soft-deletes the user and signs them out everywhere. The account can be restored
+// with its password until the grace period expires.
+func (s *ServiceImpl) DeleteAccount(ctx context.Context, params *DeleteAccountParams) (*DeletedAccount, error) {
+	if !params.Token.AuthenticatedWithin(s.ReauthenticationTimeout) {
+		return nil, utils.WrapForbiddenError(fmt.Errorf("session authenticated at %s", params.Token.AuthenticatedAt), utils.ReauthenticationRequiredMessage)
+	}
+
+	err := s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.User().DeleteUser(ctx, params.Token.UserID, model.DeletedByUser); err != nil {
+			return fmt.Errorf("delete user: %w", err)
+		}
+
+		if err := tx.Token().RevokeUserTokens(ctx, params.Token.UserID); err != nil {
+			return fmt.Errorf("revoke user tokens: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeletedAccount{
+		UserID:       params.Token.UserID,
+		RestoreUntil: time.Now().Add(s.DeletionGracePeriod),
+	}, nil
+}
+
+type RestoreAccountParams struct {
+	Username string
+	Password string
+	Device   model.Device
+}
+
+// RestoreAccount undeletes an account within the grace period and signs the user in. Accounts without a password
+// are restored by the federation service when the user signs in with a linked identity.
+func (s *ServiceImpl) RestoreAccount(ctx context.Context, params *RestoreAccountParams) (*model.Token, error) {
+	hashedPassword, err := utils.HashPassword(params.Password)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	user, err := s.Storage.User().RestoreUser(ctx, &model.UserLogin{
+		Username:       params.Username,
+		HashedPassword: hashedPassword,
+	}, time.Now().Add(-s.DeletionGracePeriod))
+	if err != nil {
+		if utils.IsNotFoundError(err) {
+			return nil, utils.WrapUnauthorizedError(err, utils.UnauthorizedMessage)
+		}
+		return nil, fmt.Errorf("restore user: %w", err)
+	}
+
+	user.Roles, err = s.Storage.User().GetRoles(ctx, user.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get roles: %w", err)
+	}
+
+	generatedToken, err := s.TokenGenerator.GenerateToken(user)
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	token, err := s.Storage.Token().CreateToken(ctx, &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   user.UserID,
+		Token:    generatedToken,
+		AlivedAt: s.TokenGenerator.GetExpirationDate(),
+		Device:   params.Device,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	return token, nil
+}
+
+// PurgeDeletedAccounts anonymizes accounts whose grace period has expired and returns how many were purged.
+func (s *ServiceImpl) PurgeDeletedAccounts(ctx context.Context) (int64, error) {
+	purged, err := s.Storage.User().PurgeUsers(ctx, time.Now().Add(-s.DeletionGracePeriod))
+	if err != nil {
+		return 0, fmt.Errorf("purge users: %w", err)
+	}
+
+	return purged, nil
+}
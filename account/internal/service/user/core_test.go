@@ -0,0 +1,65 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubStorage struct {
+	storage.Storage
+	users *stubUserRepository
+}
+
+func (s stubStorage) User() storage.UserRepository {
+	return s.users
+}
+
+type stubUserRepository struct {
+	storage.UserRepository
+	users   map[model.UserID]*model.User
+	history []*model.UsernameRecord
+	queries [][]model.UserID
+}
+
+func (r *stubUserRepository) GetUsersByIDs(_ context.Context, ids []model.UserID) ([]*model.User, error) {
+	r.queries = append(r.queries, ids)
+
+	var users []*model.User
+	for _, id := range ids {
+		if user, ok := r.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	repository := &stubUserRepository{users: map[model.UserID]*model.User{
+		"1": {UserID: "1"},
+		"2": {UserID: "2"},
+	}}
+	service := &ServiceImpl{Storage: stubStorage{users: repository}, MaxBatchSize: 3}
+
+	batch, err := service.GetUsersByIDs(context.Background(), &GetUsersByIDsParams{
+		UserIDs: []model.UserID{"1", "3", "2", "1", "3"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]model.UserID{{"1", "3", "2"}}, repository.queries)
+	assert.Len(t, batch.Users, 2)
+	assert.Equal(t, []model.UserID{"3"}, batch.Missing)
+
+	_, err = service.GetUsersByIDs(context.Background(), &GetUsersByIDsParams{
+		UserIDs: []model.UserID{"1", "2", "3", "4"},
+	})
+	result, ok := utils.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+}
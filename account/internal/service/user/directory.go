@@ -0,0 +1,132 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"pulse-auth/internal/directory"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"time"
+)
+
+// directoryProvider is the identity provider name of directory accounts in identity_table.
+const directoryProvider = "ldap"
+
+// provisionAttempts bounds how often a shadow account is retried after a concurrent login took its username.
+const provisionAttempts = 3
+
+var (
+	errDirectoryDisabled    = errors.New("directory authentication is disabled")
+	errDirectoryUnavailable = errors.New("directory is unavailable")
+)
+
+// loginWithDirectory binds as the user and returns the local shadow account, creating it on first login.
+// Roles are synchronized from the directory groups on every login.
+func (s *ServiceImpl) loginWithDirectory(ctx context.Context, params *LoginParams) (*model.User, error) {
+	if s.Directory == nil {
+		return nil, errDirectoryDisabled
+	}
+
+	entry, err := s.Directory.Authenticate(ctx, params.Username, params.Password)
+	switch {
+	case errors.Is(err, directory.ErrUserNotFound):
+		return nil, err
+	case errors.Is(err, directory.ErrInvalidCredentials):
+		return nil, utils.WrapUnauthorizedError(err, utils.UnauthorizedMessage)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", errDirectoryUnavailable, err)
+	}
+
+	user, err := s.directoryUser(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Storage.User().SetRoles(ctx, user.UserID, entry.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("set roles: %w", err)
+	}
+	user.Roles = entry.Roles
+
+	return user, nil
+}
+
+// directoryUser returns the shadow account of the directory entry, creating it on first login.
+func (s *ServiceImpl) directoryUser(ctx context.Context, entry *directory.Entry) (*model.User, error) {
+	var err error
+	for attempt := 0; attempt < provisionAttempts; attempt++ {
+		var user *model.User
+		user, err = s.provisionDirectoryUser(ctx, entry)
+		if err == nil {
+			return user, nil
+		}
+		// Either another account took the username or the same entry logged in twice at once, the next attempt
+		// picks another username or finds the identity.
+		if result, ok := utils.FromError(err); !ok || result.StatusCode != http.StatusConflict {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+func (s *ServiceImpl) provisionDirectoryUser(ctx context.Context, entry *directory.Entry) (*model.User, error) {
+	identity, err := s.Storage.Identity().GetIdentity(ctx, directoryProvider, entry.Subject)
+	if err == nil {
+		user, err := s.Storage.User().GetUserByID(ctx, identity.UserID)
+		if utils.IsNotFoundError(err) {
+			// Shadow accounts have no password, a directory login within the grace period restores them.
+			user, err = s.Storage.User().RestoreUserByID(ctx, identity.UserID, time.Now().Add(-s.DeletionGracePeriod), model.DeletedByUser)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get user by id: %w", err)
+		}
+		return user, nil
+	}
+	if !utils.IsNotFoundError(err) {
+		return nil, fmt.Errorf("get identity: %w", err)
+	}
+
+	var user *model.User
+	err = s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		// The directory username may be taken locally or reserved, the shadow account then gets a free variant.
+		name, err := s.Usernames.Allocate(ctx, tx.User(), entry.Username)
+		if err != nil {
+			return fmt.Errorf("allocate username: %w", err)
+		}
+
+		// The shadow account has no local password, the directory stays the source of truth.
+		user, err = tx.User().CreateUser(ctx, &model.UserRegister{
+			ID:         utils.GenerateUUID(),
+			Username:   name,
+			FirstName:  entry.FirstName,
+			SecondName: entry.SecondName,
+			City:       entry.City,
+		})
+		if err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+
+		_, err = tx.Identity().CreateIdentity(ctx, &model.Identity{
+			IdentityID: model.IdentityID(utils.GenerateUUID()),
+			UserID:     user.UserID,
+			Provider:   directoryProvider,
+			Subject:    entry.Subject,
+		})
+		if err != nil {
+			return fmt.Errorf("create identity: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Logger.Sugar().Infof("created shadow user %s for directory entry %s", user.UserID, entry.Subject)
+
+	return user, nil
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/directory"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/token"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubDirectory knows one password per username and fails every login with err when it is set.
+type stubDirectory struct {
+	entries   map[string]*directory.Entry
+	passwords map[string]string
+	err       error
+}
+
+func (d *stubDirectory) Authenticate(_ context.Context, username, password string) (*directory.Entry, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	entry, ok := d.entries[username]
+	if !ok {
+		return nil, directory.ErrUserNotFound
+	}
+	if d.passwords[username] != password {
+		return nil, directory.ErrInvalidCredentials
+	}
+
+	return entry, nil
+}
+
+func TestLoginWithDirectory(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	dir := &stubDirectory{
+		entries: map[string]*directory.Entry{
+			"john": {Subject: "uid=john", Username: "john", Roles: []string{"admin"}},
+		},
+		passwords: map[string]string{"john": "directory"},
+	}
+	service := &ServiceImpl{
+		Storage:        store,
+		TokenGenerator: token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		Directory:      dir,
+		Logger:         zap.NewNop(),
+	}
+
+	// A local account already has the directory username, the shadow account gets a free variant.
+	_, err := service.Register(ctx, &RegisterParams{Username: "John", Password: "local"})
+	require.NoError(t, err)
+
+	loggedIn, err := service.Login(ctx, &LoginParams{Username: "john", Password: "directory"})
+	require.NoError(t, err)
+	shadow, err := store.User().GetUserByID(ctx, loggedIn.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, "john2", shadow.Username)
+	assert.False(t, shadow.HasPassword)
+
+	again, err := service.Login(ctx, &LoginParams{Username: "john", Password: "directory"})
+	require.NoError(t, err)
+	assert.Equal(t, loggedIn.UserID, again.UserID)
+
+	_, err = service.Login(ctx, &LoginParams{Username: "john", Password: "wrong"})
+	assertStatus(t, http.StatusUnauthorized, err)
+
+	// Local accounts keep signing in while the directory is unreachable.
+	dir.err = errors.New("dial: connection refused")
+	local, err := service.Login(ctx, &LoginParams{Username: "John", Password: "local"})
+	require.NoError(t, err)
+	assert.NotEqual(t, model.UserID(""), local.UserID)
+	assert.NotEqual(t, loggedIn.UserID, local.UserID)
+
+	_, err = service.Login(ctx, &LoginParams{Username: "john", Password: "directory"})
+	assert.Error(t, err)
+}
+
+func TestLoginWithDirectoryRestoresDeletedAccount(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{
+		Storage:        store,
+		TokenGenerator: token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		Directory: &stubDirectory{
+			entries:   map[string]*directory.Entry{"john": {Subject: "uid=john", Username: "john"}},
+			passwords: map[string]string{"john": "directory"},
+		},
+		DeletionGracePeriod: time.Hour,
+		Logger:              zap.NewNop(),
+	}
+
+	loggedIn, err := service.Login(ctx, &LoginParams{Username: "john", Password: "directory"})
+	require.NoError(t, err)
+	require.NoError(t, store.User().DeleteUser(ctx, loggedIn.UserID, model.DeletedByUser))
+
+	restored, err := service.Login(ctx, &LoginParams{Username: "john", Password: "directory"})
+	require.NoError(t, err)
+	assert.Equal(t, loggedIn.UserID, restored.UserID)
+}
+
+func TestLoginWithDirectoryRefusesDeprovisionedAccount(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStorage()
+	service := &ServiceImpl{
+		Storage:        store,
+		TokenGenerator: token.NewGenerator(config.ApplicationConfig{SaltValue: "salt", App: "test"}),
+		Directory: &stubDirectory{
+			entries:   map[string]*directory.Entry{"john": {Subject: "uid=john", Username: "john"}},
+			passwords: map[string]string{"john": "directory"},
+		},
+		DeletionGracePeriod: time.Hour,
+		Logger:              zap.NewNop(),
+	}
+
+	loggedIn, err := service.Login(ctx, &LoginParams{Username: "john", Password: "directory"})
+	require.NoError(t, err)
+	require.NoError(t, store.User().DeleteUser(ctx, loggedIn.UserID, model.DeletedByProvisioning))
+
+	_, err = service.Login(ctx, &LoginParams{Username: "john", Password: "directory"})
+	assert.Error(t, err)
+	_, err = store.User().GetUserByID(ctx, loggedIn.UserID)
+	assert.True(t, utils.IsNotFoundError(err), err)
+}
@@ -0,0 +1,163 @@
+package user
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchUsersParams filters users by name prefixes, city, sex and age. Empty filters match everyone.
+type SearchUsersParams struct {
+	// Query searches fuzzily in names, username, city and biography and orders the users by relevance.
+	// Russian names match in Cyrillic and in Latin transliteration.
+	Query      string
+	FirstName  string
+	SecondName string
+	City       string
+	Sex        string
+	MinAge     *int
+	MaxAge     *int
+	// Cursor continues the search after the page that returned it.
+	Cursor string
+	Limit  uint64
+}
+
+type UserPage struct {
+	Users []*model.User
+	// Total counts the matches across all pages.
+	Total uint64
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+func (s *ServiceImpl) SearchUsers(ctx context.Context, params *SearchUsersParams) (*UserPage, error) {
+	search, err := searchUsersParamsToModel(params, time.Now())
+	if err != nil {
+		return nil, utils.WrapValidationError(err)
+	}
+
+	limit := search.Limit
+	// One extra user tells whether there is a next page.
+	search.Limit++
+	users, total, err := s.Storage.User().SearchUsers(ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("search users: %w", err)
+	}
+
+	page := &UserPage{Users: users, Total: total}
+	if uint64(len(users)) > limit {
+		page.Users = users[:limit]
+		next := &searchCursor{Offset: search.Offset + limit}
+		if len(search.Queries) == 0 {
+			last := page.Users[limit-1]
+			next = &searchCursor{After: &model.UserCursor{
+				SecondName: last.SecondName,
+				FirstName:  last.FirstName,
+				ID:         last.UserID,
+			}}
+		}
+		page.NextCursor, err = encodeCursor(next)
+		if err != nil {
+			return nil, fmt.Errorf("encode cursor: %w", err)
+		}
+	}
+
+	return page, nil
+}
+
+func searchUsersParamsToModel(params *SearchUsersParams, now time.Time) (*model.UserSearchParams, error) {
+	search := &model.UserSearchParams{
+		FirstName:  params.FirstName,
+		SecondName: params.SecondName,
+		City:       params.City,
+		Sex:        params.Sex,
+		Limit:      params.Limit,
+	}
+
+	if query := strings.TrimSpace(params.Query); query != "" {
+		search.Queries = utils.Transliterations(query)
+	}
+
+	switch params.Sex {
+	case "", model.SexMale, model.SexFemale, model.SexOther:
+	default:
+		return nil, fmt.Errorf("unknown sex: %s", params.Sex)
+	}
+
+	if params.MinAge != nil {
+		if *params.MinAge < 0 {
+			return nil, fmt.Errorf("negative min age: %d", *params.MinAge)
+		}
+		search.BornBefore = now.AddDate(-*params.MinAge, 0, 0)
+	}
+	if params.MaxAge != nil {
+		if *params.MaxAge < 0 || params.MinAge != nil && *params.MaxAge < *params.MinAge {
+			return nil, fmt.Errorf("incorrect max age: %d", *params.MaxAge)
+		}
+		// Somebody is still MaxAge until the day before their next birthday.
+		search.BornAfter = now.AddDate(-*params.MaxAge-1, 0, 0)
+	}
+
+	switch {
+	case search.Limit == 0:
+		search.Limit = defaultSearchLimit
+	case search.Limit > maxSearchLimit:
+		search.Limit = maxSearchLimit
+	}
+
+	if params.Cursor != "" {
+		cursor, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("decode cursor: %w", err)
+		}
+		ranked := len(search.Queries) > 0
+		if ranked == (cursor.After != nil) {
+			return nil, fmt.Errorf("cursor of another search mode")
+		}
+		search.After, search.Offset = cursor.After, cursor.Offset
+	}
+
+	return search, nil
+}
+
+// searchCursor continues a search either after a user in the name order, or at an offset of results ranked by relevance.
+type searchCursor struct {
+	After  *model.UserCursor `json:"a,omitempty"`
+	Offset uint64            `json:"o,omitempty"`
+}
+
+func encodeCursor(cursor *searchCursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+func decodeCursor(encoded string) (*searchCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	var cursor searchCursor
+	if err = json.Unmarshal(payload, &cursor); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if cursor.After != nil && cursor.After.ID == "" {
+		return nil, fmt.Errorf("cursor without id")
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,73 @@
+package user
+
+import (
+	"pulse-auth/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchUsersParamsToModel(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	minAge, maxAge := 18, 30
+
+	search, err := searchUsersParamsToModel(&SearchUsersParams{
+		FirstName: "Nik",
+		Sex:       model.SexMale,
+		MinAge:    &minAge,
+		MaxAge:    &maxAge,
+	}, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2006, time.June, 10, 12, 0, 0, 0, time.UTC), search.BornBefore)
+	assert.Equal(t, time.Date(1993, time.June, 10, 12, 0, 0, 0, time.UTC), search.BornAfter)
+	assert.Equal(t, uint64(defaultSearchLimit), search.Limit)
+	assert.Nil(t, search.After)
+
+	search, err = searchUsersParamsToModel(&SearchUsersParams{Limit: 1000}, now)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(maxSearchLimit), search.Limit)
+	assert.True(t, search.BornAfter.IsZero())
+	assert.True(t, search.BornBefore.IsZero())
+}
+
+func TestSearchUsersParamsToModelRejectsIncorrectFilters(t *testing.T) {
+	now := time.Now()
+	minAge, maxAge, negative := 30, 18, -1
+
+	for name, params := range map[string]*SearchUsersParams{
+		"unknown sex":          {Sex: "robot"},
+		"negative min age":     {MinAge: &negative},
+		"max age below min":    {MinAge: &minAge, MaxAge: &maxAge},
+		"malformed cursor":     {Cursor: "not a cursor"},
+		"cursor without an id": {Cursor: "eyJhIjp7fX0"},
+		"name cursor in fuzzy": {Query: "nikita", Cursor: "eyJhIjp7ImkiOiI0MiJ9fQ"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := searchUsersParamsToModel(params, now)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSearchUsersParamsToModelFuzzy(t *testing.T) {
+	search, err := searchUsersParamsToModel(&SearchUsersParams{Query: " Никита ", Cursor: "eyJvIjo0MH0"}, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"никита", "nikita"}, search.Queries)
+	assert.Equal(t, uint64(40), search.Offset)
+	assert.Nil(t, search.After)
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := &searchCursor{After: &model.UserCursor{SecondName: "Иванов", FirstName: "Никита", ID: "42"}}
+
+	encoded, err := encodeCursor(cursor)
+	require.NoError(t, err)
+
+	decoded, err := decodeCursor(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+}
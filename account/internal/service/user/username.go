@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"time"
+)
+
+type ChangeUsernameParams struct {
+	UserID   model.UserID
+	Username string
+}
+
+// ChangeUsername renames the user. The old username stays reserved for the user for UsernameReservation,
+// and the next change is only allowed after UsernameChangeCooldown.
+func (s *ServiceImpl) ChangeUsername(ctx context.Context, params *ChangeUsernameParams) (*model.User, error) {
+	// The cooldown and availability checks must see the latest renames.
+	ctx = storage.WithPrimary(ctx)
+	update := &model.UserUpdate{ID: params.UserID, Username: &params.Username}
+	if err := update.Validate(); err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+
+	user, err := s.Storage.User().GetUserByID(ctx, params.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+	if user.Username == params.Username {
+		return user, nil
+	}
+
+	history, err := s.Storage.User().ListUsernameHistory(ctx, params.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("list username history: %w", err)
+	}
+	if len(history) > 0 && time.Since(history[0].ChangedAt) < s.UsernameChangeCooldown {
+		return nil, utils.WrapError(fmt.Errorf("username changed at %s", history[0].ChangedAt),
+			utils.UsernameCooldownMessage, http.StatusTooManyRequests)
+	}
+
+	if err = s.Usernames.CheckAvailable(ctx, s.Storage.User(), params.UserID, params.Username); err != nil {
+		return nil, err
+	}
+
+	user, err = s.Storage.User().ChangeUsername(ctx, params.UserID, params.Username, time.Now().Add(s.UsernameReservation))
+	if err != nil {
+		return nil, fmt.Errorf("change username: %w", err)
+	}
+
+	return user, nil
+}
+
+type GetUserByUsernameParams struct {
+	Username string
+}
+
+// GetUserByUsername finds the user by the current username or, failing that, by the latest user who had it before
+// as long as it is still reserved for them. Once the reservation has passed the name may belong to somebody else.
+func (s *ServiceImpl) GetUserByUsername(ctx context.Context, params *GetUserByUsernameParams) (*model.User, error) {
+	user, err := s.Storage.User().GetUserByUsername(ctx, params.Username)
+	if err == nil {
+		return user, nil
+	}
+	if !utils.IsNotFoundError(err) {
+		return nil, fmt.Errorf("get user by username: %w", err)
+	}
+
+	record, err := s.Storage.User().GetUsernameRecord(ctx, params.Username)
+	if err != nil {
+		return nil, fmt.Errorf("get username record: %w", err)
+	}
+	if !time.Now().Before(record.ReservedUntil) {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("username reservation expired at %s", record.ReservedUntil), utils.NotFoundMessage)
+	}
+
+	user, err = s.Storage.User().GetUserByID(ctx, record.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,119 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (r *stubUserRepository) GetUserByID(_ context.Context, id model.UserID) (*model.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, utils.WrapNotFoundError(errors.New("no user"), utils.NotFoundMessage)
+}
+
+func (r *stubUserRepository) GetUserByUsername(_ context.Context, username string) (*model.User, error) {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+	return nil, utils.WrapNotFoundError(errors.New("no user"), utils.NotFoundMessage)
+}
+
+func (r *stubUserRepository) ListUsernameHistory(_ context.Context, id model.UserID) ([]*model.UsernameRecord, error) {
+	var history []*model.UsernameRecord
+	for _, record := range r.history {
+		if record.UserID == id {
+			history = append(history, record)
+		}
+	}
+	return history, nil
+}
+
+func (r *stubUserRepository) GetUsernameRecord(_ context.Context, username string) (*model.UsernameRecord, error) {
+	for _, record := range r.history {
+		if strings.EqualFold(record.Username, username) {
+			return record, nil
+		}
+	}
+	return nil, utils.WrapNotFoundError(errors.New("no username record"), utils.NotFoundMessage)
+}
+
+func (r *stubUserRepository) ChangeUsername(_ context.Context, id model.UserID, username string, reservedUntil time.Time) (*model.User, error) {
+	user := r.users[id]
+	r.history = append([]*model.UsernameRecord{{
+		UserID:        id,
+		Username:      user.Username,
+		ChangedAt:     time.Now(),
+		ReservedUntil: reservedUntil,
+	}}, r.history...)
+	user.Username = username
+	return user, nil
+}
+
+func TestChangeUsername(t *testing.T) {
+	repository := &stubUserRepository{users: map[model.UserID]*model.User{
+		"1": {UserID: "1", Username: "alice"},
+		"2": {UserID: "2", Username: "bob"},
+	}}
+	service := &ServiceImpl{
+		Storage:                stubStorage{users: repository},
+		UsernameChangeCooldown: time.Hour,
+		UsernameReservation:    24 * time.Hour,
+	}
+	ctx := context.Background()
+
+	statusOf := func(err error) int {
+		result, ok := utils.FromError(err)
+		require.True(t, ok, err)
+		return result.StatusCode
+	}
+
+	_, err := service.ChangeUsername(ctx, &ChangeUsernameParams{UserID: "1", Username: "Bob"})
+	assert.Equal(t, http.StatusConflict, statusOf(err))
+
+	user, err := service.ChangeUsername(ctx, &ChangeUsernameParams{UserID: "1", Username: "alicia"})
+	require.NoError(t, err)
+	assert.Equal(t, "alicia", user.Username)
+
+	_, err = service.ChangeUsername(ctx, &ChangeUsernameParams{UserID: "1", Username: "alison"})
+	assert.Equal(t, http.StatusTooManyRequests, statusOf(err))
+
+	_, err = service.ChangeUsername(ctx, &ChangeUsernameParams{UserID: "2", Username: "alice"})
+	assert.Equal(t, http.StatusConflict, statusOf(err))
+
+	user, err = service.GetUserByUsername(ctx, &GetUserByUsernameParams{Username: "ALICE"})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserID("1"), user.UserID)
+}
+
+func TestGetUserByUsernameAfterReservation(t *testing.T) {
+	repository := &stubUserRepository{
+		users: map[model.UserID]*model.User{"1": {UserID: "1", Username: "alicia"}},
+		history: []*model.UsernameRecord{{
+			UserID:        "1",
+			Username:      "alice",
+			ChangedAt:     time.Now().Add(-48 * time.Hour),
+			ReservedUntil: time.Now().Add(-24 * time.Hour),
+		}},
+	}
+	service := &ServiceImpl{Storage: stubStorage{users: repository}}
+
+	_, err := service.GetUserByUsername(context.Background(), &GetUserByUsernameParams{Username: "alice"})
+	assert.True(t, utils.IsNotFoundError(err), err)
+
+	repository.history[0].ReservedUntil = time.Now().Add(time.Hour)
+	user, err := service.GetUserByUsername(context.Background(), &GetUserByUsernameParams{Username: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserID("1"), user.UserID)
+}
@@ -0,0 +1,127 @@
+// Package username holds the username rules the services share: registration, renames, SCIM and the accounts
+// created on the first external sign in.
+package username
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxLength is the longest username registration accepts.
+	maxLength = 64
+	// maxNumbered is how many numbered variants of a candidate Allocate tries, john2 up to john100.
+	maxNumbered = 100
+	// fallback replaces candidates that are not valid usernames at all.
+	fallback = "user"
+)
+
+// Checker knows the usernames nobody may take. The zero value reserves none.
+type Checker struct {
+	// reserved holds the reserved usernames in canonical form.
+	reserved map[string]struct{}
+}
+
+// NewChecker reserves the given usernames. They are compared in canonical form, so reserving "admin" also
+// reserves "Admin" and "ａｄｍｉｎ".
+func NewChecker(reserved []string) (Checker, error) {
+	names := make(map[string]struct{}, len(reserved))
+	for _, name := range reserved {
+		canonical, err := model.NormalizeUsername(name)
+		if err != nil {
+			return Checker{}, fmt.Errorf("reserved username %q: %w", name, err)
+		}
+		names[canonical] = struct{}{}
+	}
+
+	return Checker{reserved: names}, nil
+}
+
+// Reserved reports whether the username is reserved. Usernames that can't be normalized are not.
+func (c Checker) Reserved(username string) bool {
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return false
+	}
+
+	_, reserved := c.reserved[canonical]
+	return reserved
+}
+
+// CheckAvailable fails with a validation error for reserved usernames and with a conflict when another user has
+// the username or keeps it reserved after a rename. An empty userID checks for a new user. A user taking the name
+// concurrently is caught by the unique index on the canonical username, which storage reports as a conflict too.
+func (c Checker) CheckAvailable(ctx context.Context, users storage.UserRepository, userID model.UserID, username string) error {
+	if c.Reserved(username) {
+		return utils.WrapValidationError(fmt.Errorf("Username: is reserved"))
+	}
+
+	owner, err := users.GetUserByUsername(ctx, username)
+	switch {
+	case err == nil && owner.UserID != userID:
+		return utils.WrapError(fmt.Errorf("username is taken"), utils.ConflictMessage, http.StatusConflict)
+	case err != nil && !utils.IsNotFoundError(err):
+		return fmt.Errorf("get user by username: %w", err)
+	}
+
+	record, err := users.GetUsernameRecord(ctx, username)
+	switch {
+	case err == nil && record.UserID != userID && time.Now().Before(record.ReservedUntil):
+		return utils.WrapError(fmt.Errorf("username is reserved until %s", record.ReservedUntil), utils.ConflictMessage, http.StatusConflict)
+	case err != nil && !utils.IsNotFoundError(err):
+		return fmt.Errorf("get username record: %w", err)
+	}
+
+	return nil
+}
+
+// Allocate picks the username of an account created on the first external sign in from the candidate the identity
+// provider suggested. Whitespace becomes underscores, and a candidate that still isn't a valid username is replaced
+// by "user". When the name is reserved or taken, the lowest free number is appended to it. Concurrent sign ins may
+// still pick the same name, the unique index lets one of them fail with a conflict to retry.
+func (c Checker) Allocate(ctx context.Context, users storage.UserRepository, candidate string) (string, error) {
+	base := strings.Join(strings.Fields(candidate), "_")
+	if model.ValidateUsername(base) != nil {
+		base = fallback
+	}
+
+	for number := 1; number <= maxNumbered; number++ {
+		suffix := ""
+		if number > 1 {
+			suffix = strconv.Itoa(number)
+		}
+		name := truncate(base, maxLength-len(suffix)) + suffix
+
+		err := c.CheckAvailable(ctx, users, "", name)
+		if err == nil {
+			return name, nil
+		}
+		if result, ok := utils.FromError(err); !ok || result.StatusCode == http.StatusInternalServerError {
+			return "", err
+		}
+	}
+
+	return "", utils.WrapError(fmt.Errorf("no free username for %q", candidate), utils.ConflictMessage, http.StatusConflict)
+}
+
+// truncate shortens the username to at most length bytes without splitting a character.
+func truncate(username string, length int) string {
+	if len(username) <= length {
+		return username
+	}
+
+	username = username[:length]
+	for !utf8.ValidString(username) {
+		username = username[:len(username)-1]
+	}
+
+	return username
+}
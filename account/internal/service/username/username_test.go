@@ -0,0 +1,89 @@
+package username
+
+import (
+	"context"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserved(t *testing.T) {
+	checker, err := NewChecker([]string{"admin", "Support"})
+	require.NoError(t, err)
+
+	assert.True(t, checker.Reserved("admin"))
+	assert.True(t, checker.Reserved("ADMIN"))
+	assert.True(t, checker.Reserved("ｓｕｐｐｏｒｔ"))
+	assert.False(t, checker.Reserved("administrator"))
+	assert.False(t, Checker{}.Reserved("admin"))
+
+	_, err = NewChecker([]string{"bad name"})
+	assert.Error(t, err)
+}
+
+func TestCheckAvailable(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewStorage().User()
+	checker, err := NewChecker([]string{"admin"})
+	require.NoError(t, err)
+
+	statusOf := func(err error) int {
+		result, ok := utils.FromError(err)
+		require.True(t, ok, err)
+		return result.StatusCode
+	}
+
+	alice, err := users.CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "alice"})
+	require.NoError(t, err)
+	_, err = users.ChangeUsername(ctx, alice.UserID, "alicia", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, statusOf(checker.CheckAvailable(ctx, users, "", "Admin")))
+	assert.Equal(t, http.StatusConflict, statusOf(checker.CheckAvailable(ctx, users, "", "ALICIA")))
+	assert.Equal(t, http.StatusConflict, statusOf(checker.CheckAvailable(ctx, users, "", "alice")))
+	assert.NoError(t, checker.CheckAvailable(ctx, users, alice.UserID, "alice"))
+	assert.NoError(t, checker.CheckAvailable(ctx, users, alice.UserID, "alicia"))
+	assert.NoError(t, checker.CheckAvailable(ctx, users, "", "bob"))
+}
+
+func TestAllocate(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewStorage().User()
+	checker, err := NewChecker([]string{"admin"})
+	require.NoError(t, err)
+
+	for _, name := range []string{"john", "john2"} {
+		_, err = users.CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: name})
+		require.NoError(t, err)
+	}
+
+	name, err := checker.Allocate(ctx, users, "John")
+	require.NoError(t, err)
+	assert.Equal(t, "John3", name)
+
+	name, err = checker.Allocate(ctx, users, "Admin")
+	require.NoError(t, err)
+	assert.Equal(t, "Admin2", name)
+
+	name, err = checker.Allocate(ctx, users, "John  Smith")
+	require.NoError(t, err)
+	assert.Equal(t, "John_Smith", name)
+
+	name, err = checker.Allocate(ctx, users, "")
+	require.NoError(t, err)
+	assert.Equal(t, "user", name)
+
+	long := strings.Repeat("a", 70)
+	_, err = users.CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: long[:64]})
+	require.NoError(t, err)
+	name, err = checker.Allocate(ctx, users, long)
+	require.NoError(t, err)
+	assert.Equal(t, long[:63]+"2", name)
+}
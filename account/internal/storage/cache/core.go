@@ -0,0 +1,163 @@
+// Package cache keeps users and active tokens in memory in front of another storage. Writes through the cache
+// invalidate the entries they change here and, through a notifier, on every other instance.
+package cache
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"strings"
+)
+
+// Channel is the notification channel the instances exchange invalidations on.
+const Channel = "pulse_cache"
+
+// Invalidation messages. The user and token ones are followed by an id.
+const (
+	messageUser       = "user:"
+	messageToken      = "token:"
+	messageUserTokens = "user-tokens:"
+	messageAll        = "all"
+)
+
+// Notifier broadcasts a payload to the listeners of the channel on every instance.
+type Notifier interface {
+	Notify(ctx context.Context, channel, payload string) error
+}
+
+type Storage struct {
+	next  storage.Storage
+	users *lru[model.UserID, model.User]
+	// tokens are keyed by id, so that invalidating one is a single removal, and tokenIDs finds the id of a token.
+	// The ids aren't invalidated, a token keeps its id.
+	tokens   *lru[model.TokenID, model.Token]
+	tokenIDs *lru[string, model.TokenID]
+	notifier Notifier
+	logger   *zap.Logger
+	// pending collects the invalidations of a transaction until it commits, it is nil outside transactions.
+	pending *[]string
+}
+
+// NewStorage caches the reads of next. Without a notifier the invalidations stay local, which is enough for
+// a single instance.
+func NewStorage(next storage.Storage, notifier Notifier, cfg config.CacheConfig, logger *zap.Logger) *Storage {
+	return &Storage{
+		next:     next,
+		users:    newLRU[model.UserID, model.User](cfg.Size, cfg.TTL),
+		tokens:   newLRU[model.TokenID, model.Token](cfg.Size, cfg.TTL),
+		tokenIDs: newLRU[string, model.TokenID](cfg.Size, cfg.TTL),
+		notifier: notifier,
+		logger:   logger,
+	}
+}
+
+func (s *Storage) User() storage.UserRepository {
+	return &userRepository{UserRepository: s.next.User(), s: s}
+}
+
+func (s *Storage) Token() storage.TokenRepository {
+	return &tokenRepository{TokenRepository: s.next.Token(), s: s}
+}
+
+func (s *Storage) Consent() storage.ConsentRepository {
+	return s.next.Consent()
+}
+
+func (s *Storage) Identity() storage.IdentityRepository {
+	return s.next.Identity()
+}
+
+func (s *Storage) Export() storage.ExportRepository {
+	return s.next.Export()
+}
+
+// WithTx runs fn in a transaction of the next storage. Reads in the transaction bypass the cache, and the
+// invalidations of its writes are applied once it commits.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	pending := s.pending
+	if pending == nil {
+		pending = new([]string)
+	}
+
+	err := s.next.WithTx(ctx, func(tx storage.Storage) error {
+		return fn(&Storage{
+			next:     tx,
+			users:    s.users,
+			tokens:   s.tokens,
+			tokenIDs: s.tokenIDs,
+			notifier: s.notifier,
+			logger:   s.logger,
+			pending:  pending,
+		})
+	})
+	if err != nil || s.pending != nil {
+		return err
+	}
+
+	for _, message := range *pending {
+		s.invalidate(ctx, message)
+	}
+
+	return nil
+}
+
+// Stats returns the counters of the user and token caches.
+func (s *Storage) Stats() Stats {
+	return Stats{
+		Users:  s.users.stats(),
+		Tokens: s.tokens.stats(),
+	}
+}
+
+// Subscribed drops every entry, the invalidations sent while the instance wasn't listening are lost.
+func (s *Storage) Subscribed() {
+	s.apply(messageAll)
+}
+
+// Notified applies an invalidation broadcast by an instance, this one included.
+func (s *Storage) Notified(payload string) {
+	s.apply(payload)
+}
+
+// cached reports whether reads of ctx may be served from the cache. Transactions and flows that must see their
+// own writes read the storage.
+func (s *Storage) cached(ctx context.Context) bool {
+	return s.pending == nil && !storage.UsesPrimary(ctx)
+}
+
+// invalidate applies the message and broadcasts it to the other instances, or defers both until the transaction
+// commits. A failed broadcast is only logged: the write succeeded, and the entries expire with their TTL.
+func (s *Storage) invalidate(ctx context.Context, message string) {
+	if s.pending != nil {
+		*s.pending = append(*s.pending, message)
+		return
+	}
+
+	s.apply(message)
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.Notify(ctx, Channel, message); err != nil {
+		s.logger.Sugar().Warnf("broadcast cache invalidation %q: %v", message, err)
+	}
+}
+
+func (s *Storage) apply(message string) {
+	switch {
+	case message == messageAll:
+		s.users.purge()
+		s.tokens.purge()
+		s.tokenIDs.purge()
+	case strings.HasPrefix(message, messageUser):
+		s.users.remove(model.UserID(strings.TrimPrefix(message, messageUser)))
+	case strings.HasPrefix(message, messageToken):
+		s.tokens.remove(model.TokenID(strings.TrimPrefix(message, messageToken)))
+	case strings.HasPrefix(message, messageUserTokens):
+		id := model.UserID(strings.TrimPrefix(message, messageUserTokens))
+		s.tokens.removeFunc(func(token model.Token) bool { return token.UserID == id })
+	default:
+		s.logger.Sugar().Warnf("unknown cache invalidation %q", message)
+	}
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// Stats are the counters of the caches.
+type Stats struct {
+	Users  Counters `json:"users"`
+	Tokens Counters `json:"tokens"`
+}
+
+type Counters struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Size   int    `json:"size"`
+}
+
+// lru is a size-bounded cache whose entries expire after a TTL, counting its hits and misses.
+type lru[K comparable, V any] struct {
+	entries *expirable.LRU[K, V]
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	// mu orders fills after the invalidations, generation counts the invalidations.
+	mu         sync.Mutex
+	generation uint64
+}
+
+func newLRU[K comparable, V any](size int, ttl time.Duration) *lru[K, V] {
+	return &lru[K, V]{entries: expirable.NewLRU[K, V](size, nil, ttl)}
+}
+
+func (c *lru[K, V]) get(key K) (V, bool) {
+	value, ok := c.entries.Get(key)
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+
+	return value, ok
+}
+
+// peek returns the value without counting a hit or a miss, for lookups that lead to the counted one.
+func (c *lru[K, V]) peek(key K) (V, bool) {
+	return c.entries.Peek(key)
+}
+
+// current returns the generation to pass to fill along with a value loaded afterwards.
+func (c *lru[K, V]) current() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// fill caches a value loaded since generation was current, unless an invalidation happened in between: the value
+// may have been read before the write the invalidation was for.
+func (c *lru[K, V]) fill(key K, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation == generation {
+		c.entries.Add(key, value)
+	}
+}
+
+func (c *lru[K, V]) remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries.Remove(key)
+}
+
+// evict drops the entry without counting an invalidation, so values loading meanwhile are still cached. It is for
+// entries that only went stale in ways that don't matter, like the last use of a token.
+func (c *lru[K, V]) evict(key K) {
+	c.entries.Remove(key)
+}
+
+// removeFunc removes the entries matching the predicate. It walks the whole cache, so it is meant for
+// invalidations that can't name the key.
+func (c *lru[K, V]) removeFunc(match func(value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, key := range c.entries.Keys() {
+		if value, ok := c.entries.Peek(key); ok && match(value) {
+			c.entries.Remove(key)
+		}
+	}
+}
+
+func (c *lru[K, V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries.Purge()
+}
+
+func (c *lru[K, V]) stats() Counters {
+	return Counters{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   c.entries.Len(),
+	}
+}
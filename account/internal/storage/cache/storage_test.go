@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/storage/memory"
+	"pulse-auth/internal/storage/storagetest"
+	"pulse-auth/internal/utils"
+	"testing"
+	"time"
+)
+
+var testConfig = config.CacheConfig{Size: 100, TTL: time.Minute}
+
+type notifier struct {
+	payloads []string
+}
+
+func (n *notifier) Notify(_ context.Context, channel, payload string) error {
+	if channel != Channel {
+		return errors.New("unexpected channel " + channel)
+	}
+	n.payloads = append(n.payloads, payload)
+	return nil
+}
+
+func TestContract(t *testing.T) {
+	storagetest.Run(t, NewStorage(memory.NewStorage(), nil, testConfig, zap.NewNop()))
+}
+
+func TestUserCache(t *testing.T) {
+	ctx := context.Background()
+	next := memory.NewStorage()
+	notifier := &notifier{}
+	s := NewStorage(next, notifier, testConfig, zap.NewNop())
+
+	user, err := s.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "cached"})
+	require.NoError(t, err)
+
+	for range 3 {
+		found, err := s.User().GetUserByID(ctx, user.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, "cached", found.Username)
+	}
+	_, err = s.User().GetUserByID(ctx, model.UserID(utils.GenerateUUID()))
+	assert.True(t, utils.IsNotFoundError(err))
+	assert.Equal(t, Counters{Hits: 2, Misses: 2, Size: 1}, s.Stats().Users)
+
+	// A change made by another instance is served stale until its notification arrives.
+	city := "Kazan"
+	_, err = next.User().UpdateUser(ctx, &model.UserUpdate{ID: user.UserID, City: &city})
+	require.NoError(t, err)
+	found, err := s.User().GetUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, found.City)
+
+	s.Notified(messageUser + user.UserID.String())
+	found, err = s.User().GetUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, city, found.City)
+
+	// Changes made here are invalidated right away and broadcast.
+	city = "Perm"
+	_, err = s.User().UpdateUser(ctx, &model.UserUpdate{ID: user.UserID, City: &city})
+	require.NoError(t, err)
+	found, err = s.User().GetUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, city, found.City)
+	assert.Equal(t, []string{messageUser + user.UserID.String()}, notifier.payloads)
+
+	// The cached user can't be changed through a returned copy.
+	found.City = "changed"
+	found, err = s.User().GetUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, city, found.City)
+
+	require.NoError(t, s.User().DeleteUser(ctx, user.UserID, model.DeletedByUser))
+	_, err = s.User().GetUserByID(ctx, user.UserID)
+	assert.True(t, utils.IsNotFoundError(err))
+}
+
+func TestTokenCache(t *testing.T) {
+	ctx := context.Background()
+	next := memory.NewStorage()
+	notifier := &notifier{}
+	s := NewStorage(next, notifier, testConfig, zap.NewNop())
+
+	user, err := s.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "tokens"})
+	require.NoError(t, err)
+	newToken := func() *model.Token {
+		token, err := s.Token().CreateToken(ctx, &model.TokenWithMetadata{
+			TokenID:  utils.GenerateUUID(),
+			UserID:   user.UserID,
+			Token:    utils.GenerateUUID(),
+			AlivedAt: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		return token
+	}
+	first, second := newToken(), newToken()
+
+	for _, token := range []*model.Token{first, second, first, second} {
+		_, err = s.Token().GetToken(ctx, token.Token)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, Counters{Hits: 2, Misses: 2, Size: 2}, s.Stats().Tokens)
+
+	// A revocation by another instance takes effect once its notification arrives.
+	require.NoError(t, next.Token().RevokeToken(ctx, first))
+	_, err = s.Token().GetToken(ctx, first.Token)
+	require.NoError(t, err)
+	s.Notified(messageToken + first.TokenID.String())
+	_, err = s.Token().GetToken(ctx, first.Token)
+	assert.True(t, utils.IsNotFoundError(err))
+
+	require.NoError(t, s.Token().RevokeUserTokens(ctx, user.UserID))
+	_, err = s.Token().GetToken(ctx, second.Token)
+	assert.True(t, utils.IsNotFoundError(err))
+	assert.Equal(t, []string{messageUserTokens + user.UserID.String()}, notifier.payloads)
+	assert.Zero(t, s.Stats().Tokens.Size)
+}
+
+func TestTouchToken(t *testing.T) {
+	ctx := context.Background()
+	notifier := &notifier{}
+	s := NewStorage(memory.NewStorage(), notifier, testConfig, zap.NewNop())
+
+	user, err := s.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "touched"})
+	require.NoError(t, err)
+	token, err := s.Token().CreateToken(ctx, &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   user.UserID,
+		Token:    utils.GenerateUUID(),
+		AlivedAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = s.Token().GetToken(ctx, token.Token)
+	require.NoError(t, err)
+
+	// The touch reloads the token here only, without a broadcast or failing the loads of other tokens.
+	generation := s.tokens.current()
+	require.NoError(t, s.Token().TouchToken(ctx, token.TokenID))
+	assert.Empty(t, notifier.payloads)
+	assert.Equal(t, generation, s.tokens.current())
+	assert.Zero(t, s.Stats().Tokens.Size)
+
+	_, err = s.Token().GetToken(ctx, token.Token)
+	require.NoError(t, err)
+	_, err = s.Token().GetToken(ctx, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, Counters{Hits: 1, Misses: 2, Size: 1}, s.Stats().Tokens)
+}
+
+func TestTransaction(t *testing.T) {
+	ctx := context.Background()
+	notifier := &notifier{}
+	s := NewStorage(memory.NewStorage(), notifier, testConfig, zap.NewNop())
+
+	user, err := s.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "tx"})
+	require.NoError(t, err)
+	_, err = s.User().GetUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+
+	failure := errors.New("failed")
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.User().DeleteUser(ctx, user.UserID, model.DeletedByUser); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Empty(t, notifier.payloads)
+	assert.Equal(t, 1, s.Stats().Users.Size)
+
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.User().DeleteUser(ctx, user.UserID, model.DeletedByUser); err != nil {
+			return err
+		}
+		// Reads in the transaction see its writes, not the cache.
+		_, err := tx.User().GetUserByID(ctx, user.UserID)
+		assert.True(t, utils.IsNotFoundError(err))
+		assert.Empty(t, notifier.payloads)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{messageUser + user.UserID.String()}, notifier.payloads)
+	_, err = s.User().GetUserByID(ctx, user.UserID)
+	assert.True(t, utils.IsNotFoundError(err))
+}
+
+func TestSubscribed(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage(memory.NewStorage(), nil, testConfig, zap.NewNop())
+
+	user, err := s.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "subscribed"})
+	require.NoError(t, err)
+	_, err = s.User().GetUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+
+	s.Subscribed()
+	assert.Zero(t, s.Stats().Users.Size)
+}
+
+// slowStorage holds GetUserByID after reading the user until released, and records whether it read the primary.
+type slowStorage struct {
+	storage.Storage
+	loaded  chan struct{}
+	release chan struct{}
+	primary bool
+}
+
+func (s *slowStorage) User() storage.UserRepository {
+	return &slowUserRepository{UserRepository: s.Storage.User(), s: s}
+}
+
+type slowUserRepository struct {
+	storage.UserRepository
+	s *slowStorage
+}
+
+func (r *slowUserRepository) GetUserByID(ctx context.Context, id model.UserID) (*model.User, error) {
+	r.s.primary = storage.UsesPrimary(ctx)
+	user, err := r.UserRepository.GetUserByID(ctx, id)
+	r.s.loaded <- struct{}{}
+	<-r.s.release
+	return user, err
+}
+
+func TestFillRacingInvalidation(t *testing.T) {
+	ctx := context.Background()
+	next := memory.NewStorage()
+	user, err := next.User().CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: "racing"})
+	require.NoError(t, err)
+
+	slow := &slowStorage{Storage: next, loaded: make(chan struct{}), release: make(chan struct{})}
+	s := NewStorage(slow, nil, testConfig, zap.NewNop())
+
+	filled := make(chan *model.User)
+	go func() {
+		found, err := s.User().GetUserByID(ctx, user.UserID)
+		assert.NoError(t, err)
+		filled <- found
+	}()
+
+	// The user changes after the miss read it but before the read is cached.
+	<-slow.loaded
+	city := "Kazan"
+	_, err = next.User().UpdateUser(ctx, &model.UserUpdate{ID: user.UserID, City: &city})
+	require.NoError(t, err)
+	s.Notified(messageUser + user.UserID.String())
+	close(slow.release)
+
+	stale := <-filled
+	assert.Empty(t, stale.City)
+	assert.True(t, slow.primary)
+	assert.Zero(t, s.Stats().Users.Size)
+
+	go func() { <-slow.loaded }()
+	found, err := s.User().GetUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, city, found.City)
+}
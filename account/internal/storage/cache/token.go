@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+)
+
+// tokenRepository caches GetToken, which every authenticated request calls. The writes that revoke a token or
+// change its fields invalidate it. A cached token may outlive its expiry by up to the TTL.
+type tokenRepository struct {
+	storage.TokenRepository
+	s *Storage
+}
+
+// GetToken returns the active token from the cache, loading it from the primary on a miss. Revoked and unknown
+// tokens aren't cached, and neither are tokens revoked while they load.
+func (r *tokenRepository) GetToken(ctx context.Context, token string) (*model.Token, error) {
+	if !r.s.cached(ctx) {
+		return r.TokenRepository.GetToken(ctx, token)
+	}
+	id, _ := r.s.tokenIDs.peek(token)
+	if cached, ok := r.s.tokens.get(id); ok && cached.Token == token {
+		return &cached, nil
+	}
+
+	generation := r.s.tokens.current()
+	loaded, err := r.TokenRepository.GetToken(storage.WithPrimary(ctx), token)
+	if err != nil {
+		return nil, err
+	}
+	r.s.tokenIDs.fill(token, loaded.TokenID, r.s.tokenIDs.current())
+	r.s.tokens.fill(loaded.TokenID, *loaded, generation)
+
+	return loaded, nil
+}
+
+func (r *tokenRepository) RevokeToken(ctx context.Context, token *model.Token) error {
+	if err := r.TokenRepository.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+	r.s.invalidate(ctx, messageToken+token.TokenID.String())
+
+	return nil
+}
+
+func (r *tokenRepository) ReauthenticateToken(ctx context.Context, id model.TokenID) error {
+	if err := r.TokenRepository.ReauthenticateToken(ctx, id); err != nil {
+		return err
+	}
+	r.s.invalidate(ctx, messageToken+id.String())
+
+	return nil
+}
+
+func (r *tokenRepository) RevokeClientTokens(ctx context.Context, userID model.UserID, clientID model.ClientID) error {
+	if err := r.TokenRepository.RevokeClientTokens(ctx, userID, clientID); err != nil {
+		return err
+	}
+	r.s.invalidate(ctx, messageUserTokens+userID.String())
+
+	return nil
+}
+
+func (r *tokenRepository) RevokeUserTokens(ctx context.Context, userID model.UserID) error {
+	if err := r.TokenRepository.RevokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	r.s.invalidate(ctx, messageUserTokens+userID.String())
+
+	return nil
+}
+
+// TouchToken drops the token here, otherwise its stale last use would have it touched on every request. The touch
+// isn't broadcast: other instances touch the token at most once a minute themselves, and nothing else changed.
+func (r *tokenRepository) TouchToken(ctx context.Context, id model.TokenID) error {
+	if err := r.TokenRepository.TouchToken(ctx, id); err != nil {
+		return err
+	}
+	r.s.tokens.evict(id)
+
+	return nil
+}
+
+func (r *tokenRepository) RefreshToken(ctx context.Context, params *model.TokenWithMetadata) (*model.Token, error) {
+	token, err := r.TokenRepository.RefreshToken(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	r.s.invalidate(ctx, messageToken+token.TokenID.String())
+
+	return token, nil
+}
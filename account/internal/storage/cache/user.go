@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"time"
+)
+
+// userRepository caches GetUserByID. The other reads go to the next storage, and every write that changes
+// a user invalidates it. A new write method has to be added here to keep the cache consistent.
+type userRepository struct {
+	storage.UserRepository
+	s *Storage
+}
+
+// GetUserByID returns the user from the cache, loading it from the primary on a miss. Unknown users aren't cached.
+func (r *userRepository) GetUserByID(ctx context.Context, id model.UserID) (*model.User, error) {
+	if !r.s.cached(ctx) {
+		return r.UserRepository.GetUserByID(ctx, id)
+	}
+	if user, ok := r.s.users.get(id); ok {
+		return &user, nil
+	}
+
+	// A replica may lag behind the write that invalidated the entry. And when another write invalidates it while it
+	// loads, the loaded user may predate that write and is not cached.
+	generation := r.s.users.current()
+	user, err := r.UserRepository.GetUserByID(storage.WithPrimary(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	r.s.users.fill(id, *user, generation)
+
+	return user, nil
+}
+
+func (r *userRepository) UpdateUser(ctx context.Context, update *model.UserUpdate) (*model.User, error) {
+	user, err := r.UserRepository.UpdateUser(ctx, update)
+	if err != nil {
+		return nil, err
+	}
+	r.s.invalidate(ctx, messageUser+update.ID.String())
+
+	return user, nil
+}
+
+func (r *userRepository) ChangeUsername(ctx context.Context, id model.UserID, username string, reservedUntil time.Time) (*model.User, error) {
+	user, err := r.UserRepository.ChangeUsername(ctx, id, username, reservedUntil)
+	if err != nil {
+		return nil, err
+	}
+	r.s.invalidate(ctx, messageUser+id.String())
+
+	return user, nil
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id model.UserID, by model.DeletedBy) error {
+	if err := r.UserRepository.DeleteUser(ctx, id, by); err != nil {
+		return err
+	}
+	r.s.invalidate(ctx, messageUser+id.String())
+
+	return nil
+}
+
+func (r *userRepository) RestoreUser(ctx context.Context, userLogin *model.UserLogin, deletedAfter time.Time) (*model.User, error) {
+	user, err := r.UserRepository.RestoreUser(ctx, userLogin, deletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	r.s.invalidate(ctx, messageUser+user.UserID.String())
+
+	return user, nil
+}
+
+func (r *userRepository) RestoreUserByID(ctx context.Context, id model.UserID, deletedAfter time.Time, by model.DeletedBy) (*model.User, error) {
+	user, err := r.UserRepository.RestoreUserByID(ctx, id, deletedAfter, by)
+	if err != nil {
+		return nil, err
+	}
+	r.s.invalidate(ctx, messageUser+id.String())
+
+	return user, nil
+}
+
+// PurgeUsers drops every cached entry when accounts were purged, their ids aren't known here.
+func (r *userRepository) PurgeUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	purged, err := r.UserRepository.PurgeUsers(ctx, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	if purged > 0 {
+		r.s.invalidate(ctx, messageAll)
+	}
+
+	return purged, nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id model.UserID, hashedPassword string) error {
+	if err := r.UserRepository.UpdatePassword(ctx, id, hashedPassword); err != nil {
+		return err
+	}
+	r.s.invalidate(ctx, messageUser+id.String())
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"slices"
+	"time"
+)
+
+type consentKey struct {
+	userID   model.UserID
+	clientID model.ClientID
+}
+
+type consentRecord struct {
+	consent   model.Consent
+	deletedAt time.Time
+}
+
+func (r consentRecord) model() *model.Consent {
+	consent := r.consent
+	consent.Scopes = slices.Clone(consent.Scopes)
+	return &consent
+}
+
+// GetConsent returns the scopes the user has granted to the client.
+func (s *Storage) GetConsent(_ context.Context, userID model.UserID, clientID model.ClientID) (*model.Consent, error) {
+	defer s.read()()
+
+	record, ok := s.data.consents[consentKey{userID: userID, clientID: clientID}]
+	if !ok || !record.deletedAt.IsZero() {
+		return nil, errConsentNotFound()
+	}
+
+	return record.model(), nil
+}
+
+// ListConsents returns every client the user has granted access to.
+func (s *Storage) ListConsents(_ context.Context, userID model.UserID) ([]*model.Consent, error) {
+	defer s.read()()
+
+	var records []consentRecord
+	for key, record := range s.data.consents {
+		if key.userID == userID && record.deletedAt.IsZero() {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b consentRecord) int {
+		return cmp.Or(a.consent.CreatedAt.Compare(b.consent.CreatedAt), cmp.Compare(a.consent.ClientID, b.consent.ClientID))
+	})
+
+	consents := make([]*model.Consent, 0, len(records))
+	for _, record := range records {
+		consents = append(consents, record.model())
+	}
+
+	return consents, nil
+}
+
+// GrantConsent stores the scopes granted to the client, replacing a previous or withdrawn consent.
+func (s *Storage) GrantConsent(_ context.Context, consent *model.Consent) (*model.Consent, error) {
+	defer s.write()()
+
+	key := consentKey{userID: consent.UserID, clientID: consent.ClientID}
+	updatedAt := now()
+	record, ok := s.data.consents[key]
+	if !ok {
+		record.consent = model.Consent{UserID: consent.UserID, ClientID: consent.ClientID, CreatedAt: updatedAt}
+	}
+	// Scopes go through the same format as the postgres column, so they come back sorted and without duplicates.
+	record.consent.Scopes = model.ParseScopes(model.FormatScopes(consent.Scopes))
+	record.consent.UpdatedAt = updatedAt
+	record.deletedAt = time.Time{}
+	s.data.consents[key] = record
+
+	return record.model(), nil
+}
+
+// RevokeConsent withdraws the consent.
+func (s *Storage) RevokeConsent(_ context.Context, userID model.UserID, clientID model.ClientID) error {
+	defer s.write()()
+
+	key := consentKey{userID: userID, clientID: clientID}
+	record, ok := s.data.consents[key]
+	if !ok || !record.deletedAt.IsZero() {
+		return errConsentNotFound()
+	}
+	record.deletedAt = now()
+	s.data.consents[key] = record
+
+	return nil
+}
+
+func errConsentNotFound() error {
+	return utils.WrapNotFoundError(fmt.Errorf("consent not found"), utils.NotFoundMessage)
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+)
+
+// Storage keeps everything in maps guarded by a single lock. It follows the semantics of the postgres storage
+// and is meant for tests and local development, so the data is lost on restart.
+type Storage struct {
+	mu   *sync.RWMutex
+	data *data
+	// tx is set on the Storage passed to WithTx callbacks, which already hold the write lock.
+	tx bool
+}
+
+// data holds records by value, so copying the maps is enough to snapshot it. Slices in records are never
+// modified in place, only replaced.
+type data struct {
+	users      map[model.UserID]userRecord
+	usernames  []usernameRecord
+	roles      map[model.UserID][]string
+	tokens     map[model.TokenID]tokenRecord
+	consents   map[consentKey]consentRecord
+	identities map[model.IdentityID]identityRecord
+	exports    map[model.ExportID]exportRecord
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		mu: &sync.RWMutex{},
+		data: &data{
+			users:      map[model.UserID]userRecord{},
+			roles:      map[model.UserID][]string{},
+			tokens:     map[model.TokenID]tokenRecord{},
+			consents:   map[consentKey]consentRecord{},
+			identities: map[model.IdentityID]identityRecord{},
+			exports:    map[model.ExportID]exportRecord{},
+		},
+	}
+}
+
+func (s *Storage) User() storage.UserRepository {
+	return s
+}
+
+func (s *Storage) Token() storage.TokenRepository {
+	return s
+}
+
+func (s *Storage) Consent() storage.ConsentRepository {
+	return s
+}
+
+func (s *Storage) Identity() storage.IdentityRepository {
+	return s
+}
+
+func (s *Storage) Export() storage.ExportRepository {
+	return s
+}
+
+// WithTx holds the write lock while fn runs, so transactions are serialized, and restores a snapshot of the data
+// when fn fails. fn must use the Storage it is given, calling the outer one would wait for the lock forever.
+func (s *Storage) WithTx(_ context.Context, fn func(tx storage.Storage) error) error {
+	if s.tx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.data.clone()
+	if err := fn(&Storage{mu: s.mu, data: s.data, tx: true}); err != nil {
+		*s.data = snapshot
+		return err
+	}
+
+	return nil
+}
+
+// read locks the data for reading and returns the unlock function. Inside a transaction the lock is already held.
+func (s *Storage) read() func() {
+	if s.tx {
+		return func() {}
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// write locks the data for writing and returns the unlock function. Inside a transaction the lock is already held.
+func (s *Storage) write() func() {
+	if s.tx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (d *data) clone() data {
+	return data{
+		users:      maps.Clone(d.users),
+		usernames:  slices.Clone(d.usernames),
+		roles:      maps.Clone(d.roles),
+		tokens:     maps.Clone(d.tokens),
+		consents:   maps.Clone(d.consents),
+		identities: maps.Clone(d.identities),
+		exports:    maps.Clone(d.exports),
+	}
+}
+
+// now matches the precision timestamps are stored with in postgres.
+func now() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"slices"
+	"time"
+)
+
+type exportRecord struct {
+	export  model.Export
+	archive []byte
+}
+
+func (r exportRecord) model() *model.Export {
+	export := r.export
+	return &export
+}
+
+// CreateExport queues a new personal data export.
+func (s *Storage) CreateExport(_ context.Context, export *model.Export) (*model.Export, error) {
+	defer s.write()()
+
+	if _, ok := s.data.exports[export.ExportID]; ok {
+		return nil, errConflict(fmt.Errorf("export %s exists", export.ExportID))
+	}
+
+	createdAt := now()
+	record := exportRecord{export: model.Export{
+		ExportID:  export.ExportID,
+		UserID:    export.UserID,
+		Status:    model.ExportStatusPending,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}}
+	s.data.exports[export.ExportID] = record
+
+	return record.model(), nil
+}
+
+// GetExport returns the export without its archive.
+func (s *Storage) GetExport(_ context.Context, id model.ExportID) (*model.Export, error) {
+	defer s.read()()
+
+	record, ok := s.data.exports[id]
+	if !ok {
+		return nil, errExportNotFound()
+	}
+
+	return record.model(), nil
+}
+
+// ClaimExport marks the oldest pending export, or one left running since staleBefore, as running and returns it.
+func (s *Storage) ClaimExport(_ context.Context, staleBefore time.Time) (*model.Export, error) {
+	defer s.write()()
+
+	var claimed *exportRecord
+	for _, record := range s.data.exports {
+		export := record.export
+		if export.Status != model.ExportStatusPending &&
+			(export.Status != model.ExportStatusRunning || !export.UpdatedAt.Before(staleBefore)) {
+			continue
+		}
+		if claimed == nil || export.CreatedAt.Before(claimed.export.CreatedAt) ||
+			export.CreatedAt.Equal(claimed.export.CreatedAt) && export.ExportID < claimed.export.ExportID {
+			claimed = &record
+		}
+	}
+	if claimed == nil {
+		return nil, errExportNotFound()
+	}
+
+	claimed.export.Status = model.ExportStatusRunning
+	claimed.export.UpdatedAt = now()
+	s.data.exports[claimed.export.ExportID] = *claimed
+
+	return claimed.model(), nil
+}
+
+// CompleteExport stores the archive of a running export and makes it downloadable until expiresAt.
+func (s *Storage) CompleteExport(_ context.Context, id model.ExportID, archive []byte, expiresAt time.Time) error {
+	return s.finishExport(id, func(record *exportRecord) {
+		record.export.Status = model.ExportStatusReady
+		record.export.ExpiresAt = expiresAt
+		record.archive = slices.Clone(archive)
+	})
+}
+
+// FailExport records why a running export couldn't be built. The export is removed at expiresAt.
+func (s *Storage) FailExport(_ context.Context, id model.ExportID, reason string, expiresAt time.Time) error {
+	return s.finishExport(id, func(record *exportRecord) {
+		record.export.Status = model.ExportStatusFailed
+		record.export.Error = reason
+		record.export.ExpiresAt = expiresAt
+	})
+}
+
+func (s *Storage) finishExport(id model.ExportID, finish func(record *exportRecord)) error {
+	defer s.write()()
+
+	record, ok := s.data.exports[id]
+	if !ok || record.export.Status != model.ExportStatusRunning {
+		return utils.WrapNotFoundError(fmt.Errorf("running export not found"), utils.NotFoundMessage)
+	}
+	finish(&record)
+	record.export.UpdatedAt = now()
+	s.data.exports[id] = record
+
+	return nil
+}
+
+// GetExportArchive returns the archive of a ready export that hasn't expired.
+func (s *Storage) GetExportArchive(_ context.Context, id model.ExportID) ([]byte, error) {
+	defer s.read()()
+
+	record, ok := s.data.exports[id]
+	if !ok || record.export.Status != model.ExportStatusReady || !record.export.ExpiresAt.After(time.Now()) {
+		return nil, errExportNotFound()
+	}
+
+	return slices.Clone(record.archive), nil
+}
+
+// DeleteExpiredExports removes finished exports that expired before the given time.
+func (s *Storage) DeleteExpiredExports(_ context.Context, before time.Time) (int64, error) {
+	defer s.write()()
+
+	var deleted int64
+	for id, record := range s.data.exports {
+		if !record.export.ExpiresAt.IsZero() && record.export.ExpiresAt.Before(before) {
+			delete(s.data.exports, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func errExportNotFound() error {
+	return utils.WrapNotFoundError(fmt.Errorf("export not found"), utils.NotFoundMessage)
+}
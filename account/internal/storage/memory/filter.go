@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"cmp"
+	"fmt"
+	"pulse-auth/internal/model"
+	"strings"
+	"time"
+)
+
+// userFieldValue returns the value of the field a condition compares, and false for unknown fields.
+func userFieldValue(user model.User, field model.UserField) (string, bool) {
+	switch field {
+	case model.UserFieldID:
+		return user.UserID.String(), true
+	case model.UserFieldUsername:
+		return user.Username, true
+	case model.UserFieldFirstName:
+		return user.FirstName, true
+	case model.UserFieldSecondName:
+		return user.SecondName, true
+	case model.UserFieldCity:
+		return user.City, true
+	default:
+		return "", false
+	}
+}
+
+func validateUserCondition(condition model.UserCondition) error {
+	if _, ok := userFieldValue(model.User{}, condition.Field); !ok {
+		return fmt.Errorf("unknown field: %s", condition.Field)
+	}
+
+	switch condition.Operator {
+	case model.OperatorEqual, model.OperatorNotEqual, model.OperatorContains,
+		model.OperatorStartsWith, model.OperatorEndsWith, model.OperatorPresent:
+		return nil
+	default:
+		return fmt.Errorf("unknown operator: %s", condition.Operator)
+	}
+}
+
+// userMatchesConditions compares the fields case-insensitively, like the predicates of the postgres storage.
+// The conditions must be validated.
+func userMatchesConditions(user model.User, conditions []model.UserCondition) bool {
+	for _, condition := range conditions {
+		field, _ := userFieldValue(user, condition.Field)
+		field, value := strings.ToLower(field), strings.ToLower(condition.Value)
+
+		var matches bool
+		switch condition.Operator {
+		case model.OperatorEqual:
+			matches = field == value
+		case model.OperatorNotEqual:
+			matches = field != value
+		case model.OperatorContains:
+			matches = strings.Contains(field, value)
+		case model.OperatorStartsWith:
+			matches = strings.HasPrefix(field, value)
+		case model.OperatorEndsWith:
+			matches = strings.HasSuffix(field, value)
+		case model.OperatorPresent:
+			matches = field != ""
+		}
+		if !matches {
+			return false
+		}
+	}
+
+	return true
+}
+
+// userMatchesSearch applies the search filters to active users who haven't opted out of search.
+// As in postgres, filters skip the fields their owners keep private.
+func userMatchesSearch(record userRecord, params *model.UserSearchParams) bool {
+	user := record.user
+	if !record.active() || !user.Searchable {
+		return false
+	}
+
+	if params.FirstName != "" && !strings.HasPrefix(strings.ToLower(user.FirstName), strings.ToLower(params.FirstName)) {
+		return false
+	}
+	if params.SecondName != "" && !strings.HasPrefix(strings.ToLower(user.SecondName), strings.ToLower(params.SecondName)) {
+		return false
+	}
+	if params.City != "" && (!strings.EqualFold(user.City, params.City) || user.Visibility.City == model.VisibilityPrivate) {
+		return false
+	}
+	if params.Sex != "" && (!strings.EqualFold(user.Sex, params.Sex) || user.Visibility.Sex == model.VisibilityPrivate) {
+		return false
+	}
+	if !params.BornAfter.IsZero() || !params.BornBefore.IsZero() {
+		if !user.Birthdate.After(time.Time{}) || user.Visibility.Birthdate == model.VisibilityPrivate {
+			return false
+		}
+	}
+	if !params.BornAfter.IsZero() && !user.Birthdate.After(params.BornAfter) {
+		return false
+	}
+	if !params.BornBefore.IsZero() && user.Birthdate.After(params.BornBefore) {
+		return false
+	}
+
+	return true
+}
+
+// userRank approximates the fuzzy search of postgres: it counts the queries whose words all appear in the names,
+// username, city or biography, either whole or as a part of a longer word. Zero means the user doesn't match.
+func userRank(record userRecord, queries []string) int {
+	user := record.user
+	text := strings.ToLower(strings.Join([]string{user.FirstName, user.SecondName, user.Username, user.City, user.Biography}, " "))
+
+	rank := 0
+	for _, query := range queries {
+		words := strings.Fields(strings.ToLower(query))
+		matches := len(words) > 0
+		for _, word := range words {
+			matches = matches && strings.Contains(text, word)
+		}
+		if matches {
+			rank++
+		}
+	}
+
+	return rank
+}
+
+// searchKey returns the position of the user in the search order.
+func searchKey(user model.User) model.UserCursor {
+	return model.UserCursor{SecondName: user.SecondName, FirstName: user.FirstName, ID: user.UserID}
+}
+
+// compareSearchOrder compares the user with the cursor by lower second name, lower first name and id.
+func compareSearchOrder(user model.User, cursor model.UserCursor) int {
+	return cmp.Or(
+		strings.Compare(strings.ToLower(user.SecondName), strings.ToLower(cursor.SecondName)),
+		strings.Compare(strings.ToLower(user.FirstName), strings.ToLower(cursor.FirstName)),
+		cmp.Compare(user.UserID, cursor.ID),
+	)
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"slices"
+	"time"
+)
+
+type identityRecord struct {
+	identity  model.Identity
+	deletedAt time.Time
+}
+
+func (r identityRecord) model() *model.Identity {
+	identity := r.identity
+	return &identity
+}
+
+// GetIdentity returns the identity linked to the subject of the provider.
+func (s *Storage) GetIdentity(_ context.Context, provider, subject string) (*model.Identity, error) {
+	defer s.read()()
+
+	for _, record := range s.data.identities {
+		if record.deletedAt.IsZero() && record.identity.Provider == provider && record.identity.Subject == subject {
+			return record.model(), nil
+		}
+	}
+
+	return nil, errIdentityNotFound()
+}
+
+// CreateIdentity links the subject of the provider to the user. A subject can be linked to one user at a time.
+func (s *Storage) CreateIdentity(_ context.Context, identity *model.Identity) (*model.Identity, error) {
+	defer s.write()()
+
+	if _, ok := s.data.identities[identity.IdentityID]; ok {
+		return nil, errConflict(fmt.Errorf("identity %s exists", identity.IdentityID))
+	}
+	for _, record := range s.data.identities {
+		if record.deletedAt.IsZero() && record.identity.Provider == identity.Provider && record.identity.Subject == identity.Subject {
+			return nil, errConflict(fmt.Errorf("subject is linked"))
+		}
+	}
+
+	record := identityRecord{identity: *identity}
+	record.identity.CreatedAt = now()
+	s.data.identities[identity.IdentityID] = record
+
+	return record.model(), nil
+}
+
+// ListIdentities returns the external identities linked to the user.
+func (s *Storage) ListIdentities(_ context.Context, userID model.UserID) ([]*model.Identity, error) {
+	defer s.read()()
+
+	var records []identityRecord
+	for _, record := range s.data.identities {
+		if record.deletedAt.IsZero() && record.identity.UserID == userID {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b identityRecord) int {
+		return cmp.Or(a.identity.CreatedAt.Compare(b.identity.CreatedAt), cmp.Compare(a.identity.IdentityID, b.identity.IdentityID))
+	})
+
+	identities := make([]*model.Identity, 0, len(records))
+	for _, record := range records {
+		identities = append(identities, record.model())
+	}
+
+	return identities, nil
+}
+
+// DeleteIdentity unlinks the identity from the user.
+func (s *Storage) DeleteIdentity(_ context.Context, userID model.UserID, id model.IdentityID) error {
+	defer s.write()()
+
+	record, ok := s.data.identities[id]
+	if !ok || record.identity.UserID != userID || !record.deletedAt.IsZero() {
+		return errIdentityNotFound()
+	}
+	record.deletedAt = now()
+	s.data.identities[id] = record
+
+	return nil
+}
+
+func errIdentityNotFound() error {
+	return utils.WrapNotFoundError(fmt.Errorf("identity not found"), utils.NotFoundMessage)
+}
@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"context"
+	"pulse-auth/internal/model"
+	"slices"
+)
+
+// GetRoles returns the roles granted to the user.
+func (s *Storage) GetRoles(_ context.Context, id model.UserID) ([]string, error) {
+	defer s.read()()
+
+	return slices.Clone(s.data.roles[id]), nil
+}
+
+// SetRoles replaces the roles granted to the user.
+func (s *Storage) SetRoles(_ context.Context, id model.UserID, roles []string) error {
+	defer s.write()()
+
+	if len(roles) == 0 {
+		delete(s.data.roles, id)
+		return nil
+	}
+	s.data.roles[id] = slices.Compact(slices.Sorted(slices.Values(roles)))
+
+	return nil
+}
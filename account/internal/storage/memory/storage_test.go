@@ -0,0 +1,10 @@
+package memory
+
+import (
+	"pulse-auth/internal/storage/storagetest"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	storagetest.Run(t, NewStorage())
+}
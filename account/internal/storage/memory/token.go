@@ -0,0 +1,265 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"slices"
+	"time"
+)
+
+type tokenRecord struct {
+	token     model.Token
+	device    model.Device
+	createdAt time.Time
+	alivedAt  time.Time
+	deletedAt time.Time
+}
+
+// alive reports whether the token is neither revoked nor expired.
+func (r tokenRecord) alive() bool {
+	return r.deletedAt.IsZero() && r.alivedAt.After(time.Now())
+}
+
+func (r tokenRecord) model() *model.Token {
+	token := r.token
+	return &token
+}
+
+func (r tokenRecord) session() *model.Session {
+	return &model.Session{
+		Token:     r.token,
+		Device:    r.device,
+		CreatedAt: r.createdAt,
+		ExpiresAt: r.alivedAt,
+		RevokedAt: r.deletedAt,
+	}
+}
+
+// GetCurrentUserToken returns an active token of the user.
+func (s *Storage) GetCurrentUserToken(_ context.Context, id model.UserID) (*model.Token, error) {
+	defer s.read()()
+
+	for _, record := range s.data.tokens {
+		if record.token.UserID == id && record.alive() {
+			return record.model(), nil
+		}
+	}
+
+	return nil, errTokenNotFound()
+}
+
+// CreateToken validates the params and stores a new token issued to the device.
+func (s *Storage) CreateToken(_ context.Context, params *model.TokenWithMetadata) (*model.Token, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+
+	defer s.write()()
+
+	id := model.TokenID(params.TokenID)
+	if _, ok := s.data.tokens[id]; ok {
+		return nil, errConflict(fmt.Errorf("token %s exists", id))
+	}
+
+	createdAt := now()
+	record := tokenRecord{
+		token: model.Token{
+			TokenID:    id,
+			UserID:     params.UserID,
+			ClientID:   params.ClientID,
+			Scopes:     params.Scopes,
+			Token:      params.Token,
+			LastUsedAt: createdAt,
+		},
+		device:    params.Device,
+		createdAt: createdAt,
+		alivedAt:  params.AlivedAt,
+	}
+	// Client tokens are not a sign in of the user, they never pass a reauthentication check.
+	if !record.token.IssuedToClient() {
+		record.token.AuthenticatedAt = createdAt
+	}
+	s.data.tokens[id] = record
+
+	return record.model(), nil
+}
+
+// GetToken returns a token that is neither revoked nor expired by its value.
+func (s *Storage) GetToken(_ context.Context, token string) (*model.Token, error) {
+	defer s.read()()
+
+	for _, record := range s.data.tokens {
+		if record.token.Token == token && record.alive() {
+			return record.model(), nil
+		}
+	}
+
+	return nil, errTokenNotFound()
+}
+
+// RevokeToken revokes the token of the user with the value.
+func (s *Storage) RevokeToken(_ context.Context, params *model.Token) error {
+	defer s.write()()
+
+	revoked := false
+	for id, record := range s.data.tokens {
+		if record.token.Token == params.Token && record.token.UserID == params.UserID && record.deletedAt.IsZero() {
+			record.deletedAt = now()
+			s.data.tokens[id] = record
+			revoked = true
+		}
+	}
+	if !revoked {
+		return errTokenNotFound()
+	}
+
+	return nil
+}
+
+// ListSessions returns the user's tokens that are neither revoked nor expired, most recently used first.
+func (s *Storage) ListSessions(_ context.Context, userID model.UserID) ([]*model.Session, error) {
+	defer s.read()()
+
+	var records []tokenRecord
+	for _, record := range s.data.tokens {
+		if record.token.UserID == userID && record.alive() {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b tokenRecord) int {
+		return cmp.Or(b.token.LastUsedAt.Compare(a.token.LastUsedAt), cmp.Compare(a.token.TokenID, b.token.TokenID))
+	})
+
+	sessions := make([]*model.Session, 0, len(records))
+	for _, record := range records {
+		sessions = append(sessions, record.session())
+	}
+
+	return sessions, nil
+}
+
+// ListSessionHistory returns every token of the user that is still stored, revoked and expired ones included,
+// most recently created first.
+func (s *Storage) ListSessionHistory(_ context.Context, userID model.UserID) ([]*model.Session, error) {
+	defer s.read()()
+
+	var records []tokenRecord
+	for _, record := range s.data.tokens {
+		if record.token.UserID == userID {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b tokenRecord) int {
+		return cmp.Or(b.createdAt.Compare(a.createdAt), cmp.Compare(a.token.TokenID, b.token.TokenID))
+	})
+
+	sessions := make([]*model.Session, 0, len(records))
+	for _, record := range records {
+		sessions = append(sessions, record.session())
+	}
+
+	return sessions, nil
+}
+
+// TouchToken records that the token has just been used.
+func (s *Storage) TouchToken(_ context.Context, id model.TokenID) error {
+	defer s.write()()
+
+	record, ok := s.data.tokens[id]
+	if ok && record.deletedAt.IsZero() {
+		record.token.LastUsedAt = now()
+		s.data.tokens[id] = record
+	}
+
+	return nil
+}
+
+// ReauthenticateToken records that the user has just proved their credentials again in the session.
+func (s *Storage) ReauthenticateToken(_ context.Context, id model.TokenID) error {
+	defer s.write()()
+
+	record, ok := s.data.tokens[id]
+	if !ok || !record.deletedAt.IsZero() || record.token.IssuedToClient() {
+		return errTokenNotFound()
+	}
+	record.token.AuthenticatedAt = now()
+	s.data.tokens[id] = record
+
+	return nil
+}
+
+// RevokeClientTokens revokes every active token the user has issued to the client.
+func (s *Storage) RevokeClientTokens(_ context.Context, userID model.UserID, clientID model.ClientID) error {
+	defer s.write()()
+
+	s.revokeTokens(func(token model.Token) bool {
+		return token.UserID == userID && token.ClientID == clientID
+	})
+
+	return nil
+}
+
+// RevokeUserTokens revokes every active token of the user, signing them out everywhere.
+func (s *Storage) RevokeUserTokens(_ context.Context, userID model.UserID) error {
+	defer s.write()()
+
+	s.revokeTokens(func(token model.Token) bool {
+		return token.UserID == userID
+	})
+
+	return nil
+}
+
+// RefreshToken extends the lifetime of the user's token with the value.
+func (s *Storage) RefreshToken(_ context.Context, params *model.TokenWithMetadata) (*model.Token, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("params validate: %w", err)
+	}
+
+	defer s.write()()
+
+	for id, record := range s.data.tokens {
+		if record.token.Token == params.Token && record.token.UserID == params.UserID && record.deletedAt.IsZero() {
+			record.alivedAt = params.AlivedAt
+			s.data.tokens[id] = record
+			return record.model(), nil
+		}
+	}
+
+	return nil, errTokenNotFound()
+}
+
+// revokeTokens revokes the active tokens matching the predicate. The caller must hold the write lock.
+func (s *Storage) revokeTokens(match func(token model.Token) bool) {
+	revokedAt := now()
+	for id, record := range s.data.tokens {
+		if record.deletedAt.IsZero() && match(record.token) {
+			record.deletedAt = revokedAt
+			s.data.tokens[id] = record
+		}
+	}
+}
+
+func errTokenNotFound() error {
+	return utils.WrapNotFoundError(fmt.Errorf("token not found"), utils.NotFoundMessage)
+}
+
+// DeleteExpiredTokens removes the tokens that expired or were revoked before the given time.
+func (s *Storage) DeleteExpiredTokens(_ context.Context, before time.Time) (int64, error) {
+	defer s.write()()
+
+	var deleted int64
+	for id, record := range s.data.tokens {
+		if record.alivedAt.Before(before) || !record.deletedAt.IsZero() && record.deletedAt.Before(before) {
+			delete(s.data.tokens, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,421 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"slices"
+	"time"
+)
+
+type userRecord struct {
+	user           model.User
+	hashedPassword string
+	// canonical is empty for usernames that predate normalization and clash with an older user.
+	canonical string
+	deletedAt time.Time
+	deletedBy model.DeletedBy
+	purgedAt  time.Time
+}
+
+func (r userRecord) active() bool {
+	return r.deletedAt.IsZero()
+}
+
+// model returns a copy of the user, so callers can't change the stored record.
+func (r userRecord) model() *model.User {
+	user := r.user
+	user.HasPassword = r.hashedPassword != ""
+	return &user
+}
+
+// LoginUser returns the active user with the exact username and password hash.
+func (s *Storage) LoginUser(_ context.Context, userLogin *model.UserLogin) (*model.User, error) {
+	defer s.read()()
+
+	for _, record := range s.data.users {
+		if record.active() && record.user.Username == userLogin.Username && record.hashedPassword == userLogin.HashedPassword {
+			return record.model(), nil
+		}
+	}
+
+	return nil, errUserNotFound()
+}
+
+// CreateUser validates the registration params and creates a new user with the default profile visibility.
+func (s *Storage) CreateUser(_ context.Context, params *model.UserRegister) (*model.User, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+	canonical, err := model.NormalizeUsername(params.Username)
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+	}
+
+	defer s.write()()
+
+	id := model.UserID(params.ID)
+	if _, ok := s.data.users[id]; ok {
+		return nil, errConflict(fmt.Errorf("user %s exists", id))
+	}
+	if s.usernameTaken(canonical, "") {
+		return nil, errConflict(fmt.Errorf("username is taken"))
+	}
+
+	createdAt := now()
+	record := userRecord{
+		user: model.User{
+			UserID:     id,
+			Username:   params.Username,
+			FirstName:  params.FirstName,
+			SecondName: params.SecondName,
+			Sex:        params.Sex,
+			Birthdate:  params.Birthdate,
+			Biography:  params.Biography,
+			City:       params.City,
+			Visibility: model.DefaultProfileVisibility(),
+			Searchable: true,
+			CreatedAt:  createdAt,
+			UpdatedAt:  createdAt,
+		},
+		hashedPassword: params.HashedPassword,
+		canonical:      canonical,
+	}
+	s.data.users[id] = record
+
+	return record.model(), nil
+}
+
+// GetUserByID returns the active user with the id.
+func (s *Storage) GetUserByID(_ context.Context, id model.UserID) (*model.User, error) {
+	defer s.read()()
+
+	record, ok := s.data.users[id]
+	if !ok || !record.active() {
+		return nil, errUserNotFound()
+	}
+
+	return record.model(), nil
+}
+
+// GetUserWithDeleted returns the user, including users deleted but not purged yet.
+func (s *Storage) GetUserWithDeleted(_ context.Context, id model.UserID) (*model.User, error) {
+	defer s.read()()
+
+	record, ok := s.data.users[id]
+	if !ok || !record.purgedAt.IsZero() {
+		return nil, errUserNotFound()
+	}
+	user := record.model()
+	user.DeletedAt = record.deletedAt
+	user.DeletedBy = record.deletedBy
+
+	return user, nil
+}
+
+// GetUsersByIDs returns the active users among the ids. Unknown ids are skipped.
+func (s *Storage) GetUsersByIDs(_ context.Context, ids []model.UserID) ([]*model.User, error) {
+	defer s.read()()
+
+	users := make([]*model.User, 0, len(ids))
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(ids))) {
+		if record, ok := s.data.users[id]; ok && record.active() {
+			users = append(users, record.model())
+		}
+	}
+
+	return users, nil
+}
+
+// SearchUser returns a searchable active user with exactly the first and last name.
+func (s *Storage) SearchUser(_ context.Context, firstName, lastName string) (*model.User, error) {
+	defer s.read()()
+
+	for _, record := range s.data.users {
+		if record.active() && record.user.Searchable &&
+			record.user.FirstName == firstName && record.user.SecondName == lastName {
+			return record.model(), nil
+		}
+	}
+
+	return nil, errUserNotFound()
+}
+
+// SearchUsers returns a page of active users matching the filters and the number of matches across all pages.
+func (s *Storage) SearchUsers(_ context.Context, params *model.UserSearchParams) ([]*model.User, uint64, error) {
+	defer s.read()()
+
+	type match struct {
+		record userRecord
+		rank   int
+	}
+	var matches []match
+	for _, record := range s.data.users {
+		if !userMatchesSearch(record, params) {
+			continue
+		}
+		rank := 0
+		if len(params.Queries) > 0 {
+			if rank = userRank(record, params.Queries); rank == 0 {
+				continue
+			}
+		}
+		matches = append(matches, match{record: record, rank: rank})
+	}
+	total := uint64(len(matches))
+
+	users := make([]*model.User, 0, min(total, params.Limit))
+	if len(params.Queries) > 0 {
+		slices.SortFunc(matches, func(a, b match) int {
+			return cmp.Or(cmp.Compare(b.rank, a.rank), cmp.Compare(a.record.user.UserID, b.record.user.UserID))
+		})
+		for _, m := range page(matches, params.Offset, params.Limit) {
+			users = append(users, m.record.model())
+		}
+		return users, total, nil
+	}
+
+	slices.SortFunc(matches, func(a, b match) int {
+		return compareSearchOrder(a.record.user, searchKey(b.record.user))
+	})
+	for _, m := range matches {
+		if uint64(len(users)) == params.Limit {
+			break
+		}
+		if params.After != nil && compareSearchOrder(m.record.user, *params.After) <= 0 {
+			continue
+		}
+		users = append(users, m.record.model())
+	}
+
+	return users, total, nil
+}
+
+// LockUser only checks that the user is active, transactions hold the write lock and don't overlap.
+func (s *Storage) LockUser(_ context.Context, id model.UserID) error {
+	defer s.read()()
+
+	if record, ok := s.data.users[id]; !ok || !record.active() {
+		return errUserNotFound()
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the user's password hash. An empty hash leaves the account without a password.
+func (s *Storage) UpdatePassword(_ context.Context, id model.UserID, hashedPassword string) error {
+	defer s.write()()
+
+	record, ok := s.data.users[id]
+	if !ok || !record.active() {
+		return errUserNotFound()
+	}
+	record.hashedPassword = hashedPassword
+	s.data.users[id] = record
+
+	return nil
+}
+
+// ListUsers returns a page of active users matching the conditions and the number of matches across all pages.
+func (s *Storage) ListUsers(_ context.Context, params *model.UserListParams) ([]*model.User, uint64, error) {
+	for _, condition := range params.Conditions {
+		if err := validateUserCondition(condition); err != nil {
+			return nil, 0, utils.WrapValidationError(fmt.Errorf("condition: %w", err))
+		}
+	}
+
+	defer s.read()()
+
+	var matches []userRecord
+	for _, record := range s.data.users {
+		if record.active() && userMatchesConditions(record.user, params.Conditions) {
+			matches = append(matches, record)
+		}
+	}
+	slices.SortFunc(matches, func(a, b userRecord) int {
+		return cmp.Or(a.user.CreatedAt.Compare(b.user.CreatedAt), cmp.Compare(a.user.UserID, b.user.UserID))
+	})
+
+	selected := page(matches, params.Offset, params.Limit)
+	users := make([]*model.User, 0, len(selected))
+	for _, record := range selected {
+		users = append(users, record.model())
+	}
+
+	return users, uint64(len(matches)), nil
+}
+
+// UpdateUser changes the fields set in the update and returns the updated user.
+func (s *Storage) UpdateUser(ctx context.Context, update *model.UserUpdate) (*model.User, error) {
+	err := update.Validate()
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+	if update.Empty() {
+		return s.GetUserByID(ctx, update.ID)
+	}
+
+	defer s.write()()
+
+	record, ok := s.data.users[update.ID]
+	if !ok || !record.active() {
+		return nil, errUserNotFound()
+	}
+
+	user := &record.user
+	if update.Username != nil {
+		canonical, err := model.NormalizeUsername(*update.Username)
+		if err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+		}
+		if s.usernameTaken(canonical, update.ID) {
+			return nil, errConflict(fmt.Errorf("username is taken"))
+		}
+		user.Username, record.canonical = *update.Username, canonical
+	}
+	setIfNotNil(&user.FirstName, update.FirstName)
+	setIfNotNil(&user.SecondName, update.SecondName)
+	setIfNotNil(&user.Sex, update.Sex)
+	setIfNotNil(&user.Birthdate, update.Birthdate)
+	setIfNotNil(&user.Biography, update.Biography)
+	setIfNotNil(&user.City, update.City)
+	setIfNotNil(&user.Visibility.Sex, update.SexVisibility)
+	setIfNotNil(&user.Visibility.Birthdate, update.BirthdateVisibility)
+	setIfNotNil(&user.Visibility.Biography, update.BiographyVisibility)
+	setIfNotNil(&user.Visibility.City, update.CityVisibility)
+	setIfNotNil(&user.Searchable, update.Searchable)
+	user.UpdatedAt = now()
+	s.data.users[update.ID] = record
+
+	return record.model(), nil
+}
+
+// DeleteUser soft-deletes the user and records who deleted it.
+func (s *Storage) DeleteUser(_ context.Context, id model.UserID, by model.DeletedBy) error {
+	defer s.write()()
+
+	record, ok := s.data.users[id]
+	if !ok || !record.active() {
+		return errUserNotFound()
+	}
+	record.deletedAt = now()
+	record.deletedBy = by
+	record.user.UpdatedAt = record.deletedAt
+	s.data.users[id] = record
+
+	return nil
+}
+
+// RestoreUser undeletes the user matching the credentials if they deleted it after deletedAfter and it's not purged
+// yet.
+func (s *Storage) RestoreUser(_ context.Context, userLogin *model.UserLogin, deletedAfter time.Time) (*model.User, error) {
+	defer s.write()()
+
+	for id, record := range s.data.users {
+		if record.user.Username != userLogin.Username || record.hashedPassword != userLogin.HashedPassword ||
+			!record.purgedAt.IsZero() || !record.deletedAt.After(deletedAfter) || record.deletedBy != model.DeletedByUser {
+			continue
+		}
+		record.deletedAt = time.Time{}
+		record.deletedBy = ""
+		record.user.UpdatedAt = now()
+		s.data.users[id] = record
+		return record.model(), nil
+	}
+
+	return nil, errUserNotFound()
+}
+
+// RestoreUserByID undeletes the user if it was deleted by the given party after deletedAfter and not purged yet.
+func (s *Storage) RestoreUserByID(_ context.Context, id model.UserID, deletedAfter time.Time, by model.DeletedBy) (*model.User, error) {
+	defer s.write()()
+
+	record, ok := s.data.users[id]
+	if !ok || !record.purgedAt.IsZero() || !record.deletedAt.After(deletedAfter) || record.deletedBy != by {
+		return nil, errUserNotFound()
+	}
+	record.deletedAt = time.Time{}
+	record.deletedBy = ""
+	record.user.UpdatedAt = now()
+	s.data.users[id] = record
+
+	return record.model(), nil
+}
+
+// PurgeUsers anonymizes users deleted before deletedBefore and removes everything linked to them.
+// The user record is kept with a placeholder username, like the row in postgres.
+func (s *Storage) PurgeUsers(_ context.Context, deletedBefore time.Time) (int64, error) {
+	defer s.write()()
+
+	purgedAt := now()
+	purged := map[model.UserID]bool{}
+	for id, record := range s.data.users {
+		if record.active() || !record.deletedAt.Before(deletedBefore) || !record.purgedAt.IsZero() {
+			continue
+		}
+		placeholder := "deleted-" + id.String()
+		record.user = model.User{
+			UserID:     id,
+			Username:   placeholder,
+			Visibility: record.user.Visibility,
+			Searchable: record.user.Searchable,
+			CreatedAt:  record.user.CreatedAt,
+			UpdatedAt:  purgedAt,
+		}
+		record.hashedPassword = ""
+		record.canonical = placeholder
+		record.purgedAt = purgedAt
+		s.data.users[id] = record
+		purged[id] = true
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	maps.DeleteFunc(s.data.tokens, func(_ model.TokenID, r tokenRecord) bool { return purged[r.token.UserID] })
+	maps.DeleteFunc(s.data.consents, func(k consentKey, _ consentRecord) bool { return purged[k.userID] })
+	maps.DeleteFunc(s.data.identities, func(_ model.IdentityID, r identityRecord) bool { return purged[r.identity.UserID] })
+	maps.DeleteFunc(s.data.roles, func(id model.UserID, _ []string) bool { return purged[id] })
+	maps.DeleteFunc(s.data.exports, func(_ model.ExportID, r exportRecord) bool { return purged[r.export.UserID] })
+	s.data.usernames = slices.DeleteFunc(s.data.usernames, func(r usernameRecord) bool { return purged[r.record.UserID] })
+
+	return int64(len(purged)), nil
+}
+
+// usernameTaken reports whether a user other than except has the canonical username, deleted users included.
+// The caller must hold the lock.
+func (s *Storage) usernameTaken(canonical string, except model.UserID) bool {
+	for id, record := range s.data.users {
+		if id != except && record.canonical == canonical {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setIfNotNil[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// page returns the items between offset and offset plus limit.
+func page[T any](items []T, offset, limit uint64) []T {
+	start := min(offset, uint64(len(items)))
+	end := min(start+limit, uint64(len(items)))
+	return items[start:end]
+}
+
+func errUserNotFound() error {
+	return utils.WrapNotFoundError(fmt.Errorf("user not found"), utils.NotFoundMessage)
+}
+
+func errConflict(err error) error {
+	return utils.WrapError(err, utils.ConflictMessage, http.StatusConflict)
+}
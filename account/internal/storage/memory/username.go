@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type usernameRecord struct {
+	record    model.UsernameRecord
+	canonical string
+}
+
+// GetUserByUsername returns the active user with the username, compared in canonical form.
+func (s *Storage) GetUserByUsername(_ context.Context, username string) (*model.User, error) {
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("normalize username: %w", err), utils.NotFoundMessage)
+	}
+
+	defer s.read()()
+
+	for _, record := range s.data.users {
+		if record.active() && record.canonical == canonical {
+			return record.model(), nil
+		}
+	}
+
+	return nil, errUserNotFound()
+}
+
+// ChangeUsername renames the user and records the old username in the history, reserved until reservedUntil.
+func (s *Storage) ChangeUsername(_ context.Context, id model.UserID, username string, reservedUntil time.Time) (*model.User, error) {
+	if err := model.ValidateUsername(username); err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("validate username: %w", err))
+	}
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+	}
+
+	defer s.write()()
+
+	record, ok := s.data.users[id]
+	if !ok || !record.active() {
+		return nil, errUserNotFound()
+	}
+	if s.usernameTaken(canonical, id) {
+		return nil, errConflict(fmt.Errorf("username is taken"))
+	}
+
+	changedAt := now()
+	if record.user.Username != username {
+		previous := record.canonical
+		if previous == "" {
+			previous = strings.ToLower(norm.NFKC.String(record.user.Username))
+		}
+		s.data.usernames = append(s.data.usernames, usernameRecord{
+			record: model.UsernameRecord{
+				UserID:        id,
+				Username:      record.user.Username,
+				ChangedAt:     changedAt,
+				ReservedUntil: reservedUntil,
+			},
+			canonical: previous,
+		})
+	}
+	record.user.Username, record.canonical = username, canonical
+	record.user.UpdatedAt = changedAt
+	s.data.users[id] = record
+
+	return record.model(), nil
+}
+
+// ListUsernameHistory returns the previous usernames of the user, most recent first.
+func (s *Storage) ListUsernameHistory(_ context.Context, id model.UserID) ([]*model.UsernameRecord, error) {
+	defer s.read()()
+
+	records := make([]*model.UsernameRecord, 0)
+	for _, r := range slices.Backward(s.data.usernames) {
+		if r.record.UserID == id {
+			record := r.record
+			records = append(records, &record)
+		}
+	}
+	slices.SortStableFunc(records, func(a, b *model.UsernameRecord) int {
+		return cmp.Compare(b.ChangedAt.UnixNano(), a.ChangedAt.UnixNano())
+	})
+
+	return records, nil
+}
+
+// GetUsernameRecord returns the latest time somebody gave up the username, compared in canonical form.
+func (s *Storage) GetUsernameRecord(_ context.Context, username string) (*model.UsernameRecord, error) {
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("normalize username: %w", err), utils.NotFoundMessage)
+	}
+
+	defer s.read()()
+
+	var latest *model.UsernameRecord
+	for _, r := range s.data.usernames {
+		if r.canonical == canonical && (latest == nil || !r.record.ChangedAt.Before(latest.ChangedAt)) {
+			record := r.record
+			latest = &record
+		}
+	}
+	if latest == nil {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("username record not found"), utils.NotFoundMessage)
+	}
+
+	return latest, nil
+}
@@ -1,10 +1,20 @@
 package postgres
 
-import "strings"
+import (
+	"database/sql"
+	"strings"
+)
 
 const (
-	UserTable  = "user_table"
-	TokenTable = "token_table"
+	UserTable     = "user_table"
+	TokenTable    = "token_table"
+	ConsentTable  = "consent_table"
+	IdentityTable = "identity_table"
+	UserRoleTable = "user_role_table"
+	ExportTable   = "export_table"
+
+	UsernameHistoryTable = "username_history_table"
+	JobRunTable          = "job_run_table"
 )
 
 const (
@@ -13,32 +23,85 @@ const (
 )
 
 const (
-	fieldID     = "id"
-	fieldUserID = "user_id"
-
-	fieldUsername       = "username"
-	fieldHashedPassword = "hashed_password"
-	fieldFirstName      = "first_name"
-	fieldSecondName     = "second_name"
-	fieldSex            = "sex"
-	fieldBirthdate      = "birthdate"
-	fieldBiography      = "biography"
-	fieldCity           = "city"
+	fieldID       = "id"
+	fieldUserID   = "user_id"
+	fieldClientID = "client_id"
+
+	fieldUsername          = "username"
+	fieldUsernameCanonical = "username_canonical"
+	fieldHashedPassword    = "hashed_password"
+	fieldFirstName         = "first_name"
+	fieldSecondName        = "second_name"
+	fieldSex               = "sex"
+	fieldBirthdate         = "birthdate"
+	fieldBiography         = "biography"
+	fieldCity              = "city"
+
+	fieldSexVisibility       = "sex_visibility"
+	fieldBirthdateVisibility = "birthdate_visibility"
+	fieldBiographyVisibility = "biography_visibility"
+	fieldCityVisibility      = "city_visibility"
+	fieldSearchable          = "searchable"
 
 	fieldCreatedAt = "created_at"
+	fieldUpdatedAt = "updated_at"
 	fieldDeletedAt = "deleted_at"
+	fieldDeletedBy = "deleted_by"
+	fieldPurgedAt  = "purged_at"
+
+	fieldToken           = "token"
+	fieldAlivedAt        = "alived_at"
+	fieldAuthenticatedAt = "authenticated_at"
+	fieldLastUsedAt      = "last_used_at"
+	fieldUserAgent       = "user_agent"
+	fieldIPAddress       = "ip_address"
+	fieldClientName      = "client_name"
+
+	fieldScopes = "scopes"
+
+	fieldRole = "role"
 
-	fieldToken    = "token"
-	fieldAlivedAt = "alived_at"
+	fieldProvider = "provider"
+	fieldSubject  = "subject"
+	fieldEmail    = "email"
+
+	fieldReservedUntil = "reserved_until"
+
+	fieldSearchText   = "search_text"
+	fieldSearchVector = "search_vector"
+
+	fieldStatus    = "status"
+	fieldError     = "error"
+	fieldArchive   = "archive"
+	fieldExpiresAt = "expires_at"
 )
 
+// userSearchOrder is the order of search results, matching the cursor and idx_user_table_search_order.
+var userSearchOrder = []string{"LOWER(" + fieldSecondName + ")", "LOWER(" + fieldFirstName + ")", fieldID}
+
 var (
 	userFields = []string{
 		fieldID, fieldUsername, fieldHashedPassword, fieldFirstName, fieldSecondName,
-		fieldSex, fieldBirthdate, fieldBiography, fieldCity, fieldCreatedAt,
+		fieldSex, fieldBirthdate, fieldBiography, fieldCity, fieldCreatedAt, fieldUpdatedAt,
+		fieldSexVisibility, fieldBirthdateVisibility, fieldBiographyVisibility, fieldCityVisibility, fieldSearchable,
+	}
+	tokenFields = []string{
+		fieldID, fieldUserID, fieldToken, fieldCreatedAt, fieldAlivedAt, fieldClientID, fieldAuthenticatedAt,
+		fieldLastUsedAt, fieldUserAgent, fieldIPAddress, fieldClientName, fieldScopes,
 	}
-	tokenFields = []string{fieldID, fieldUserID, fieldToken, fieldCreatedAt, fieldAlivedAt}
+	consentFields         = []string{fieldUserID, fieldClientID, fieldScopes, fieldCreatedAt, fieldUpdatedAt}
+	identityFields        = []string{fieldID, fieldUserID, fieldProvider, fieldSubject, fieldEmail, fieldCreatedAt}
+	usernameHistoryFields = []string{fieldUserID, fieldUsername, fieldCreatedAt, fieldReservedUntil}
+	exportFields          = []string{fieldID, fieldUserID, fieldStatus, fieldError, fieldCreatedAt, fieldUpdatedAt, fieldExpiresAt}
 
-	returningUser  = returning + strings.Join(userFields, separator)
-	returningToken = returning + strings.Join(tokenFields, separator)
+	returningUser     = returning + strings.Join(userFields, separator)
+	returningToken    = returning + strings.Join(tokenFields, separator)
+	returningConsent  = returning + strings.Join(consentFields, separator)
+	returningIdentity = returning + strings.Join(identityFields, separator)
+	returningExport   = returning + strings.Join(exportFields, separator)
 )
+
+// nullString stores empty optional values as NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
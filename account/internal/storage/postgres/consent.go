@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// GetConsent returns the scopes the user has granted to the client.
+func (s *Storage) GetConsent(ctx context.Context, userID model.UserID, clientID model.ClientID) (*model.Consent, error) {
+	sql, args, err := sq.Select(consentFields...).
+		From(ConsentTable).
+		Where(sq.Eq{
+			fieldUserID:    userID.String(),
+			fieldClientID:  clientID.String(),
+			fieldDeletedAt: nil,
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity consentEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return consentEntityToModel(entity), nil
+}
+
+// ListConsents returns every client the user has granted access to.
+func (s *Storage) ListConsents(ctx context.Context, userID model.UserID) ([]*model.Consent, error) {
+	sql, args, err := sq.Select(consentFields...).
+		From(ConsentTable).
+		Where(sq.Eq{
+			fieldUserID:    userID.String(),
+			fieldDeletedAt: nil,
+		}).
+		OrderBy(fieldCreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []consentEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	consents := make([]*model.Consent, 0, len(entities))
+	for _, entity := range entities {
+		consents = append(consents, consentEntityToModel(entity))
+	}
+
+	return consents, nil
+}
+
+// GrantConsent stores the scopes granted to the client, replacing a previous or withdrawn consent.
+func (s *Storage) GrantConsent(ctx context.Context, consent *model.Consent) (*model.Consent, error) {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Insert(ConsentTable).
+		Columns(consentFields...).
+		Values(consent.UserID, consent.ClientID, model.FormatScopes(consent.Scopes), now, now).
+		Suffix("ON CONFLICT (user_id, client_id) DO UPDATE SET " +
+			"scopes = EXCLUDED.scopes, updated_at = EXCLUDED.updated_at, deleted_at = NULL " +
+			returningConsent).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity consentEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return consentEntityToModel(entity), nil
+}
+
+// RevokeConsent withdraws the consent by updating the deleted_at field with the current timestamp.
+func (s *Storage) RevokeConsent(ctx context.Context, userID model.UserID, clientID model.ClientID) error {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Update(ConsentTable).
+		Where(sq.Eq{
+			fieldUserID:    userID.String(),
+			fieldClientID:  clientID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldDeletedAt, now).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.WrapNotFoundError(fmt.Errorf("consent not found"), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+type consentEntity struct {
+	UserID    string    `db:"user_id"`
+	ClientID  string    `db:"client_id"`
+	Scopes    string    `db:"scopes"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// consentEntityToModel converts a consent entity to a consent model and returns a pointer to it.
+func consentEntityToModel(entity consentEntity) *model.Consent {
+	return &model.Consent{
+		UserID:    model.UserID(entity.UserID),
+		ClientID:  model.ClientID(entity.ClientID),
+		Scopes:    model.ParseScopes(entity.Scopes),
+		CreatedAt: entity.CreatedAt,
+		UpdatedAt: entity.UpdatedAt,
+	}
+}
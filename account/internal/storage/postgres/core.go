@@ -1,15 +1,30 @@
 package postgres
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 	"pulse-auth/cmd/pulse/config"
 	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
 )
 
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx, so the repositories run the same queries in a transaction.
+type queryer interface {
+	sqlx.ExecerContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
 type Storage struct {
-	db     *sqlx.DB
+	conn *sqlx.DB
+	db   queryer
+	// replicas is nil without read replicas.
+	replicas *replicaSet
+	// dsn opens the connections that live outside the pool, like the one of Listen.
+	dsn    string
 	logger *zap.Logger
 }
 
@@ -18,12 +33,33 @@ func NewStorage(logger *zap.Logger, config config.StorageConfig) (*Storage, erro
 	if err != nil {
 		return nil, fmt.Errorf("new pg storage: %w", err)
 	}
+	replicas, err := newReplicaSet(logger, config)
+	if err != nil {
+		_ = postgresStorage.Conn.Close()
+		return nil, fmt.Errorf("new replica set: %w", err)
+	}
 	return &Storage{
-		db:     postgresStorage.Conn,
-		logger: logger,
+		conn:     postgresStorage.Conn,
+		db:       postgresStorage.Conn,
+		replicas: replicas,
+		dsn:      dataSourceName(config),
+		logger:   logger,
 	}, nil
 }
 
+// Close closes the connection pools of the primary and of the read replicas.
+func (s *Storage) Close() error {
+	var errs []error
+	if s.replicas != nil {
+		errs = append(errs, s.replicas.close())
+	}
+	if err := s.conn.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close primary: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
 func (s *Storage) User() storage.UserRepository {
 	return s
 }
@@ -31,3 +67,38 @@ func (s *Storage) User() storage.UserRepository {
 func (s *Storage) Token() storage.TokenRepository {
 	return s
 }
+
+func (s *Storage) Consent() storage.ConsentRepository {
+	return s
+}
+
+func (s *Storage) Identity() storage.IdentityRepository {
+	return s
+}
+
+func (s *Storage) Export() storage.ExportRepository {
+	return s
+}
+
+func (s *Storage) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	if _, ok := s.db.(*sqlx.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("begin transaction: %w", err))
+	}
+	// Rolling back a committed transaction is a no-op.
+	defer func() { _ = tx.Rollback() }()
+
+	if err = fn(&Storage{conn: s.conn, db: tx, replicas: s.replicas, dsn: s.dsn, logger: s.logger}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.WrapSqlError(fmt.Errorf("commit transaction: %w", err))
+	}
+
+	return nil
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	"net"
+	"net/url"
 	"pulse-auth/cmd/pulse/config"
+	"strconv"
 )
 
 type PGStorage struct {
@@ -13,13 +16,14 @@ type PGStorage struct {
 
 // NewPGStorage creates a new PGStorage instance connected to a PostgreSQL database using pgx driver.
 func NewPGStorage(log *zap.Logger, cfg config.StorageConfig) (*PGStorage, error) {
-	dbSource := fmt.Sprintf("postgres://%s:%s@localhost:%d/%s", cfg.Username, cfg.Password, cfg.Port, cfg.Name)
-	conn, err := sqlx.Connect("pgx", dbSource)
+	conn, err := sqlx.Connect("pgx", dataSourceName(cfg))
 
 	if err != nil {
 		return nil, fmt.Errorf("connect to pgx failed %w", err)
 	}
 
+	setPoolLimits(conn, cfg)
+
 	err = conn.Ping()
 
 	if err != nil {
@@ -28,3 +32,52 @@ func NewPGStorage(log *zap.Logger, cfg config.StorageConfig) (*PGStorage, error)
 
 	return &PGStorage{Conn: conn}, nil
 }
+
+func setPoolLimits(conn *sqlx.DB, cfg config.StorageConfig) {
+	conn.SetMaxOpenConns(cfg.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// dataSourceName builds the connection URL from the config unless it has a complete DSN.
+// Parameters pgx does not know, like statement_timeout, are sent to the server as run-time parameters.
+func dataSourceName(cfg config.StorageConfig) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
+	host, port := cfg.Host, cfg.Port
+	if host == "" {
+		host = "localhost"
+	}
+	if port == 0 {
+		port = 5432
+	}
+
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"sslmode":          cfg.SSLMode,
+		"sslrootcert":      cfg.SSLRootCert,
+		"sslcert":          cfg.SSLCert,
+		"sslkey":           cfg.SSLKey,
+		"application_name": cfg.ApplicationName,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	if cfg.StatementTimeout > 0 {
+		params.Set("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.Username, cfg.Password),
+		Host:     net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:     "/" + cfg.Name,
+		RawQuery: params.Encode(),
+	}
+
+	return dsn.String()
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// CreateExport queues a new personal data export.
+func (s *Storage) CreateExport(ctx context.Context, export *model.Export) (*model.Export, error) {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Insert(ExportTable).
+		Columns(fieldID, fieldUserID, fieldStatus, fieldCreatedAt, fieldUpdatedAt).
+		Values(export.ExportID, export.UserID, model.ExportStatusPending, now, now).
+		Suffix(returningExport).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity exportEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return exportEntityToModel(entity), nil
+}
+
+// GetExport returns the export without its archive.
+func (s *Storage) GetExport(ctx context.Context, id model.ExportID) (*model.Export, error) {
+	sql, args, err := sq.Select(exportFields...).
+		From(ExportTable).
+		Where(sq.Eq{fieldID: id.String()}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity exportEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return exportEntityToModel(entity), nil
+}
+
+// ClaimExport marks the oldest pending export as running and returns it. Exports left running since
+// staleBefore are claimed again, so the work of a replica that stopped mid-export isn't lost.
+func (s *Storage) ClaimExport(ctx context.Context, staleBefore time.Time) (*model.Export, error) {
+	query := `
+UPDATE ` + ExportTable + `
+SET status = $1, updated_at = $2
+WHERE id = (
+    SELECT id FROM ` + ExportTable + `
+    WHERE status = $3 OR (status = $1 AND updated_at < $4)
+    ORDER BY created_at
+    LIMIT 1 FOR UPDATE SKIP LOCKED
+)
+` + returningExport
+
+	var entity exportEntity
+	err := s.db.GetContext(ctx, &entity, query, model.ExportStatusRunning, time.Now().Truncate(time.Millisecond),
+		model.ExportStatusPending, staleBefore)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return exportEntityToModel(entity), nil
+}
+
+// CompleteExport stores the archive of a running export and makes it downloadable until expiresAt.
+func (s *Storage) CompleteExport(ctx context.Context, id model.ExportID, archive []byte, expiresAt time.Time) error {
+	return s.finishExport(ctx, id, map[string]any{
+		fieldStatus:    model.ExportStatusReady,
+		fieldArchive:   archive,
+		fieldExpiresAt: expiresAt,
+	})
+}
+
+// FailExport records why a running export couldn't be built. The export is removed at expiresAt.
+func (s *Storage) FailExport(ctx context.Context, id model.ExportID, reason string, expiresAt time.Time) error {
+	return s.finishExport(ctx, id, map[string]any{
+		fieldStatus:    model.ExportStatusFailed,
+		fieldError:     reason,
+		fieldExpiresAt: expiresAt,
+	})
+}
+
+func (s *Storage) finishExport(ctx context.Context, id model.ExportID, values map[string]any) error {
+	sql, args, err := sq.Update(ExportTable).
+		Where(sq.Eq{
+			fieldID:     id.String(),
+			fieldStatus: model.ExportStatusRunning,
+		}).
+		SetMap(values).
+		Set(fieldUpdatedAt, time.Now().Truncate(time.Millisecond)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.WrapNotFoundError(fmt.Errorf("running export not found"), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+// GetExportArchive returns the archive of a ready export that hasn't expired.
+func (s *Storage) GetExportArchive(ctx context.Context, id model.ExportID) ([]byte, error) {
+	sql, args, err := sq.Select(fieldArchive).
+		From(ExportTable).
+		Where(sq.Eq{
+			fieldID:     id.String(),
+			fieldStatus: model.ExportStatusReady,
+		}).
+		Where(sq.Gt{fieldExpiresAt: time.Now()}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var archive []byte
+	err = s.db.GetContext(ctx, &archive, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return archive, nil
+}
+
+// DeleteExpiredExports removes finished exports that expired before the given time.
+func (s *Storage) DeleteExpiredExports(ctx context.Context, before time.Time) (int64, error) {
+	sql, args, err := sq.Delete(ExportTable).
+		Where(sq.Lt{fieldExpiresAt: before}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return 0, utils.WrapSqlError(err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, utils.WrapInternalError(err)
+	}
+
+	return deleted, nil
+}
+
+type exportEntity struct {
+	ID        string         `db:"id"`
+	UserID    string         `db:"user_id"`
+	Status    string         `db:"status"`
+	Error     sql.NullString `db:"error"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+	ExpiresAt sql.NullTime   `db:"expires_at"`
+}
+
+// exportEntityToModel converts an export entity to an export model.
+func exportEntityToModel(entity exportEntity) *model.Export {
+	return &model.Export{
+		ExportID:  model.ExportID(entity.ID),
+		UserID:    model.UserID(entity.UserID),
+		Status:    model.ExportStatus(entity.Status),
+		Error:     entity.Error.String,
+		CreatedAt: entity.CreatedAt,
+		UpdatedAt: entity.UpdatedAt,
+		ExpiresAt: entity.ExpiresAt.Time,
+	}
+}
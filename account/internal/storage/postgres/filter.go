@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"fmt"
+	"pulse-auth/internal/model"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var userFieldColumns = map[model.UserField]string{
+	model.UserFieldID:         fieldID,
+	model.UserFieldUsername:   fieldUsername,
+	model.UserFieldFirstName:  fieldFirstName,
+	model.UserFieldSecondName: fieldSecondName,
+	model.UserFieldCity:       fieldCity,
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// userConditionToSql translates a condition into a case-insensitive predicate on the column.
+func userConditionToSql(condition model.UserCondition) (sq.Sqlizer, error) {
+	column, ok := userFieldColumns[condition.Field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field: %s", condition.Field)
+	}
+
+	pattern := likeEscaper.Replace(condition.Value)
+	switch condition.Operator {
+	case model.OperatorEqual:
+		return sq.Expr("LOWER("+column+") = LOWER(?)", condition.Value), nil
+	case model.OperatorNotEqual:
+		return sq.Expr("LOWER("+column+") IS DISTINCT FROM LOWER(?)", condition.Value), nil
+	case model.OperatorContains:
+		return sq.ILike{column: "%" + pattern + "%"}, nil
+	case model.OperatorStartsWith:
+		return sq.ILike{column: pattern + "%"}, nil
+	case model.OperatorEndsWith:
+		return sq.ILike{column: "%" + pattern}, nil
+	case model.OperatorPresent:
+		return sq.Expr("COALESCE(" + column + ", '') <> ''"), nil
+	default:
+		return nil, fmt.Errorf("unknown operator: %s", condition.Operator)
+	}
+}
+
+// userSearchToSql translates the search filters into predicates on active users who haven't opted out of search. The cursor is left out,
+// so the same predicates count the matches across all pages.
+func userSearchToSql(params *model.UserSearchParams) sq.And {
+	where := sq.And{sq.Eq{fieldDeletedAt: nil, fieldSearchable: true}}
+	if params.FirstName != "" {
+		where = append(where, sq.Expr("LOWER("+fieldFirstName+") LIKE ?", strings.ToLower(likeEscaper.Replace(params.FirstName))+"%"))
+	}
+	if params.SecondName != "" {
+		where = append(where, sq.Expr("LOWER("+fieldSecondName+") LIKE ?", strings.ToLower(likeEscaper.Replace(params.SecondName))+"%"))
+	}
+	// Search is only open to signed in users, so filters skip the fields their owners keep private.
+	// Otherwise filtering would reveal the hidden values.
+	if params.City != "" {
+		where = append(where, sq.Expr("LOWER("+fieldCity+") = LOWER(?)", params.City),
+			sq.NotEq{fieldCityVisibility: model.VisibilityPrivate})
+	}
+	if params.Sex != "" {
+		where = append(where, sq.Expr("LOWER("+fieldSex+") = LOWER(?)", params.Sex),
+			sq.NotEq{fieldSexVisibility: model.VisibilityPrivate})
+	}
+	if !params.BornAfter.IsZero() || !params.BornBefore.IsZero() {
+		// Users who never set a birthdate have the zero time stored.
+		where = append(where, sq.Gt{fieldBirthdate: time.Time{}},
+			sq.NotEq{fieldBirthdateVisibility: model.VisibilityPrivate})
+	}
+	if !params.BornAfter.IsZero() {
+		where = append(where, sq.Gt{fieldBirthdate: params.BornAfter})
+	}
+	if !params.BornBefore.IsZero() {
+		where = append(where, sq.LtOrEq{fieldBirthdate: params.BornBefore})
+	}
+
+	return where
+}
+
+// userCursorToSql selects the users after the cursor in the search order.
+func userCursorToSql(cursor *model.UserCursor) sq.Sqlizer {
+	row := "(" + strings.Join(userSearchOrder, separator) + ")"
+	return sq.Expr(row+" > (LOWER(?), LOWER(?), ?)", cursor.SecondName, cursor.FirstName, cursor.ID.String())
+}
+
+// userQueriesToSql matches users whose names, username or city are similar to any of the queries,
+// or whose text search vector, which also covers the biography, contains one of them.
+func userQueriesToSql(queries []string) sq.Sqlizer {
+	predicate := sq.Or{}
+	for _, query := range queries {
+		predicate = append(predicate,
+			sq.Expr("? <% "+fieldSearchText, query),
+			sq.Expr(fieldSearchVector+" @@ plainto_tsquery('simple', ?)", query),
+		)
+	}
+
+	return predicate
+}
+
+// userRankToSql orders fuzzy search results by the best trigram similarity plus the text search rank.
+func userRankToSql(queries []string) (string, []any) {
+	similarities := make([]string, 0, len(queries))
+	tsQueries := make([]string, 0, len(queries))
+	args := make([]any, 0, 2*len(queries))
+	for _, query := range queries {
+		similarities = append(similarities, "word_similarity(?, "+fieldSearchText+")")
+		args = append(args, query)
+	}
+	for _, query := range queries {
+		tsQueries = append(tsQueries, "plainto_tsquery('simple', ?)")
+		args = append(args, query)
+	}
+
+	rank := "GREATEST(" + strings.Join(similarities, separator) + ") + " +
+		"ts_rank(" + fieldSearchVector + ", " + strings.Join(tsQueries, " || ") + ") DESC"
+	return rank, args
+}
@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// GetIdentity returns the identity linked to the subject of the provider.
+func (s *Storage) GetIdentity(ctx context.Context, provider, subject string) (*model.Identity, error) {
+	sql, args, err := sq.Select(identityFields...).
+		From(IdentityTable).
+		Where(sq.Eq{
+			fieldProvider:  provider,
+			fieldSubject:   subject,
+			fieldDeletedAt: nil,
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity identityEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return identityEntityToModel(entity), nil
+}
+
+// CreateIdentity links the subject of the provider to the user.
+func (s *Storage) CreateIdentity(ctx context.Context, identity *model.Identity) (*model.Identity, error) {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Insert(IdentityTable).
+		Columns(identityFields...).
+		Values(identity.IdentityID, identity.UserID, identity.Provider, identity.Subject, nullString(identity.Email), now).
+		Suffix(returningIdentity).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity identityEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return identityEntityToModel(entity), nil
+}
+
+// ListIdentities returns the external identities linked to the user.
+func (s *Storage) ListIdentities(ctx context.Context, userID model.UserID) ([]*model.Identity, error) {
+	sql, args, err := sq.Select(identityFields...).
+		From(IdentityTable).
+		Where(sq.Eq{
+			fieldUserID:    userID.String(),
+			fieldDeletedAt: nil,
+		}).
+		OrderBy(fieldCreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []identityEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	identities := make([]*model.Identity, 0, len(entities))
+	for _, entity := range entities {
+		identities = append(identities, identityEntityToModel(entity))
+	}
+
+	return identities, nil
+}
+
+// DeleteIdentity unlinks the identity from the user by updating the deleted_at field with the current timestamp.
+func (s *Storage) DeleteIdentity(ctx context.Context, userID model.UserID, id model.IdentityID) error {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Update(IdentityTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldUserID:    userID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldDeletedAt, now).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.WrapNotFoundError(fmt.Errorf("identity not found"), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+type identityEntity struct {
+	ID        string         `db:"id"`
+	UserID    string         `db:"user_id"`
+	Provider  string         `db:"provider"`
+	Subject   string         `db:"subject"`
+	Email     sql.NullString `db:"email"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// identityEntityToModel converts an identity entity to an identity model and returns a pointer to it.
+func identityEntityToModel(entity identityEntity) *model.Identity {
+	return &model.Identity{
+		IdentityID: model.IdentityID(entity.ID),
+		UserID:     model.UserID(entity.UserID),
+		Provider:   entity.Provider,
+		Subject:    entity.Subject,
+		Email:      entity.Email.String,
+		CreatedAt:  entity.CreatedAt,
+	}
+}
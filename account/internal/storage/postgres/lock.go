@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"pulse-auth/internal/job"
+	"time"
+)
+
+// TryLock runs fn while holding a session level advisory lock named after the job, so that only one of the
+// instances sharing the database runs it at a time. The last slot that ran and its outcome are recorded in
+// job_run_table. It reports false without running fn when another instance holds the lock or has already run the
+// slot.
+func (s *Storage) TryLock(ctx context.Context, name string, slot time.Time, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := s.conn.Connx(ctx)
+	if err != nil {
+		return false, fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	key := advisoryLockKey(name)
+	var locked bool
+	if err = conn.GetContext(ctx, &locked, "SELECT pg_try_advisory_lock($1)", key); err != nil {
+		return false, fmt.Errorf("try advisory lock: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		// ctx is canceled on shutdown, the lock must be released anyway. Should that fail, the connection is
+		// dropped instead of going back to the pool, which releases the lock too.
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			s.logger.Sugar().Warnf("advisory unlock %s: %v", name, err)
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	// A failed run keeps its slot as well, the next slot tries again.
+	result, err := conn.ExecContext(ctx, `
+INSERT INTO `+JobRunTable+` (name, scheduled_at, started_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET scheduled_at = excluded.scheduled_at, started_at = excluded.started_at,
+                                 finished_at = NULL, result = NULL, error = NULL
+WHERE `+JobRunTable+`.scheduled_at < excluded.scheduled_at`, name, slot, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("record job run: %w", err)
+	}
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("record job run: %w", err)
+	}
+	if recorded == 0 {
+		return false, nil
+	}
+
+	err = fn(ctx)
+	s.recordJobResult(ctx, name, slot, err)
+
+	return true, err
+}
+
+// recordJobResult stores the outcome of the run of the slot. The run has happened either way, so a failure to
+// record it is only logged.
+func (s *Storage) recordJobResult(ctx context.Context, name string, slot time.Time, runErr error) {
+	result, message := job.ResultOK, sql.NullString{}
+	if runErr != nil {
+		result, message = job.ResultFailed, sql.NullString{String: runErr.Error(), Valid: true}
+	}
+
+	// ctx is canceled on shutdown, the outcome of the run that just ended is recorded anyway.
+	_, err := s.conn.ExecContext(context.WithoutCancel(ctx), `
+UPDATE `+JobRunTable+` SET finished_at = $3, result = $4, error = $5
+WHERE name = $1 AND scheduled_at = $2`, name, slot, time.Now(), string(result), message)
+	if err != nil {
+		s.logger.Sugar().Warnf("record result of job %s: %v", name, err)
+	}
+}
+
+// LastRuns returns the last run of each job recorded in job_run_table, whichever instance made it. Runs that
+// haven't finished yet have a zero FinishedAt and no result.
+func (s *Storage) LastRuns(ctx context.Context) (map[string]job.Run, error) {
+	var entities []jobRunEntity
+	err := s.conn.SelectContext(ctx, &entities, `
+SELECT name, started_at, finished_at, result, error FROM `+JobRunTable)
+	if err != nil {
+		return nil, fmt.Errorf("select job runs: %w", err)
+	}
+
+	runs := make(map[string]job.Run, len(entities))
+	for _, entity := range entities {
+		runs[entity.Name] = job.Run{
+			StartedAt:  entity.StartedAt,
+			FinishedAt: entity.FinishedAt.Time,
+			Result:     job.Result(entity.Result.String),
+			Error:      entity.Error.String,
+		}
+	}
+
+	return runs, nil
+}
+
+type jobRunEntity struct {
+	Name       string         `db:"name"`
+	StartedAt  time.Time      `db:"started_at"`
+	FinishedAt sql.NullTime   `db:"finished_at"`
+	Result     sql.NullString `db:"result"`
+	Error      sql.NullString `db:"error"`
+}
+
+// advisoryLockKey maps the job name to the bigint key of its advisory lock.
+func advisoryLockKey(name string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte("pulse-job:" + name))
+	return int64(hash.Sum64())
+}
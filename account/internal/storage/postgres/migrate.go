@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/migrations"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// NewMigrator returns a goose provider for the embedded migrations. It holds a Postgres advisory lock
+// while migrating, so replicas starting at the same time apply each migration once.
+func (s *Storage) NewMigrator() (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("new postgres session locker: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, s.conn.DB, migrations.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("new goose provider: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Migrate applies the pending migrations.
+func (s *Storage) Migrate(ctx context.Context) error {
+	migrator, err := s.NewMigrator()
+	if err != nil {
+		return fmt.Errorf("new migrator: %w", err)
+	}
+
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("up: %w", err)
+	}
+
+	for _, result := range results {
+		s.logger.Sugar().Infof("applied migration %s in %s", result.Source.Path, result.Duration)
+	}
+
+	return nil
+}
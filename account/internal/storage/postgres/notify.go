@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// listenRetryDelay is how long Listen waits before reconnecting after the connection dropped.
+const listenRetryDelay = 5 * time.Second
+
+// Listener receives the notifications of a channel.
+type Listener interface {
+	// Subscribed is called each time the channel is subscribed. Notifications sent while the connection was
+	// down are lost.
+	Subscribed()
+	Notified(payload string)
+}
+
+// Notify sends the payload to the listeners of the channel on every instance. It runs outside the transaction
+// of the storage, so call it once the changes it announces are committed.
+func (s *Storage) Notify(ctx context.Context, channel, payload string) error {
+	_, err := s.conn.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	if err != nil {
+		return fmt.Errorf("notify %s: %w", channel, err)
+	}
+
+	return nil
+}
+
+// Listen delivers the notifications of the channel to the listener until ctx is canceled. It holds a connection
+// of its own outside the pool and reconnects when it drops.
+func (s *Storage) Listen(ctx context.Context, channel string, listener Listener) error {
+	for {
+		err := s.listen(ctx, channel, listener)
+		if ctx.Err() != nil {
+			return nil
+		}
+		s.logger.Sugar().Warnf("listen %s: %v", channel, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (s *Storage) listen(ctx context.Context, channel string, listener Listener) error {
+	conn, err := pgx.Connect(ctx, s.dsn)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	listener.Subscribed()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+		listener.Notified(notification.Payload)
+	}
+}
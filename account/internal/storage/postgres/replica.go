@@ -0,0 +1,247 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"net/url"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/storage"
+	"sync/atomic"
+	"time"
+)
+
+// defaultReplicaCheckInterval replaces a replica check interval that isn't positive.
+const defaultReplicaCheckInterval = 5 * time.Second
+
+// replicaCheckTimeout bounds a single health check, so a hanging replica doesn't delay the others.
+const replicaCheckTimeout = 2 * time.Second
+
+// replicaLagQuery returns how many seconds the replica is behind the primary. A replica that has replayed
+// everything it received is up to date however old its last transaction is.
+const replicaLagQuery = `
+SELECT CASE
+    WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+type replica struct {
+	name    string
+	conn    *sqlx.DB
+	healthy atomic.Bool
+}
+
+// replicaSet balances reads over the healthy replicas.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+	maxLag   time.Duration
+	logger   *zap.Logger
+}
+
+// newReplicaSet opens the configured replicas and checks them once, so that healthy ones serve reads right away.
+// It returns nil without replicas. Replicas that are down are only logged, they join once they recover.
+func newReplicaSet(logger *zap.Logger, cfg config.StorageConfig) (*replicaSet, error) {
+	if len(cfg.Replicas) == 0 {
+		return nil, nil
+	}
+
+	interval := cfg.ReplicaCheckInterval
+	if interval <= 0 {
+		logger.Sugar().Warnf("replica check interval %s is not positive, checking every %s", interval, defaultReplicaCheckInterval)
+		interval = defaultReplicaCheckInterval
+	}
+
+	set := &replicaSet{
+		interval: interval,
+		maxLag:   cfg.ReplicaMaxLag,
+		logger:   logger,
+	}
+	for i, replicaConfig := range cfg.Replicas {
+		dsn := dataSourceName(replicaStorageConfig(cfg, replicaConfig))
+		conn, err := sqlx.Open("pgx", dsn)
+		if err != nil {
+			_ = set.close()
+			return nil, fmt.Errorf("open replica %d: %w", i+1, err)
+		}
+		setPoolLimits(conn, cfg)
+
+		replica := &replica{name: replicaName(i, dsn), conn: conn}
+		// Replicas start in rotation, so that the first check logs those that are down.
+		replica.healthy.Store(true)
+		set.replicas = append(set.replicas, replica)
+	}
+	set.check(context.Background())
+
+	return set, nil
+}
+
+// replicaStorageConfig returns the connection settings of the replica, taking those it doesn't set from the primary.
+func replicaStorageConfig(cfg config.StorageConfig, replica config.ReplicaConfig) config.StorageConfig {
+	cfg.DSN = replica.DSN
+	if replica.Host != "" {
+		cfg.Host = replica.Host
+	}
+	if replica.Port != 0 {
+		cfg.Port = replica.Port
+	}
+
+	return cfg
+}
+
+// replicaName identifies the replica in logs without its credentials.
+func replicaName(i int, dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// pick returns the healthy replicas in turn, or nil when none is healthy.
+func (r *replicaSet) pick() *replica {
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		if replica.healthy.Load() {
+			healthy = append(healthy, replica)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	return healthy[r.next.Add(1)%uint64(len(healthy))]
+}
+
+// check updates the health of every replica and logs the replicas that left or rejoined the rotation.
+func (r *replicaSet) check(ctx context.Context) {
+	for _, replica := range r.replicas {
+		err := r.checkReplica(ctx, replica)
+		healthy := err == nil
+		if replica.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			r.logger.Sugar().Infof("read replica %s is healthy", replica.name)
+		} else {
+			r.logger.Sugar().Warnf("read replica %s is unhealthy: %v", replica.name, err)
+		}
+	}
+}
+
+func (r *replicaSet) checkReplica(ctx context.Context, replica *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var lag float64
+	if err := replica.conn.GetContext(ctx, &lag, replicaLagQuery); err != nil {
+		return fmt.Errorf("query lag: %w", err)
+	}
+	if r.maxLag > 0 && time.Duration(lag*float64(time.Second)) > r.maxLag {
+		return fmt.Errorf("lag of %.1fs exceeds %s", lag, r.maxLag)
+	}
+
+	return nil
+}
+
+// close closes the connections of every replica and returns the first error.
+func (r *replicaSet) close() error {
+	var errs []error
+	for _, replica := range r.replicas {
+		if err := replica.conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close replica %s: %w", replica.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckReplicas checks the health of the read replicas every ReplicaCheckInterval until ctx is canceled.
+// It returns right away when no replicas are configured.
+func (s *Storage) CheckReplicas(ctx context.Context) error {
+	if s.replicas == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(s.replicas.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.replicas.check(ctx)
+		}
+	}
+}
+
+// reader returns where the read-only queries of ctx run: a healthy replica, falling back to the primary
+// when none is healthy. Transactions and contexts marked with storage.WithPrimary always read the primary.
+func (s *Storage) reader(ctx context.Context) queryer {
+	if s.replicas == nil || storage.UsesPrimary(ctx) {
+		return s.db
+	}
+	if _, ok := s.db.(*sqlx.Tx); ok {
+		return s.db
+	}
+
+	replica := s.replicas.pick()
+	if replica == nil {
+		return s.db
+	}
+
+	return &replicaReader{replica: replica, primary: s.db, logger: s.logger}
+}
+
+// replicaReader runs queries on a replica and retries them on the primary when the replica fails.
+// A replica that can't be reached is taken out of rotation until its next successful health check.
+type replicaReader struct {
+	replica *replica
+	primary queryer
+	logger  *zap.Logger
+}
+
+func (r *replicaReader) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	err := r.replica.conn.GetContext(ctx, dest, query, args...)
+	if !r.failed(ctx, err) {
+		return err
+	}
+
+	return r.primary.GetContext(ctx, dest, query, args...)
+}
+
+func (r *replicaReader) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	err := r.replica.conn.SelectContext(ctx, dest, query, args...)
+	if !r.failed(ctx, err) {
+		return err
+	}
+
+	return r.primary.SelectContext(ctx, dest, query, args...)
+}
+
+// ExecContext runs on the primary, replicas are read-only.
+func (r *replicaReader) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// failed reports whether the query should be retried on the primary. Missing rows are an answer, and a canceled
+// request isn't worth retrying. Errors reported by the server, like a query canceled by a conflict with
+// recovery, don't take the replica out of rotation.
+func (r *replicaReader) failed(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) && r.replica.healthy.CompareAndSwap(true, false) {
+		r.logger.Sugar().Warnf("read replica %s is unhealthy: %v", r.replica.name, err)
+	}
+
+	return true
+}
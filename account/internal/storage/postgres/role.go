@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// GetRoles returns the roles granted to the user.
+func (s *Storage) GetRoles(ctx context.Context, id model.UserID) ([]string, error) {
+	sql, args, err := sq.Select(fieldRole).
+		From(UserRoleTable).
+		Where(sq.Eq{fieldUserID: id.String()}).
+		OrderBy(fieldRole).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var roles []string
+	err = s.db.SelectContext(ctx, &roles, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return roles, nil
+}
+
+// SetRoles replaces the roles granted to the user in a single statement.
+func (s *Storage) SetRoles(ctx context.Context, id model.UserID, roles []string) error {
+	if roles == nil {
+		roles = []string{}
+	}
+
+	const query = `
+WITH deleted AS (
+    DELETE FROM ` + UserRoleTable + ` WHERE user_id = $1 AND NOT (role = ANY ($2))
+)
+INSERT INTO ` + UserRoleTable + ` (user_id, role, created_at)
+SELECT $1, unnest($2::TEXT[]), $3
+ON CONFLICT (user_id, role) DO NOTHING`
+
+	_, err := s.db.ExecContext(ctx, query, id.String(), roles, time.Now().Truncate(time.Millisecond))
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	sq "github.com/Masterminds/squirrel"
 	"pulse-auth/internal/model"
@@ -45,9 +46,14 @@ func (s *Storage) CreateToken(ctx context.Context, params *model.TokenWithMetada
 	}
 
 	now := time.Now().Truncate(time.Millisecond)
+	// Client tokens are not a sign in of the user, they never pass a reauthentication check.
+	authenticatedAt := sql.NullTime{Time: now, Valid: params.ClientID == ""}
 	sql, args, err := sq.Insert(TokenTable).
 		Columns(tokenFields...).
-		Values(params.TokenID, params.UserID, params.Token, now, params.AlivedAt).
+		Values(params.TokenID, params.UserID, params.Token, now, params.AlivedAt, nullString(params.ClientID.String()),
+			authenticatedAt, now, nullString(params.Device.UserAgent), nullString(params.Device.IPAddress),
+			nullString(params.Device.ClientName), nullString(model.FormatScopes(params.Scopes)),
+		).
 		Suffix(returningToken).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
@@ -64,6 +70,34 @@ func (s *Storage) CreateToken(ctx context.Context, params *model.TokenWithMetada
 	return tokenEntityToModel(entity), nil
 }
 
+// GetToken returns a token that is neither revoked nor expired by its value.
+func (s *Storage) GetToken(ctx context.Context, token string) (*model.Token, error) {
+	sql, args, err := sq.Select(tokenFields...).
+		From(TokenTable).
+		Where(
+			sq.Eq{
+				fieldToken:     token,
+				fieldDeletedAt: nil,
+			},
+			sq.Gt{
+				fieldAlivedAt: time.Now(),
+			},
+		).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity tokenEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return tokenEntityToModel(entity), nil
+}
+
 // RevokeToken revokes a token by updating the deleted_at field with the current timestamp in the database.
 func (s *Storage) RevokeToken(ctx context.Context, params *model.Token) error {
 	now := time.Now().Truncate(time.Millisecond)
@@ -94,7 +128,173 @@ func (s *Storage) RevokeToken(ctx context.Context, params *model.Token) error {
 	}
 
 	if rowsAffected == 0 {
-		return utils.WrapNotFoundError(err, utils.NotFoundMessage)
+		return utils.WrapNotFoundError(fmt.Errorf("token not found"), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+// ListSessions returns the user's tokens that are neither revoked nor expired, most recently used first.
+func (s *Storage) ListSessions(ctx context.Context, userID model.UserID) ([]*model.Session, error) {
+	sql, args, err := sq.Select(tokenFields...).
+		From(TokenTable).
+		Where(
+			sq.Eq{
+				fieldUserID:    userID.String(),
+				fieldDeletedAt: nil,
+			},
+			sq.Gt{
+				fieldAlivedAt: time.Now(),
+			},
+		).
+		OrderBy(fieldLastUsedAt + " DESC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []tokenEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	sessions := make([]*model.Session, 0, len(entities))
+	for _, entity := range entities {
+		sessions = append(sessions, tokenEntityToSession(entity))
+	}
+
+	return sessions, nil
+}
+
+// ListSessionHistory returns every token of the user that is still stored, revoked and expired ones included,
+// most recently created first.
+func (s *Storage) ListSessionHistory(ctx context.Context, userID model.UserID) ([]*model.Session, error) {
+	sql, args, err := sq.Select(tokenFields...).
+		Column(fieldDeletedAt+" AS revoked_at").
+		From(TokenTable).
+		Where(sq.Eq{fieldUserID: userID.String()}).
+		OrderBy(fieldCreatedAt+" DESC", fieldID).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []sessionHistoryEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	sessions := make([]*model.Session, 0, len(entities))
+	for _, entity := range entities {
+		session := tokenEntityToSession(entity.tokenEntity)
+		session.RevokedAt = entity.RevokedAt.Time
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// TouchToken records that the token has just been used.
+func (s *Storage) TouchToken(ctx context.Context, id model.TokenID) error {
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldLastUsedAt, time.Now().Truncate(time.Millisecond)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	_, err = s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	return nil
+}
+
+// ReauthenticateToken records that the user has just proved their credentials again in the session.
+func (s *Storage) ReauthenticateToken(ctx context.Context, id model.TokenID) error {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldClientID:  nil,
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldAuthenticatedAt, now).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.WrapNotFoundError(fmt.Errorf("token not found"), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+// RevokeClientTokens revokes every active token the user has issued to the client.
+func (s *Storage) RevokeClientTokens(ctx context.Context, userID model.UserID, clientID model.ClientID) error {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldUserID:    userID.String(),
+			fieldClientID:  clientID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldDeletedAt, now).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	_, err = s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	return nil
+}
+
+// RevokeUserTokens revokes every active token of the user, signing them out everywhere.
+func (s *Storage) RevokeUserTokens(ctx context.Context, userID model.UserID) error {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldUserID:    userID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldDeletedAt, now).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	_, err = s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
 	}
 
 	return nil
@@ -130,18 +330,76 @@ func (s *Storage) RefreshToken(ctx context.Context, params *model.TokenWithMetad
 }
 
 type tokenEntity struct {
-	ID        string    `db:"id"`
-	UserID    string    `db:"user_id"`
-	Token     string    `db:"token"`
-	CreatedAt time.Time `db:"created_at"`
-	DeletedAt time.Time `db:"deleted_at"`
-	AlivedAt  time.Time `db:"alived_at"`
+	ID              string         `db:"id"`
+	UserID          string         `db:"user_id"`
+	ClientID        sql.NullString `db:"client_id"`
+	Token           string         `db:"token"`
+	CreatedAt       time.Time      `db:"created_at"`
+	DeletedAt       time.Time      `db:"deleted_at"`
+	AlivedAt        time.Time      `db:"alived_at"`
+	AuthenticatedAt sql.NullTime   `db:"authenticated_at"`
+	LastUsedAt      time.Time      `db:"last_used_at"`
+	UserAgent       sql.NullString `db:"user_agent"`
+	IPAddress       sql.NullString `db:"ip_address"`
+	ClientName      sql.NullString `db:"client_name"`
+	Scopes          sql.NullString `db:"scopes"`
+}
+
+// sessionHistoryEntity is a token row with the time it was revoked at, NULL while the token is active.
+type sessionHistoryEntity struct {
+	tokenEntity
+	RevokedAt sql.NullTime `db:"revoked_at"`
 }
 
 // tokenEntityToModel converts a token entity to a token model and returns a pointer to it.
 func tokenEntityToModel(entity tokenEntity) *model.Token {
 	return &model.Token{
-		UserID: model.UserID(entity.UserID),
-		Token:  entity.Token,
+		TokenID:         model.TokenID(entity.ID),
+		UserID:          model.UserID(entity.UserID),
+		ClientID:        model.ClientID(entity.ClientID.String),
+		Scopes:          model.ParseScopes(entity.Scopes.String),
+		Token:           entity.Token,
+		AuthenticatedAt: entity.AuthenticatedAt.Time,
+		LastUsedAt:      entity.LastUsedAt,
+	}
+}
+
+// tokenEntityToSession converts a token entity to a session with the device it was issued to.
+func tokenEntityToSession(entity tokenEntity) *model.Session {
+	return &model.Session{
+		Token: *tokenEntityToModel(entity),
+		Device: model.Device{
+			UserAgent:  entity.UserAgent.String,
+			IPAddress:  entity.IPAddress.String,
+			ClientName: entity.ClientName.String,
+		},
+		CreatedAt: entity.CreatedAt,
+		ExpiresAt: entity.AlivedAt,
+	}
+}
+
+// DeleteExpiredTokens removes the tokens that expired or were revoked before the given time.
+func (s *Storage) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	sql, args, err := sq.Delete(TokenTable).
+		Where(sq.Or{
+			sq.Lt{fieldAlivedAt: before},
+			sq.Lt{fieldDeletedAt: before},
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return 0, utils.WrapSqlError(err)
 	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, utils.WrapInternalError(err)
+	}
+
+	return deleted, nil
 }
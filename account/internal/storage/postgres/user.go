@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"pulse-auth/internal/model"
 	"pulse-auth/internal/utils"
@@ -18,6 +19,7 @@ func (s *Storage) LoginUser(ctx context.Context, userLogin *model.UserLogin) (*m
 		Where(sq.Eq{
 			fieldUsername:       userLogin.Username,
 			fieldHashedPassword: userLogin.HashedPassword,
+			fieldDeletedAt:      nil,
 		}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
@@ -38,13 +40,21 @@ func (s *Storage) LoginUser(ctx context.Context, userLogin *model.UserLogin) (*m
 func (s *Storage) CreateUser(ctx context.Context, params *model.UserRegister) (*model.User, error) {
 	err := params.Validate()
 	if err != nil {
-		return nil, fmt.Errorf("params validate: %w", err)
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+	canonical, err := model.NormalizeUsername(params.Username)
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
 	}
 	now := time.Now().Truncate(time.Millisecond)
+	visibility := model.DefaultProfileVisibility()
 	sql, args, err := sq.Insert(UserTable).
 		Columns(userFields...).
+		Columns(fieldUsernameCanonical).
 		Values(params.ID, params.Username, params.HashedPassword, params.FirstName, params.SecondName,
-			params.Sex, params.Birthdate, params.Biography, params.City, now,
+			params.Sex, params.Birthdate, params.Biography, params.City, now, now,
+			visibility.Sex, visibility.Birthdate, visibility.Biography, visibility.City, true,
+			canonical,
 		).
 		Suffix(returningUser).
 		PlaceholderFormat(sq.Dollar).
@@ -76,7 +86,7 @@ func (s *Storage) GetUserByID(ctx context.Context, id model.UserID) (*model.User
 	}
 
 	var entity userEntity
-	err = s.db.GetContext(ctx, &entity, sql, args...)
+	err = s.reader(ctx).GetContext(ctx, &entity, sql, args...)
 	if err != nil {
 		return nil, utils.WrapSqlError(err)
 	}
@@ -84,6 +94,65 @@ func (s *Storage) GetUserByID(ctx context.Context, id model.UserID) (*model.User
 	return userEntityToModel(entity), nil
 }
 
+// GetUserWithDeleted retrieves a user by ID, including users deleted but not purged yet.
+func (s *Storage) GetUserWithDeleted(ctx context.Context, id model.UserID) (*model.User, error) {
+	sql, args, err := sq.Select(userFields...).
+		Columns(fieldDeletedAt, fieldDeletedBy).
+		From(UserTable).
+		Where(sq.Eq{
+			fieldID:       id.String(),
+			fieldPurgedAt: nil,
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity deletedUserEntity
+	err = s.reader(ctx).GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	user := userEntityToModel(entity.userEntity)
+	user.DeletedAt = entity.DeletedAt.Time
+	user.DeletedBy = model.DeletedBy(entity.DeletedBy.String)
+
+	return user, nil
+}
+
+// GetUsersByIDs returns the active users among the ids in a single query. Unknown ids are skipped.
+func (s *Storage) GetUsersByIDs(ctx context.Context, ids []model.UserID) ([]*model.User, error) {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+
+	sql, args, err := sq.Select(userFields...).
+		From(UserTable).
+		Where(sq.Expr(fieldID+" = ANY(?)", values)).
+		Where(sq.Eq{fieldDeletedAt: nil}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []userEntity
+	err = s.reader(ctx).SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	users := make([]*model.User, 0, len(entities))
+	for _, entity := range entities {
+		users = append(users, userEntityToModel(entity))
+	}
+
+	return users, nil
+}
+
 // SearchUser searches for a user in the storage using first and last name.
 func (s *Storage) SearchUser(ctx context.Context, firstName, lastName string) (*model.User, error) {
 	sql, args, err := sq.Select(userFields...).
@@ -91,6 +160,7 @@ func (s *Storage) SearchUser(ctx context.Context, firstName, lastName string) (*
 		Where(sq.Eq{
 			fieldFirstName:  firstName,
 			fieldSecondName: lastName,
+			fieldSearchable: true,
 			fieldDeletedAt:  nil,
 		}).
 		PlaceholderFormat(sq.Dollar).
@@ -99,6 +169,246 @@ func (s *Storage) SearchUser(ctx context.Context, firstName, lastName string) (*
 		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
 	}
 
+	var entity userEntity
+	err = s.reader(ctx).GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// SearchUsers returns a page of active users matching the filters and the number of matches across all pages.
+func (s *Storage) SearchUsers(ctx context.Context, params *model.UserSearchParams) ([]*model.User, uint64, error) {
+	// The total and the page are read from the same replica.
+	db := s.reader(ctx)
+	where := userSearchToSql(params)
+	if len(params.Queries) > 0 {
+		where = append(where, userQueriesToSql(params.Queries))
+	}
+	countSql, countArgs, err := sq.Select("COUNT(*)").
+		From(UserTable).
+		Where(where).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var total uint64
+	err = db.GetContext(ctx, &total, countSql, countArgs...)
+	if err != nil {
+		return nil, 0, utils.WrapSqlError(err)
+	}
+
+	builder := sq.Select(userFields...).
+		From(UserTable).
+		Limit(params.Limit)
+	if len(params.Queries) > 0 {
+		rank, rankArgs := userRankToSql(params.Queries)
+		builder = builder.Where(where).OrderByClause(rank, rankArgs...).OrderBy(fieldID).Offset(params.Offset)
+	} else {
+		if params.After != nil {
+			where = append(where, userCursorToSql(params.After))
+		}
+		builder = builder.Where(where).OrderBy(userSearchOrder...)
+	}
+
+	sql, args, err := builder.
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []userEntity
+	err = db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, 0, utils.WrapSqlError(err)
+	}
+
+	users := make([]*model.User, 0, len(entities))
+	for _, entity := range entities {
+		users = append(users, userEntityToModel(entity))
+	}
+
+	return users, total, nil
+}
+
+// LockUser takes a row lock on the active user, it is held until the transaction ends.
+func (s *Storage) LockUser(ctx context.Context, id model.UserID) error {
+	sql, args, err := sq.Select(fieldID).
+		From(UserTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var locked string
+	err = s.db.GetContext(ctx, &locked, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the user's password hash. An empty hash leaves the account without a password.
+func (s *Storage) UpdatePassword(ctx context.Context, id model.UserID, hashedPassword string) error {
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldHashedPassword, hashedPassword).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.WrapNotFoundError(fmt.Errorf("user not found"), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+// ListUsers returns a page of active users matching the conditions and the number of matches across all pages.
+func (s *Storage) ListUsers(ctx context.Context, params *model.UserListParams) ([]*model.User, uint64, error) {
+	// The total and the page are read from the same replica.
+	db := s.reader(ctx)
+	where := sq.And{sq.Eq{fieldDeletedAt: nil}}
+	for _, condition := range params.Conditions {
+		predicate, err := userConditionToSql(condition)
+		if err != nil {
+			return nil, 0, utils.WrapValidationError(fmt.Errorf("condition: %w", err))
+		}
+		where = append(where, predicate)
+	}
+
+	countSql, countArgs, err := sq.Select("COUNT(*)").
+		From(UserTable).
+		Where(where).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var total uint64
+	err = db.GetContext(ctx, &total, countSql, countArgs...)
+	if err != nil {
+		return nil, 0, utils.WrapSqlError(err)
+	}
+
+	sql, args, err := sq.Select(userFields...).
+		From(UserTable).
+		Where(where).
+		OrderBy(fieldCreatedAt, fieldID).
+		Offset(params.Offset).
+		Limit(params.Limit).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []userEntity
+	err = db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, 0, utils.WrapSqlError(err)
+	}
+
+	users := make([]*model.User, 0, len(entities))
+	for _, entity := range entities {
+		users = append(users, userEntityToModel(entity))
+	}
+
+	return users, total, nil
+}
+
+// UpdateUser changes the fields set in the update and returns the updated user.
+func (s *Storage) UpdateUser(ctx context.Context, update *model.UserUpdate) (*model.User, error) {
+	err := update.Validate()
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+	if update.Empty() {
+		return s.GetUserByID(ctx, update.ID)
+	}
+
+	builder := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldID:        update.ID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldUpdatedAt, time.Now().Truncate(time.Millisecond))
+	if update.Username != nil {
+		canonical, err := model.NormalizeUsername(*update.Username)
+		if err != nil {
+			return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+		}
+		builder = builder.Set(fieldUsername, *update.Username).Set(fieldUsernameCanonical, canonical)
+	}
+	if update.FirstName != nil {
+		builder = builder.Set(fieldFirstName, *update.FirstName)
+	}
+	if update.SecondName != nil {
+		builder = builder.Set(fieldSecondName, *update.SecondName)
+	}
+	if update.Sex != nil {
+		builder = builder.Set(fieldSex, *update.Sex)
+	}
+	if update.Birthdate != nil {
+		builder = builder.Set(fieldBirthdate, *update.Birthdate)
+	}
+	if update.Biography != nil {
+		builder = builder.Set(fieldBiography, *update.Biography)
+	}
+	if update.City != nil {
+		builder = builder.Set(fieldCity, *update.City)
+	}
+	if update.SexVisibility != nil {
+		builder = builder.Set(fieldSexVisibility, *update.SexVisibility)
+	}
+	if update.BirthdateVisibility != nil {
+		builder = builder.Set(fieldBirthdateVisibility, *update.BirthdateVisibility)
+	}
+	if update.BiographyVisibility != nil {
+		builder = builder.Set(fieldBiographyVisibility, *update.BiographyVisibility)
+	}
+	if update.CityVisibility != nil {
+		builder = builder.Set(fieldCityVisibility, *update.CityVisibility)
+	}
+	if update.Searchable != nil {
+		builder = builder.Set(fieldSearchable, *update.Searchable)
+	}
+
+	sql, args, err := builder.
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
 	var entity userEntity
 	err = s.db.GetContext(ctx, &entity, sql, args...)
 	if err != nil {
@@ -108,6 +418,142 @@ func (s *Storage) SearchUser(ctx context.Context, firstName, lastName string) (*
 	return userEntityToModel(entity), nil
 }
 
+// DeleteUser soft-deletes the user by setting deleted_at and who deleted it.
+func (s *Storage) DeleteUser(ctx context.Context, id model.UserID, by model.DeletedBy) error {
+	now := time.Now().Truncate(time.Millisecond)
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldDeletedAt, now).
+		Set(fieldDeletedBy, string(by)).
+		Set(fieldUpdatedAt, now).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return utils.WrapSqlError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.WrapNotFoundError(fmt.Errorf("user not found"), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+// RestoreUser undeletes the user matching the credentials if they deleted it after deletedAfter and it's not purged
+// yet.
+func (s *Storage) RestoreUser(ctx context.Context, userLogin *model.UserLogin, deletedAfter time.Time) (*model.User, error) {
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldUsername:       userLogin.Username,
+			fieldHashedPassword: userLogin.HashedPassword,
+			fieldPurgedAt:       nil,
+			fieldDeletedBy:      string(model.DeletedByUser),
+		}).
+		Where(sq.Gt{fieldDeletedAt: deletedAfter}).
+		Set(fieldDeletedAt, nil).
+		Set(fieldDeletedBy, nil).
+		Set(fieldUpdatedAt, time.Now().Truncate(time.Millisecond)).
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity userEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// RestoreUserByID undeletes the user if it was deleted by the given party after deletedAfter and not purged yet. It
+// restores accounts whose owner proved who they are some other way than with the password, like a linked identity.
+func (s *Storage) RestoreUserByID(ctx context.Context, id model.UserID, deletedAfter time.Time, by model.DeletedBy) (*model.User, error) {
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldPurgedAt:  nil,
+			fieldDeletedBy: string(by),
+		}).
+		Where(sq.Gt{fieldDeletedAt: deletedAfter}).
+		Set(fieldDeletedAt, nil).
+		Set(fieldDeletedBy, nil).
+		Set(fieldUpdatedAt, time.Now().Truncate(time.Millisecond)).
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity userEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// PurgeUsers anonymizes users deleted before deletedBefore and removes everything linked to them.
+// The user row is kept with a placeholder username so that foreign keys and audit references stay valid.
+func (s *Storage) PurgeUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	const query = `
+WITH purged AS (
+    UPDATE ` + UserTable + `
+    SET username           = 'deleted-' || id,
+        username_canonical = 'deleted-' || id,
+        hashed_password    = '',
+        first_name         = NULL,
+        second_name        = NULL,
+        sex                = NULL,
+        birthdate          = NULL,
+        biography          = NULL,
+        city               = NULL,
+        purged_at          = $2,
+        updated_at         = $2
+    WHERE deleted_at < $1 AND purged_at IS NULL
+    RETURNING id
+), tokens AS (
+    DELETE FROM ` + TokenTable + ` WHERE user_id IN (SELECT id FROM purged)
+), consents AS (
+    DELETE FROM ` + ConsentTable + ` WHERE user_id IN (SELECT id FROM purged)
+), identities AS (
+    DELETE FROM ` + IdentityTable + ` WHERE user_id IN (SELECT id FROM purged)
+), roles AS (
+    DELETE FROM ` + UserRoleTable + ` WHERE user_id IN (SELECT id FROM purged)
+), exports AS (
+    DELETE FROM ` + ExportTable + ` WHERE user_id IN (SELECT id FROM purged)
+), usernames AS (
+    DELETE FROM ` + UsernameHistoryTable + ` WHERE user_id IN (SELECT id FROM purged)
+)
+SELECT COUNT(*) FROM purged`
+
+	var purged int64
+	err := s.db.GetContext(ctx, &purged, query, deletedBefore, time.Now().Truncate(time.Millisecond))
+	if err != nil {
+		return 0, utils.WrapSqlError(err)
+	}
+
+	return purged, nil
+}
+
 type userEntity struct {
 	ID             string    `db:"id"`
 	Username       string    `db:"username"`
@@ -119,6 +565,20 @@ type userEntity struct {
 	Biography      string    `db:"biography"`
 	City           string    `db:"city"`
 	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+
+	SexVisibility       string `db:"sex_visibility"`
+	BirthdateVisibility string `db:"birthdate_visibility"`
+	BiographyVisibility string `db:"biography_visibility"`
+	CityVisibility      string `db:"city_visibility"`
+	Searchable          bool   `db:"searchable"`
+}
+
+// deletedUserEntity is a user with the columns telling whether and by whom it was deleted.
+type deletedUserEntity struct {
+	userEntity
+	DeletedAt sql.NullTime   `db:"deleted_at"`
+	DeletedBy sql.NullString `db:"deleted_by"`
 }
 
 // userEntityToModel converts a user entity to a model User instance, mapping the attributes accordingly.
@@ -132,5 +592,16 @@ func userEntityToModel(entity userEntity) *model.User {
 		Birthdate:  entity.Birthdate,
 		Biography:  entity.Biography,
 		City:       entity.City,
+
+		HasPassword: entity.HashedPassword != "",
+		Visibility: model.ProfileVisibility{
+			Sex:       model.Visibility(entity.SexVisibility),
+			Birthdate: model.Visibility(entity.BirthdateVisibility),
+			Biography: model.Visibility(entity.BiographyVisibility),
+			City:      model.Visibility(entity.CityVisibility),
+		},
+		Searchable: entity.Searchable,
+		CreatedAt:  entity.CreatedAt,
+		UpdatedAt:  entity.UpdatedAt,
 	}
 }
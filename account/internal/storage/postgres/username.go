@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// GetUserByUsername returns the active user with the username, compared in canonical form.
+func (s *Storage) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("normalize username: %w", err), utils.NotFoundMessage)
+	}
+
+	sql, args, err := sq.Select(userFields...).
+		From(UserTable).
+		Where(sq.Eq{
+			fieldUsernameCanonical: canonical,
+			fieldDeletedAt:         nil,
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity userEntity
+	err = s.reader(ctx).GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// ChangeUsername renames the user and records the old username in the history, reserved until reservedUntil.
+// Usernames that predate normalization are recorded with an approximation of their canonical form.
+func (s *Storage) ChangeUsername(ctx context.Context, id model.UserID, username string, reservedUntil time.Time) (*model.User, error) {
+	if err := model.ValidateUsername(username); err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("validate username: %w", err))
+	}
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+	}
+
+	query := `
+WITH previous AS (
+    SELECT id, username, COALESCE(username_canonical, LOWER(NORMALIZE(username, NFKC))) AS username_canonical
+    FROM ` + UserTable + ` WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+), history AS (
+    INSERT INTO ` + UsernameHistoryTable + ` (user_id, username, username_canonical, created_at, reserved_until)
+    SELECT id, username, username_canonical, $4, $5 FROM previous WHERE username <> $2
+)
+UPDATE ` + UserTable + `
+SET username = $2, username_canonical = $3, updated_at = $4
+WHERE id IN (SELECT id FROM previous)
+` + returningUser
+
+	var entity userEntity
+	err = s.db.GetContext(ctx, &entity, query, id.String(), username, canonical, time.Now().Truncate(time.Millisecond), reservedUntil)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// ListUsernameHistory returns the previous usernames of the user, most recent first.
+func (s *Storage) ListUsernameHistory(ctx context.Context, id model.UserID) ([]*model.UsernameRecord, error) {
+	sql, args, err := sq.Select(usernameHistoryFields...).
+		From(UsernameHistoryTable).
+		Where(sq.Eq{fieldUserID: id.String()}).
+		OrderBy(fieldCreatedAt + " DESC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []usernameRecordEntity
+	err = s.reader(ctx).SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	records := make([]*model.UsernameRecord, 0, len(entities))
+	for _, entity := range entities {
+		records = append(records, usernameRecordEntityToModel(entity))
+	}
+
+	return records, nil
+}
+
+// GetUsernameRecord returns the latest time somebody gave up the username, compared in canonical form.
+func (s *Storage) GetUsernameRecord(ctx context.Context, username string) (*model.UsernameRecord, error) {
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("normalize username: %w", err), utils.NotFoundMessage)
+	}
+
+	sql, args, err := sq.Select(usernameHistoryFields...).
+		From(UsernameHistoryTable).
+		Where(sq.Eq{fieldUsernameCanonical: canonical}).
+		OrderBy(fieldCreatedAt + " DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity usernameRecordEntity
+	err = s.reader(ctx).GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, utils.WrapSqlError(err)
+	}
+
+	return usernameRecordEntityToModel(entity), nil
+}
+
+type usernameRecordEntity struct {
+	UserID        string    `db:"user_id"`
+	Username      string    `db:"username"`
+	CreatedAt     time.Time `db:"created_at"`
+	ReservedUntil time.Time `db:"reserved_until"`
+}
+
+// usernameRecordEntityToModel converts a username history entity to a username record model.
+func usernameRecordEntityToModel(entity usernameRecordEntity) *model.UsernameRecord {
+	return &model.UsernameRecord{
+		UserID:        model.UserID(entity.UserID),
+		Username:      entity.Username,
+		ChangedAt:     entity.CreatedAt,
+		ReservedUntil: entity.ReservedUntil,
+	}
+}
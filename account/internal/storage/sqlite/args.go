@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"database/sql"
+	"net/http"
+	"pulse-auth/internal/utils"
+	"strings"
+	"time"
+)
+
+const (
+	UserTable     = "user_table"
+	TokenTable    = "token_table"
+	ConsentTable  = "consent_table"
+	IdentityTable = "identity_table"
+	UserRoleTable = "user_role_table"
+	ExportTable   = "export_table"
+
+	UsernameHistoryTable = "username_history_table"
+)
+
+const (
+	returning = "RETURNING "
+	separator = ","
+)
+
+const (
+	fieldID       = "id"
+	fieldUserID   = "user_id"
+	fieldClientID = "client_id"
+
+	fieldUsername          = "username"
+	fieldUsernameCanonical = "username_canonical"
+	fieldHashedPassword    = "hashed_password"
+	fieldFirstName         = "first_name"
+	fieldSecondName        = "second_name"
+	fieldSex               = "sex"
+	fieldBirthdate         = "birthdate"
+	fieldBiography         = "biography"
+	fieldCity              = "city"
+
+	fieldUsernameLower   = "username_lower"
+	fieldFirstNameLower  = "first_name_lower"
+	fieldSecondNameLower = "second_name_lower"
+	fieldCityLower       = "city_lower"
+	fieldSearchText      = "search_text"
+
+	fieldSexVisibility       = "sex_visibility"
+	fieldBirthdateVisibility = "birthdate_visibility"
+	fieldBiographyVisibility = "biography_visibility"
+	fieldCityVisibility      = "city_visibility"
+	fieldSearchable          = "searchable"
+
+	fieldCreatedAt = "created_at"
+	fieldUpdatedAt = "updated_at"
+	fieldDeletedAt = "deleted_at"
+	fieldDeletedBy = "deleted_by"
+	fieldPurgedAt  = "purged_at"
+
+	fieldToken           = "token"
+	fieldAlivedAt        = "alived_at"
+	fieldAuthenticatedAt = "authenticated_at"
+	fieldLastUsedAt      = "last_used_at"
+	fieldUserAgent       = "user_agent"
+	fieldIPAddress       = "ip_address"
+	fieldClientName      = "client_name"
+
+	fieldScopes = "scopes"
+
+	fieldRole = "role"
+
+	fieldProvider = "provider"
+	fieldSubject  = "subject"
+	fieldEmail    = "email"
+
+	fieldReservedUntil = "reserved_until"
+
+	fieldStatus    = "status"
+	fieldError     = "error"
+	fieldArchive   = "archive"
+	fieldExpiresAt = "expires_at"
+)
+
+// userSearchOrder is the order of search results, matching the cursor and idx_user_table_search_order.
+var userSearchOrder = []string{fieldSecondNameLower, fieldFirstNameLower, fieldID}
+
+var (
+	userFields = []string{
+		fieldID, fieldUsername, fieldUsernameCanonical, fieldHashedPassword, fieldFirstName, fieldSecondName,
+		fieldSex, fieldBirthdate, fieldBiography, fieldCity, fieldCreatedAt, fieldUpdatedAt,
+		fieldSexVisibility, fieldBirthdateVisibility, fieldBiographyVisibility, fieldCityVisibility, fieldSearchable,
+	}
+	tokenFields = []string{
+		fieldID, fieldUserID, fieldToken, fieldCreatedAt, fieldAlivedAt, fieldClientID, fieldAuthenticatedAt,
+		fieldLastUsedAt, fieldUserAgent, fieldIPAddress, fieldClientName, fieldScopes,
+	}
+	consentFields         = []string{fieldUserID, fieldClientID, fieldScopes, fieldCreatedAt, fieldUpdatedAt}
+	identityFields        = []string{fieldID, fieldUserID, fieldProvider, fieldSubject, fieldEmail, fieldCreatedAt}
+	usernameHistoryFields = []string{fieldUserID, fieldUsername, fieldCreatedAt, fieldReservedUntil}
+	exportFields          = []string{fieldID, fieldUserID, fieldStatus, fieldError, fieldCreatedAt, fieldUpdatedAt, fieldExpiresAt}
+
+	returningUser     = returning + strings.Join(userFields, separator)
+	returningToken    = returning + strings.Join(tokenFields, separator)
+	returningConsent  = returning + strings.Join(consentFields, separator)
+	returningIdentity = returning + strings.Join(identityFields, separator)
+	returningExport   = returning + strings.Join(exportFields, separator)
+)
+
+// nullString stores empty optional values as NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// millis is how times are stored, as Unix milliseconds.
+func millis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// nullMillis stores the zero time as NULL.
+func nullMillis(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: !t.IsZero()}
+}
+
+func fromMillis(value int64) time.Time {
+	return time.UnixMilli(value)
+}
+
+func fromNullMillis(value sql.NullInt64) time.Time {
+	if !value.Valid {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(value.Int64)
+}
+
+// wrapError maps SQLite constraint violations like utils.WrapSqlError maps the Postgres ones.
+// Both pure Go and cgo drivers report them with the message of SQLite itself.
+func wrapError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return utils.WrapError(err, utils.ConflictMessage, http.StatusConflict)
+	}
+
+	return utils.WrapSqlError(err)
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+)
+
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx, so the repositories run the same queries in a transaction.
+type queryer interface {
+	sqlx.ExecerContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+type Storage struct {
+	conn   *sqlx.DB
+	db     queryer
+	logger *zap.Logger
+}
+
+func (s *Storage) User() storage.UserRepository {
+	return s
+}
+
+func (s *Storage) Token() storage.TokenRepository {
+	return s
+}
+
+func (s *Storage) Consent() storage.ConsentRepository {
+	return s
+}
+
+func (s *Storage) Identity() storage.IdentityRepository {
+	return s
+}
+
+func (s *Storage) Export() storage.ExportRepository {
+	return s
+}
+
+func (s *Storage) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		return fn(tx)
+	})
+}
+
+// withTx is WithTx for the repository methods that need several statements.
+func (s *Storage) withTx(ctx context.Context, fn func(tx *Storage) error) error {
+	if _, ok := s.db.(*sqlx.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("begin transaction: %w", err))
+	}
+	// Rolling back a committed transaction is a no-op.
+	defer func() { _ = tx.Rollback() }()
+
+	if err = fn(&Storage{conn: s.conn, db: tx, logger: s.logger}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return wrapError(fmt.Errorf("commit transaction: %w", err))
+	}
+
+	return nil
+}
+
+// Close closes the database, so that the WAL is checkpointed into the database file.
+func (s *Storage) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"net/url"
+	"pulse-auth/cmd/pulse/config"
+
+	// The driver is a translation of SQLite to Go, so binaries built with it don't need cgo.
+	_ "github.com/glebarez/go-sqlite"
+)
+
+// driverName is the database/sql driver registered by glebarez/go-sqlite.
+const driverName = "sqlite"
+
+// NewStorage opens the SQLite database at the configured path, creating it if it doesn't exist.
+func NewStorage(logger *zap.Logger, cfg config.StorageConfig) (*Storage, error) {
+	conn, err := sqlx.Connect(driverName, dataSourceName(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("connect to sqlite failed %w", err)
+	}
+
+	// SQLite allows a single writer. One connection queues the statements in the pool
+	// instead of failing them with SQLITE_BUSY, which is plenty for a single node.
+	conn.SetMaxOpenConns(1)
+	conn.SetConnMaxLifetime(0)
+	conn.SetConnMaxIdleTime(0)
+
+	return &Storage{
+		conn:   conn,
+		db:     conn,
+		logger: logger,
+	}, nil
+}
+
+// dataSourceName opens the database file with foreign keys enforced and write-ahead logging, unless the config
+// has a complete DSN.
+func dataSourceName(cfg config.StorageConfig) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+
+	return "file:" + cfg.Path + "?" + params.Encode()
+}
@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"fmt"
+	"pulse-auth/internal/model"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// userFieldColumns maps the fields to the lower-case copies they are compared with. Ids are always lower case.
+var userFieldColumns = map[model.UserField]string{
+	model.UserFieldID:         fieldID,
+	model.UserFieldUsername:   fieldUsernameLower,
+	model.UserFieldFirstName:  fieldFirstNameLower,
+	model.UserFieldSecondName: fieldSecondNameLower,
+	model.UserFieldCity:       fieldCityLower,
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// like matches the column against a pattern with the LIKE wildcards escaped.
+func like(column, pattern string) sq.Sqlizer {
+	return sq.Expr(column+` LIKE ? ESCAPE '\'`, pattern)
+}
+
+// userConditionToSql translates a condition into a case-insensitive predicate on the column.
+func userConditionToSql(condition model.UserCondition) (sq.Sqlizer, error) {
+	column, ok := userFieldColumns[condition.Field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field: %s", condition.Field)
+	}
+
+	value := strings.ToLower(condition.Value)
+	pattern := likeEscaper.Replace(value)
+	switch condition.Operator {
+	case model.OperatorEqual:
+		return sq.Eq{column: value}, nil
+	case model.OperatorNotEqual:
+		return sq.Expr(column+" IS NOT ?", value), nil
+	case model.OperatorContains:
+		return like(column, "%"+pattern+"%"), nil
+	case model.OperatorStartsWith:
+		return like(column, pattern+"%"), nil
+	case model.OperatorEndsWith:
+		return like(column, "%"+pattern), nil
+	case model.OperatorPresent:
+		return sq.NotEq{column: ""}, nil
+	default:
+		return nil, fmt.Errorf("unknown operator: %s", condition.Operator)
+	}
+}
+
+// userSearchToSql translates the search filters into predicates on active users who haven't opted out of search.
+// The cursor is left out, so the same predicates count the matches across all pages.
+func userSearchToSql(params *model.UserSearchParams) sq.And {
+	where := sq.And{sq.Eq{fieldDeletedAt: nil, fieldSearchable: true}}
+	if params.FirstName != "" {
+		where = append(where, like(fieldFirstNameLower, likeEscaper.Replace(strings.ToLower(params.FirstName))+"%"))
+	}
+	if params.SecondName != "" {
+		where = append(where, like(fieldSecondNameLower, likeEscaper.Replace(strings.ToLower(params.SecondName))+"%"))
+	}
+	// Search is only open to signed in users, so filters skip the fields their owners keep private.
+	// Otherwise filtering would reveal the hidden values.
+	if params.City != "" {
+		where = append(where, sq.Eq{fieldCityLower: strings.ToLower(params.City)},
+			sq.NotEq{fieldCityVisibility: model.VisibilityPrivate})
+	}
+	if params.Sex != "" {
+		where = append(where, sq.Eq{fieldSex: strings.ToLower(params.Sex)},
+			sq.NotEq{fieldSexVisibility: model.VisibilityPrivate})
+	}
+	if !params.BornAfter.IsZero() || !params.BornBefore.IsZero() {
+		where = append(where, sq.NotEq{fieldBirthdate: nil},
+			sq.NotEq{fieldBirthdateVisibility: model.VisibilityPrivate})
+	}
+	if !params.BornAfter.IsZero() {
+		where = append(where, sq.Gt{fieldBirthdate: millis(params.BornAfter)})
+	}
+	if !params.BornBefore.IsZero() {
+		where = append(where, sq.LtOrEq{fieldBirthdate: millis(params.BornBefore)})
+	}
+
+	return where
+}
+
+// userCursorToSql selects the users after the cursor in the search order.
+func userCursorToSql(cursor *model.UserCursor) sq.Sqlizer {
+	row := "(" + strings.Join(userSearchOrder, separator) + ")"
+	return sq.Expr(row+" > (?, ?, ?)", strings.ToLower(cursor.SecondName), strings.ToLower(cursor.FirstName), cursor.ID.String())
+}
+
+// userQueryToSql approximates the trigram search of Postgres: every word of the query must appear in the names,
+// username, city or biography, whole or as a part of a longer word.
+func userQueryToSql(query string) sq.Sqlizer {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return sq.Expr("0 = 1")
+	}
+
+	predicate := sq.And{}
+	for _, word := range words {
+		predicate = append(predicate, like(fieldSearchText, "%"+likeEscaper.Replace(word)+"%"))
+	}
+
+	return predicate
+}
+
+// userQueriesToSql matches users matching any of the queries.
+func userQueriesToSql(queries []string) sq.Sqlizer {
+	predicate := sq.Or{}
+	for _, query := range queries {
+		predicate = append(predicate, userQueryToSql(query))
+	}
+
+	return predicate
+}
+
+// userRankToSql orders fuzzy search results by the number of queries they match.
+func userRankToSql(queries []string) (string, []any, error) {
+	matches := make([]string, 0, len(queries))
+	var args []any
+	for _, query := range queries {
+		predicate, predicateArgs, err := userQueryToSql(query).ToSql()
+		if err != nil {
+			return "", nil, err
+		}
+		matches = append(matches, "(CASE WHEN "+predicate+" THEN 1 ELSE 0 END)")
+		args = append(args, predicateArgs...)
+	}
+
+	return strings.Join(matches, " + ") + " DESC", args, nil
+}
+
+// userSearchText is what fuzzy search looks in, kept in lower case.
+func userSearchText(entity userEntity) string {
+	return strings.ToLower(strings.Join([]string{
+		entity.FirstName, entity.SecondName, entity.Username, entity.City, entity.Biography,
+	}, " "))
+}
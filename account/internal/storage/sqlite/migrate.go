@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"pulse-auth/migrations"
+
+	"github.com/pressly/goose/v3"
+)
+
+// MigrationsDir is where the SQLite migrations are kept, relative to the Postgres ones.
+const MigrationsDir = "sqlite"
+
+// NewMigrator returns a goose provider for the embedded SQLite migrations. A single node owns the database file,
+// so unlike Postgres no lock is taken.
+func (s *Storage) NewMigrator() (*goose.Provider, error) {
+	fsys, err := fs.Sub(migrations.SQLiteFS, MigrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite migrations: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectSQLite3, s.conn.DB, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("new goose provider: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Migrate applies the pending migrations.
+func (s *Storage) Migrate(ctx context.Context) error {
+	migrator, err := s.NewMigrator()
+	if err != nil {
+		return fmt.Errorf("new migrator: %w", err)
+	}
+
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("up: %w", err)
+	}
+
+	for _, result := range results {
+		s.logger.Sugar().Infof("applied migration %s in %s", result.Source.Path, result.Duration)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// GetRoles returns the roles granted to the user.
+func (s *Storage) GetRoles(ctx context.Context, id model.UserID) ([]string, error) {
+	sql, args, err := sq.Select(fieldRole).
+		From(UserRoleTable).
+		Where(sq.Eq{fieldUserID: id.String()}).
+		OrderBy(fieldRole).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var roles []string
+	err = s.db.SelectContext(ctx, &roles, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return roles, nil
+}
+
+// SetRoles replaces the roles granted to the user, keeping when the remaining ones were granted.
+func (s *Storage) SetRoles(ctx context.Context, id model.UserID, roles []string) error {
+	return s.withTx(ctx, func(tx *Storage) error {
+		sql, args, err := sq.Delete(UserRoleTable).
+			Where(sq.Eq{fieldUserID: id.String()}).
+			Where(sq.NotEq{fieldRole: roles}).
+			PlaceholderFormat(sq.Question).
+			ToSql()
+		if err != nil {
+			return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+		}
+		if _, err = tx.db.ExecContext(ctx, sql, args...); err != nil {
+			return wrapError(err)
+		}
+		if len(roles) == 0 {
+			return nil
+		}
+
+		now := millis(time.Now().Truncate(time.Millisecond))
+		builder := sq.Insert(UserRoleTable).
+			Options("OR IGNORE").
+			Columns(fieldUserID, fieldRole, fieldCreatedAt)
+		for _, role := range roles {
+			builder = builder.Values(id.String(), role, now)
+		}
+		sql, args, err = builder.
+			PlaceholderFormat(sq.Question).
+			ToSql()
+		if err != nil {
+			return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+		}
+		if _, err = tx.db.ExecContext(ctx, sql, args...); err != nil {
+			return wrapError(err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"path/filepath"
+	"pulse-auth/cmd/pulse/config"
+	"pulse-auth/internal/storage/storagetest"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	s, err := NewStorage(zap.NewNop(), config.StorageConfig{Path: filepath.Join(t.TempDir(), "pulse.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	require.NoError(t, s.Migrate(context.Background()))
+
+	storagetest.Run(t, s)
+}
+
+func TestDataSourceName(t *testing.T) {
+	cfg := config.StorageConfig{Path: "/var/lib/pulse/pulse.db"}
+	assert.Equal(t,
+		"file:/var/lib/pulse/pulse.db?_pragma=foreign_keys%281%29&_pragma=journal_mode%28WAL%29&_pragma=busy_timeout%285000%29",
+		dataSourceName(cfg))
+
+	cfg.DSN = "file::memory:"
+	assert.Equal(t, cfg.DSN, dataSourceName(cfg))
+}
@@ -0,0 +1,338 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// GetCurrentUserToken returns an active token of the user.
+func (s *Storage) GetCurrentUserToken(ctx context.Context, id model.UserID) (*model.Token, error) {
+	return s.getToken(ctx, sq.Eq{
+		fieldUserID:    id.String(),
+		fieldDeletedAt: nil,
+	})
+}
+
+// CreateToken creates a token with the provided parameters and stores it in the database.
+func (s *Storage) CreateToken(ctx context.Context, params *model.TokenWithMetadata) (*model.Token, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+
+	now := millis(time.Now().Truncate(time.Millisecond))
+	// Client tokens are not a sign in of the user, they never pass a reauthentication check.
+	authenticatedAt := sql.NullInt64{Int64: now, Valid: params.ClientID == ""}
+	sql, args, err := sq.Insert(TokenTable).
+		Columns(tokenFields...).
+		Values(params.TokenID, params.UserID, params.Token, now, millis(params.AlivedAt), nullString(params.ClientID.String()),
+			authenticatedAt, now, nullString(params.Device.UserAgent), nullString(params.Device.IPAddress),
+			nullString(params.Device.ClientName), nullString(model.FormatScopes(params.Scopes)),
+		).
+		Suffix(returningToken).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity tokenEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return tokenEntityToModel(entity), nil
+}
+
+// GetToken returns a token that is neither revoked nor expired by its value.
+func (s *Storage) GetToken(ctx context.Context, token string) (*model.Token, error) {
+	return s.getToken(ctx, sq.Eq{
+		fieldToken:     token,
+		fieldDeletedAt: nil,
+	})
+}
+
+// RevokeToken revokes a token by updating the deleted_at field with the current timestamp in the database.
+func (s *Storage) RevokeToken(ctx context.Context, params *model.Token) error {
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldToken:     params.Token,
+			fieldUserID:    params.UserID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldDeletedAt, millis(time.Now().Truncate(time.Millisecond))).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	return s.execOne(ctx, "token", sql, args...)
+}
+
+// ListSessions returns the user's tokens that are neither revoked nor expired, most recently used first.
+func (s *Storage) ListSessions(ctx context.Context, userID model.UserID) ([]*model.Session, error) {
+	sql, args, err := sq.Select(tokenFields...).
+		From(TokenTable).
+		Where(sq.Eq{
+			fieldUserID:    userID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Where(sq.Gt{fieldAlivedAt: millis(time.Now())}).
+		OrderBy(fieldLastUsedAt + " DESC").
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []tokenEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	sessions := make([]*model.Session, 0, len(entities))
+	for _, entity := range entities {
+		sessions = append(sessions, tokenEntityToSession(entity))
+	}
+
+	return sessions, nil
+}
+
+// ListSessionHistory returns every token of the user that is still stored, revoked and expired ones included,
+// most recently created first.
+func (s *Storage) ListSessionHistory(ctx context.Context, userID model.UserID) ([]*model.Session, error) {
+	sql, args, err := sq.Select(tokenFields...).
+		Column(fieldDeletedAt+" AS revoked_at").
+		From(TokenTable).
+		Where(sq.Eq{fieldUserID: userID.String()}).
+		OrderBy(fieldCreatedAt+" DESC", fieldID).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []sessionHistoryEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	sessions := make([]*model.Session, 0, len(entities))
+	for _, entity := range entities {
+		session := tokenEntityToSession(entity.tokenEntity)
+		session.RevokedAt = fromNullMillis(entity.RevokedAt)
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// TouchToken records that the token has just been used.
+func (s *Storage) TouchToken(ctx context.Context, id model.TokenID) error {
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldLastUsedAt, millis(time.Now().Truncate(time.Millisecond))).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	_, err = s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// ReauthenticateToken records that the user has just proved their credentials again in the session.
+func (s *Storage) ReauthenticateToken(ctx context.Context, id model.TokenID) error {
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldClientID:  nil,
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldAuthenticatedAt, millis(time.Now().Truncate(time.Millisecond))).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	return s.execOne(ctx, "token", sql, args...)
+}
+
+// RevokeClientTokens revokes every active token the user has issued to the client.
+func (s *Storage) RevokeClientTokens(ctx context.Context, userID model.UserID, clientID model.ClientID) error {
+	return s.revokeTokens(ctx, sq.Eq{
+		fieldUserID:    userID.String(),
+		fieldClientID:  clientID.String(),
+		fieldDeletedAt: nil,
+	})
+}
+
+// RevokeUserTokens revokes every active token of the user, signing them out everywhere.
+func (s *Storage) RevokeUserTokens(ctx context.Context, userID model.UserID) error {
+	return s.revokeTokens(ctx, sq.Eq{
+		fieldUserID:    userID.String(),
+		fieldDeletedAt: nil,
+	})
+}
+
+// RefreshToken updates the token's lifetime based on the provided parameters, returning the updated token.
+func (s *Storage) RefreshToken(ctx context.Context, params *model.TokenWithMetadata) (*model.Token, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("params validate: %w", err)
+	}
+	sql, args, err := sq.Update(TokenTable).
+		Where(sq.Eq{
+			fieldToken:     params.Token,
+			fieldUserID:    params.UserID.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldAlivedAt, millis(params.AlivedAt)).
+		Suffix(returningToken).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity tokenEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return tokenEntityToModel(entity), nil
+}
+
+// DeleteExpiredTokens removes the tokens that expired or were revoked before the given time.
+func (s *Storage) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	sql, args, err := sq.Delete(TokenTable).
+		Where(sq.Or{
+			sq.Lt{fieldAlivedAt: millis(before)},
+			sq.Lt{fieldDeletedAt: millis(before)},
+		}).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, utils.WrapInternalError(err)
+	}
+
+	return deleted, nil
+}
+
+// getToken returns an unexpired token matching the predicate.
+func (s *Storage) getToken(ctx context.Context, where sq.Eq) (*model.Token, error) {
+	sql, args, err := sq.Select(tokenFields...).
+		From(TokenTable).
+		Where(where).
+		Where(sq.Gt{fieldAlivedAt: millis(time.Now())}).
+		Limit(1).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity tokenEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return tokenEntityToModel(entity), nil
+}
+
+func (s *Storage) revokeTokens(ctx context.Context, where sq.Eq) error {
+	sql, args, err := sq.Update(TokenTable).
+		Where(where).
+		Set(fieldDeletedAt, millis(time.Now().Truncate(time.Millisecond))).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	_, err = s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+type tokenEntity struct {
+	ID              string         `db:"id"`
+	UserID          string         `db:"user_id"`
+	ClientID        sql.NullString `db:"client_id"`
+	Token           string         `db:"token"`
+	CreatedAt       int64          `db:"created_at"`
+	AlivedAt        int64          `db:"alived_at"`
+	AuthenticatedAt sql.NullInt64  `db:"authenticated_at"`
+	LastUsedAt      int64          `db:"last_used_at"`
+	UserAgent       sql.NullString `db:"user_agent"`
+	IPAddress       sql.NullString `db:"ip_address"`
+	ClientName      sql.NullString `db:"client_name"`
+	Scopes          sql.NullString `db:"scopes"`
+}
+
+// sessionHistoryEntity is a token row with the time it was revoked at, NULL while the token is active.
+type sessionHistoryEntity struct {
+	tokenEntity
+	RevokedAt sql.NullInt64 `db:"revoked_at"`
+}
+
+// tokenEntityToModel converts a token entity to a token model and returns a pointer to it.
+func tokenEntityToModel(entity tokenEntity) *model.Token {
+	return &model.Token{
+		TokenID:         model.TokenID(entity.ID),
+		UserID:          model.UserID(entity.UserID),
+		ClientID:        model.ClientID(entity.ClientID.String),
+		Scopes:          model.ParseScopes(entity.Scopes.String),
+		Token:           entity.Token,
+		AuthenticatedAt: fromNullMillis(entity.AuthenticatedAt),
+		LastUsedAt:      fromMillis(entity.LastUsedAt),
+	}
+}
+
+// tokenEntityToSession converts a token entity to a session with the device it was issued to.
+func tokenEntityToSession(entity tokenEntity) *model.Session {
+	return &model.Session{
+		Token: *tokenEntityToModel(entity),
+		Device: model.Device{
+			UserAgent:  entity.UserAgent.String,
+			IPAddress:  entity.IPAddress.String,
+			ClientName: entity.ClientName.String,
+		},
+		CreatedAt: fromMillis(entity.CreatedAt),
+		ExpiresAt: fromMillis(entity.AlivedAt),
+	}
+}
@@ -0,0 +1,619 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// LoginUser takes a user login input and checks the database for a matching user.
+func (s *Storage) LoginUser(ctx context.Context, userLogin *model.UserLogin) (*model.User, error) {
+	entity, err := s.getUser(ctx, sq.Eq{
+		fieldUsername:       userLogin.Username,
+		fieldHashedPassword: userLogin.HashedPassword,
+		fieldDeletedAt:      nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userEntityToModel(*entity), nil
+}
+
+// CreateUser takes in user registration params, validates them and creates a new user in the database.
+func (s *Storage) CreateUser(ctx context.Context, params *model.UserRegister) (*model.User, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+	canonical, err := model.NormalizeUsername(params.Username)
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	visibility := model.DefaultProfileVisibility()
+	entity := userEntity{
+		ID:                  params.ID,
+		Username:            params.Username,
+		UsernameCanonical:   nullString(canonical),
+		HashedPassword:      params.HashedPassword,
+		FirstName:           params.FirstName,
+		SecondName:          params.SecondName,
+		Sex:                 params.Sex,
+		Birthdate:           nullMillis(params.Birthdate),
+		Biography:           params.Biography,
+		City:                params.City,
+		CreatedAt:           millis(now),
+		UpdatedAt:           millis(now),
+		SexVisibility:       string(visibility.Sex),
+		BirthdateVisibility: string(visibility.Birthdate),
+		BiographyVisibility: string(visibility.Biography),
+		CityVisibility:      string(visibility.City),
+		Searchable:          true,
+	}
+	sql, args, err := sq.Insert(UserTable).
+		SetMap(userEntityToValues(entity)).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	_, err = s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// GetUserByID retrieve a user by ID from the storage.
+func (s *Storage) GetUserByID(ctx context.Context, id model.UserID) (*model.User, error) {
+	entity, err := s.getUser(ctx, sq.Eq{
+		fieldID:        id.String(),
+		fieldDeletedAt: nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userEntityToModel(*entity), nil
+}
+
+// GetUserWithDeleted retrieves a user by ID, including users deleted but not purged yet.
+func (s *Storage) GetUserWithDeleted(ctx context.Context, id model.UserID) (*model.User, error) {
+	sql, args, err := sq.Select(userFields...).
+		Columns(fieldDeletedAt, fieldDeletedBy).
+		From(UserTable).
+		Where(sq.Eq{
+			fieldID:       id.String(),
+			fieldPurgedAt: nil,
+		}).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity deletedUserEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	user := userEntityToModel(entity.userEntity)
+	user.DeletedAt = fromNullMillis(entity.DeletedAt)
+	user.DeletedBy = model.DeletedBy(entity.DeletedBy.String)
+
+	return user, nil
+}
+
+// GetUsersByIDs returns the active users among the ids in a single query. Unknown ids are skipped.
+func (s *Storage) GetUsersByIDs(ctx context.Context, ids []model.UserID) ([]*model.User, error) {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+
+	return s.selectUsers(ctx, sq.Select(userFields...).
+		From(UserTable).
+		Where(sq.Eq{
+			fieldID:        values,
+			fieldDeletedAt: nil,
+		}))
+}
+
+// SearchUser searches for a user in the storage using first and last name.
+func (s *Storage) SearchUser(ctx context.Context, firstName, lastName string) (*model.User, error) {
+	entity, err := s.getUser(ctx, sq.Eq{
+		fieldFirstName:  firstName,
+		fieldSecondName: lastName,
+		fieldSearchable: true,
+		fieldDeletedAt:  nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userEntityToModel(*entity), nil
+}
+
+// SearchUsers returns a page of active users matching the filters and the number of matches across all pages.
+func (s *Storage) SearchUsers(ctx context.Context, params *model.UserSearchParams) ([]*model.User, uint64, error) {
+	where := userSearchToSql(params)
+	if len(params.Queries) > 0 {
+		where = append(where, userQueriesToSql(params.Queries))
+	}
+	total, err := s.countUsers(ctx, where)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	builder := sq.Select(userFields...).
+		From(UserTable).
+		Limit(params.Limit)
+	if len(params.Queries) > 0 {
+		rank, rankArgs, err := userRankToSql(params.Queries)
+		if err != nil {
+			return nil, 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+		}
+		builder = builder.Where(where).OrderByClause(rank, rankArgs...).OrderBy(fieldID).Offset(params.Offset)
+	} else {
+		if params.After != nil {
+			where = append(where, userCursorToSql(params.After))
+		}
+		builder = builder.Where(where).OrderBy(userSearchOrder...)
+	}
+
+	users, err := s.selectUsers(ctx, builder)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// LockUser only checks that the user is active. The pool has a single connection, so transactions don't overlap
+// and need no row locks.
+func (s *Storage) LockUser(ctx context.Context, id model.UserID) error {
+	_, err := s.getUser(ctx, sq.Eq{
+		fieldID:        id.String(),
+		fieldDeletedAt: nil,
+	})
+
+	return err
+}
+
+// UpdatePassword replaces the user's password hash. An empty hash leaves the account without a password.
+func (s *Storage) UpdatePassword(ctx context.Context, id model.UserID, hashedPassword string) error {
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldHashedPassword, hashedPassword).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	return s.execOne(ctx, "user", sql, args...)
+}
+
+// ListUsers returns a page of active users matching the conditions and the number of matches across all pages.
+func (s *Storage) ListUsers(ctx context.Context, params *model.UserListParams) ([]*model.User, uint64, error) {
+	where := sq.And{sq.Eq{fieldDeletedAt: nil}}
+	for _, condition := range params.Conditions {
+		predicate, err := userConditionToSql(condition)
+		if err != nil {
+			return nil, 0, utils.WrapValidationError(fmt.Errorf("condition: %w", err))
+		}
+		where = append(where, predicate)
+	}
+
+	total, err := s.countUsers(ctx, where)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users, err := s.selectUsers(ctx, sq.Select(userFields...).
+		From(UserTable).
+		Where(where).
+		OrderBy(fieldCreatedAt, fieldID).
+		Offset(params.Offset).
+		Limit(params.Limit))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UpdateUser changes the fields set in the update and returns the updated user. The whole row is written back,
+// so that the lower-case copies of the fields stay in sync.
+func (s *Storage) UpdateUser(ctx context.Context, update *model.UserUpdate) (*model.User, error) {
+	err := update.Validate()
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("params validate: %w", err))
+	}
+	if update.Empty() {
+		return s.GetUserByID(ctx, update.ID)
+	}
+
+	var updated *model.User
+	err = s.withTx(ctx, func(tx *Storage) error {
+		entity, err := tx.getUser(ctx, sq.Eq{
+			fieldID:        update.ID.String(),
+			fieldDeletedAt: nil,
+		})
+		if err != nil {
+			return err
+		}
+
+		if update.Username != nil {
+			canonical, err := model.NormalizeUsername(*update.Username)
+			if err != nil {
+				return utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+			}
+			entity.Username, entity.UsernameCanonical = *update.Username, nullString(canonical)
+		}
+		setIfNotNil(&entity.FirstName, update.FirstName)
+		setIfNotNil(&entity.SecondName, update.SecondName)
+		setIfNotNil(&entity.Sex, update.Sex)
+		if update.Birthdate != nil {
+			entity.Birthdate = nullMillis(*update.Birthdate)
+		}
+		setIfNotNil(&entity.Biography, update.Biography)
+		setIfNotNil(&entity.City, update.City)
+		setVisibilityIfNotNil(&entity.SexVisibility, update.SexVisibility)
+		setVisibilityIfNotNil(&entity.BirthdateVisibility, update.BirthdateVisibility)
+		setVisibilityIfNotNil(&entity.BiographyVisibility, update.BiographyVisibility)
+		setVisibilityIfNotNil(&entity.CityVisibility, update.CityVisibility)
+		setIfNotNil(&entity.Searchable, update.Searchable)
+		entity.UpdatedAt = millis(time.Now().Truncate(time.Millisecond))
+
+		if err = tx.saveUser(ctx, entity); err != nil {
+			return err
+		}
+		updated = userEntityToModel(*entity)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteUser soft-deletes the user by setting deleted_at and who deleted it.
+func (s *Storage) DeleteUser(ctx context.Context, id model.UserID, by model.DeletedBy) error {
+	now := millis(time.Now().Truncate(time.Millisecond))
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		}).
+		Set(fieldDeletedAt, now).
+		Set(fieldDeletedBy, string(by)).
+		Set(fieldUpdatedAt, now).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	return s.execOne(ctx, "user", sql, args...)
+}
+
+// RestoreUser undeletes the user matching the credentials if they deleted it after deletedAfter and it's not purged
+// yet.
+func (s *Storage) RestoreUser(ctx context.Context, userLogin *model.UserLogin, deletedAfter time.Time) (*model.User, error) {
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldUsername:       userLogin.Username,
+			fieldHashedPassword: userLogin.HashedPassword,
+			fieldPurgedAt:       nil,
+			fieldDeletedBy:      string(model.DeletedByUser),
+		}).
+		Where(sq.Gt{fieldDeletedAt: millis(deletedAfter)}).
+		Set(fieldDeletedAt, nil).
+		Set(fieldDeletedBy, nil).
+		Set(fieldUpdatedAt, millis(time.Now().Truncate(time.Millisecond))).
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity userEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// RestoreUserByID undeletes the user if it was deleted by the given party after deletedAfter and not purged yet. It
+// restores accounts whose owner proved who they are some other way than with the password, like a linked identity.
+func (s *Storage) RestoreUserByID(ctx context.Context, id model.UserID, deletedAfter time.Time, by model.DeletedBy) (*model.User, error) {
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{
+			fieldID:        id.String(),
+			fieldPurgedAt:  nil,
+			fieldDeletedBy: string(by),
+		}).
+		Where(sq.Gt{fieldDeletedAt: millis(deletedAfter)}).
+		Set(fieldDeletedAt, nil).
+		Set(fieldDeletedBy, nil).
+		Set(fieldUpdatedAt, millis(time.Now().Truncate(time.Millisecond))).
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity userEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return userEntityToModel(entity), nil
+}
+
+// PurgeUsers anonymizes users deleted before deletedBefore and removes everything linked to them.
+// The user row is kept with a placeholder username so that foreign keys and audit references stay valid.
+func (s *Storage) PurgeUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := s.withTx(ctx, func(tx *Storage) error {
+		var ids []string
+		err := tx.db.SelectContext(ctx, &ids, "SELECT id FROM "+UserTable+" WHERE deleted_at < ? AND purged_at IS NULL",
+			millis(deletedBefore))
+		if err != nil {
+			return wrapError(err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		now := millis(time.Now().Truncate(time.Millisecond))
+		placeholder := sq.Expr("'deleted-' || " + fieldID)
+		sql, args, err := sq.Update(UserTable).
+			Where(sq.Eq{fieldID: ids}).
+			SetMap(map[string]any{
+				fieldUsername: placeholder, fieldUsernameCanonical: placeholder, fieldUsernameLower: placeholder,
+				fieldSearchText: placeholder, fieldHashedPassword: "",
+				fieldFirstName: "", fieldFirstNameLower: "", fieldSecondName: "", fieldSecondNameLower: "",
+				fieldSex: "", fieldBirthdate: nil, fieldBiography: "", fieldCity: "", fieldCityLower: "",
+				fieldPurgedAt: now, fieldUpdatedAt: now,
+			}).
+			PlaceholderFormat(sq.Question).
+			ToSql()
+		if err != nil {
+			return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+		}
+		if _, err = tx.db.ExecContext(ctx, sql, args...); err != nil {
+			return wrapError(err)
+		}
+
+		for _, table := range []string{TokenTable, ConsentTable, IdentityTable, UserRoleTable, ExportTable, UsernameHistoryTable} {
+			sql, args, err := sq.Delete(table).
+				Where(sq.Eq{fieldUserID: ids}).
+				PlaceholderFormat(sq.Question).
+				ToSql()
+			if err != nil {
+				return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+			}
+			if _, err = tx.db.ExecContext(ctx, sql, args...); err != nil {
+				return wrapError(err)
+			}
+		}
+
+		purged = int64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// getUser returns the user matching the predicate with the columns needed to write it back.
+func (s *Storage) getUser(ctx context.Context, where sq.Sqlizer) (*userEntity, error) {
+	sql, args, err := sq.Select(userFields...).
+		From(UserTable).
+		Where(where).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity userEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &entity, nil
+}
+
+func (s *Storage) selectUsers(ctx context.Context, builder sq.SelectBuilder) ([]*model.User, error) {
+	sql, args, err := builder.
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []userEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	users := make([]*model.User, 0, len(entities))
+	for _, entity := range entities {
+		users = append(users, userEntityToModel(entity))
+	}
+
+	return users, nil
+}
+
+func (s *Storage) countUsers(ctx context.Context, where sq.Sqlizer) (uint64, error) {
+	sql, args, err := sq.Select("COUNT(*)").
+		From(UserTable).
+		Where(where).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return 0, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var total uint64
+	err = s.db.GetContext(ctx, &total, sql, args...)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return total, nil
+}
+
+// saveUser writes every column of the user back, recomputing the lower-case copies.
+func (s *Storage) saveUser(ctx context.Context, entity *userEntity) error {
+	sql, args, err := sq.Update(UserTable).
+		Where(sq.Eq{fieldID: entity.ID}).
+		SetMap(userEntityToValues(*entity)).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	return s.execOne(ctx, "user", sql, args...)
+}
+
+// execOne runs a statement that must change a row, reporting the entity as not found otherwise.
+func (s *Storage) execOne(ctx context.Context, entity string, sql string, args ...any) error {
+	result, err := s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return utils.WrapNotFoundError(fmt.Errorf("%s not found", entity), utils.NotFoundMessage)
+	}
+
+	return nil
+}
+
+func setIfNotNil[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func setVisibilityIfNotNil(field *string, value *model.Visibility) {
+	if value != nil {
+		*field = string(*value)
+	}
+}
+
+type userEntity struct {
+	ID                string         `db:"id"`
+	Username          string         `db:"username"`
+	UsernameCanonical sql.NullString `db:"username_canonical"`
+	HashedPassword    string         `db:"hashed_password"`
+	FirstName         string         `db:"first_name"`
+	SecondName        string         `db:"second_name"`
+	Sex               string         `db:"sex"`
+	Birthdate         sql.NullInt64  `db:"birthdate"`
+	Biography         string         `db:"biography"`
+	City              string         `db:"city"`
+	CreatedAt         int64          `db:"created_at"`
+	UpdatedAt         int64          `db:"updated_at"`
+
+	SexVisibility       string `db:"sex_visibility"`
+	BirthdateVisibility string `db:"birthdate_visibility"`
+	BiographyVisibility string `db:"biography_visibility"`
+	CityVisibility      string `db:"city_visibility"`
+	Searchable          bool   `db:"searchable"`
+}
+
+// deletedUserEntity is a user with the columns telling whether and by whom it was deleted.
+type deletedUserEntity struct {
+	userEntity
+	DeletedAt sql.NullInt64  `db:"deleted_at"`
+	DeletedBy sql.NullString `db:"deleted_by"`
+}
+
+// userEntityToValues returns the columns of the user, with the lower-case copies the filters use.
+func userEntityToValues(entity userEntity) map[string]any {
+	return map[string]any{
+		fieldID:                  entity.ID,
+		fieldUsername:            entity.Username,
+		fieldUsernameCanonical:   entity.UsernameCanonical,
+		fieldUsernameLower:       strings.ToLower(entity.Username),
+		fieldHashedPassword:      entity.HashedPassword,
+		fieldFirstName:           entity.FirstName,
+		fieldFirstNameLower:      strings.ToLower(entity.FirstName),
+		fieldSecondName:          entity.SecondName,
+		fieldSecondNameLower:     strings.ToLower(entity.SecondName),
+		fieldSex:                 entity.Sex,
+		fieldBirthdate:           entity.Birthdate,
+		fieldBiography:           entity.Biography,
+		fieldCity:                entity.City,
+		fieldCityLower:           strings.ToLower(entity.City),
+		fieldSearchText:          userSearchText(entity),
+		fieldCreatedAt:           entity.CreatedAt,
+		fieldUpdatedAt:           entity.UpdatedAt,
+		fieldSexVisibility:       entity.SexVisibility,
+		fieldBirthdateVisibility: entity.BirthdateVisibility,
+		fieldBiographyVisibility: entity.BiographyVisibility,
+		fieldCityVisibility:      entity.CityVisibility,
+		fieldSearchable:          entity.Searchable,
+	}
+}
+
+// userEntityToModel converts a user entity to a model User instance, mapping the attributes accordingly.
+func userEntityToModel(entity userEntity) *model.User {
+	return &model.User{
+		UserID:     model.UserID(entity.ID),
+		Username:   entity.Username,
+		FirstName:  entity.FirstName,
+		SecondName: entity.SecondName,
+		Sex:        entity.Sex,
+		Birthdate:  fromNullMillis(entity.Birthdate),
+		Biography:  entity.Biography,
+		City:       entity.City,
+
+		HasPassword: entity.HashedPassword != "",
+		Visibility: model.ProfileVisibility{
+			Sex:       model.Visibility(entity.SexVisibility),
+			Birthdate: model.Visibility(entity.BirthdateVisibility),
+			Biography: model.Visibility(entity.BiographyVisibility),
+			City:      model.Visibility(entity.CityVisibility),
+		},
+		Searchable: entity.Searchable,
+		CreatedAt:  fromMillis(entity.CreatedAt),
+		UpdatedAt:  fromMillis(entity.UpdatedAt),
+	}
+}
@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/utils"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"golang.org/x/text/unicode/norm"
+)
+
+// GetUserByUsername returns the active user with the username, compared in canonical form.
+func (s *Storage) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("normalize username: %w", err), utils.NotFoundMessage)
+	}
+
+	entity, err := s.getUser(ctx, sq.Eq{
+		fieldUsernameCanonical: canonical,
+		fieldDeletedAt:         nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userEntityToModel(*entity), nil
+}
+
+// ChangeUsername renames the user and records the old username in the history, reserved until reservedUntil.
+func (s *Storage) ChangeUsername(ctx context.Context, id model.UserID, username string, reservedUntil time.Time) (*model.User, error) {
+	if err := model.ValidateUsername(username); err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("validate username: %w", err))
+	}
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapValidationError(fmt.Errorf("normalize username: %w", err))
+	}
+
+	var changed *model.User
+	err = s.withTx(ctx, func(tx *Storage) error {
+		entity, err := tx.getUser(ctx, sq.Eq{
+			fieldID:        id.String(),
+			fieldDeletedAt: nil,
+		})
+		if err != nil {
+			return err
+		}
+
+		now := millis(time.Now().Truncate(time.Millisecond))
+		if entity.Username != username {
+			previous := entity.UsernameCanonical.String
+			if !entity.UsernameCanonical.Valid {
+				previous = strings.ToLower(norm.NFKC.String(entity.Username))
+			}
+
+			sql, args, err := sq.Insert(UsernameHistoryTable).
+				Columns(fieldUserID, fieldUsername, fieldUsernameCanonical, fieldCreatedAt, fieldReservedUntil).
+				Values(entity.ID, entity.Username, previous, now, millis(reservedUntil)).
+				PlaceholderFormat(sq.Question).
+				ToSql()
+			if err != nil {
+				return utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+			}
+			if _, err = tx.db.ExecContext(ctx, sql, args...); err != nil {
+				return wrapError(err)
+			}
+		}
+
+		entity.Username, entity.UsernameCanonical = username, nullString(canonical)
+		entity.UpdatedAt = now
+		if err = tx.saveUser(ctx, entity); err != nil {
+			return err
+		}
+		changed = userEntityToModel(*entity)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changed, nil
+}
+
+// ListUsernameHistory returns the previous usernames of the user, most recent first.
+func (s *Storage) ListUsernameHistory(ctx context.Context, id model.UserID) ([]*model.UsernameRecord, error) {
+	sql, args, err := sq.Select(usernameHistoryFields...).
+		From(UsernameHistoryTable).
+		Where(sq.Eq{fieldUserID: id.String()}).
+		OrderBy(fieldCreatedAt + " DESC").
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entities []usernameRecordEntity
+	err = s.db.SelectContext(ctx, &entities, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	records := make([]*model.UsernameRecord, 0, len(entities))
+	for _, entity := range entities {
+		records = append(records, usernameRecordEntityToModel(entity))
+	}
+
+	return records, nil
+}
+
+// GetUsernameRecord returns the latest time somebody gave up the username, compared in canonical form.
+func (s *Storage) GetUsernameRecord(ctx context.Context, username string) (*model.UsernameRecord, error) {
+	canonical, err := model.NormalizeUsername(username)
+	if err != nil {
+		return nil, utils.WrapNotFoundError(fmt.Errorf("normalize username: %w", err), utils.NotFoundMessage)
+	}
+
+	sql, args, err := sq.Select(usernameHistoryFields...).
+		From(UsernameHistoryTable).
+		Where(sq.Eq{fieldUsernameCanonical: canonical}).
+		OrderBy(fieldCreatedAt + " DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Question).
+		ToSql()
+	if err != nil {
+		return nil, utils.WrapInternalError(fmt.Errorf("incorrect sql"))
+	}
+
+	var entity usernameRecordEntity
+	err = s.db.GetContext(ctx, &entity, sql, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return usernameRecordEntityToModel(entity), nil
+}
+
+type usernameRecordEntity struct {
+	UserID        string `db:"user_id"`
+	Username      string `db:"username"`
+	CreatedAt     int64  `db:"created_at"`
+	ReservedUntil int64  `db:"reserved_until"`
+}
+
+// usernameRecordEntityToModel converts a username history entity to a username record model.
+func usernameRecordEntityToModel(entity usernameRecordEntity) *model.UsernameRecord {
+	return &model.UsernameRecord{
+		UserID:        model.UserID(entity.UserID),
+		Username:      entity.Username,
+		ChangedAt:     fromMillis(entity.CreatedAt),
+		ReservedUntil: fromMillis(entity.ReservedUntil),
+	}
+}
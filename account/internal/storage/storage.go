@@ -4,25 +4,104 @@ import (
 	"context"
 	"errors"
 	"pulse-auth/internal/model"
+	"time"
 )
 
 var ErrUserNotExist = errors.New("user not exist")
 
+type primaryContextKey struct{}
+
+// WithPrimary marks ctx so that its reads run on the primary database even when read replicas are configured.
+// Flows that read what they have just written, or write based on what they read, can't afford replication lag.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// UsesPrimary reports whether ctx was marked with WithPrimary.
+func UsesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
+
 type Storage interface {
 	User() UserRepository
 	Token() TokenRepository
+	Consent() ConsentRepository
+	Identity() IdentityRepository
+	Export() ExportRepository
+
+	// WithTx runs fn with a Storage whose repositories share one transaction. The transaction is committed
+	// when fn returns nil and rolled back otherwise. Calls nested in fn join the transaction.
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
 }
 
 type UserRepository interface {
 	LoginUser(ctx context.Context, userLogin *model.UserLogin) (*model.User, error)
 	CreateUser(ctx context.Context, user *model.UserRegister) (*model.User, error)
 	GetUserByID(ctx context.Context, id model.UserID) (*model.User, error)
+	// GetUserWithDeleted finds the account even when it is deleted but not purged yet, with DeletedAt and DeletedBy
+	// set.
+	GetUserWithDeleted(ctx context.Context, id model.UserID) (*model.User, error)
+	GetUsersByIDs(ctx context.Context, ids []model.UserID) ([]*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	ChangeUsername(ctx context.Context, id model.UserID, username string, reservedUntil time.Time) (*model.User, error)
+	ListUsernameHistory(ctx context.Context, id model.UserID) ([]*model.UsernameRecord, error)
+	GetUsernameRecord(ctx context.Context, username string) (*model.UsernameRecord, error)
 	SearchUser(ctx context.Context, firstName, lastName string) (*model.User, error)
+	SearchUsers(ctx context.Context, params *model.UserSearchParams) ([]*model.User, uint64, error)
+	ListUsers(ctx context.Context, params *model.UserListParams) ([]*model.User, uint64, error)
+	UpdateUser(ctx context.Context, update *model.UserUpdate) (*model.User, error)
+	DeleteUser(ctx context.Context, id model.UserID, by model.DeletedBy) error
+	// RestoreUser restores the account by its credentials, only if the user deleted it themselves.
+	RestoreUser(ctx context.Context, userLogin *model.UserLogin, deletedAfter time.Time) (*model.User, error)
+	// RestoreUserByID restores the account only if it was deleted by the same party, so that users can't undo a
+	// deprovisioning.
+	RestoreUserByID(ctx context.Context, id model.UserID, deletedAfter time.Time, by model.DeletedBy) (*model.User, error)
+	PurgeUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	UpdatePassword(ctx context.Context, id model.UserID, hashedPassword string) error
+	// LockUser locks the active user until the transaction ends, so that checks of what the user can sign in with
+	// and the writes relying on them don't interleave with another transaction. Outside a transaction it only
+	// checks that the user exists.
+	LockUser(ctx context.Context, id model.UserID) error
+	GetRoles(ctx context.Context, id model.UserID) ([]string, error)
+	SetRoles(ctx context.Context, id model.UserID, roles []string) error
 }
 
 type TokenRepository interface {
 	GetCurrentUserToken(ctx context.Context, id model.UserID) (*model.Token, error)
+	GetToken(ctx context.Context, token string) (*model.Token, error)
 	CreateToken(ctx context.Context, token *model.TokenWithMetadata) (*model.Token, error)
 	RevokeToken(ctx context.Context, token *model.Token) error
+	ReauthenticateToken(ctx context.Context, id model.TokenID) error
+	RevokeClientTokens(ctx context.Context, userID model.UserID, clientID model.ClientID) error
+	RevokeUserTokens(ctx context.Context, userID model.UserID) error
+	ListSessions(ctx context.Context, userID model.UserID) ([]*model.Session, error)
+	ListSessionHistory(ctx context.Context, userID model.UserID) ([]*model.Session, error)
+	TouchToken(ctx context.Context, id model.TokenID) error
 	RefreshToken(ctx context.Context, token *model.TokenWithMetadata) (*model.Token, error)
+	DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+type ConsentRepository interface {
+	GetConsent(ctx context.Context, userID model.UserID, clientID model.ClientID) (*model.Consent, error)
+	ListConsents(ctx context.Context, userID model.UserID) ([]*model.Consent, error)
+	GrantConsent(ctx context.Context, consent *model.Consent) (*model.Consent, error)
+	RevokeConsent(ctx context.Context, userID model.UserID, clientID model.ClientID) error
+}
+
+type IdentityRepository interface {
+	GetIdentity(ctx context.Context, provider, subject string) (*model.Identity, error)
+	CreateIdentity(ctx context.Context, identity *model.Identity) (*model.Identity, error)
+	ListIdentities(ctx context.Context, userID model.UserID) ([]*model.Identity, error)
+	DeleteIdentity(ctx context.Context, userID model.UserID, id model.IdentityID) error
+}
+
+type ExportRepository interface {
+	CreateExport(ctx context.Context, export *model.Export) (*model.Export, error)
+	GetExport(ctx context.Context, id model.ExportID) (*model.Export, error)
+	ClaimExport(ctx context.Context, staleBefore time.Time) (*model.Export, error)
+	CompleteExport(ctx context.Context, id model.ExportID, archive []byte, expiresAt time.Time) error
+	FailExport(ctx context.Context, id model.ExportID, reason string, expiresAt time.Time) error
+	GetExportArchive(ctx context.Context, id model.ExportID) ([]byte, error)
+	DeleteExpiredExports(ctx context.Context, before time.Time) (int64, error)
 }
@@ -0,0 +1,616 @@
+// Package storagetest is the contract every storage.Storage implementation must satisfy. The tests only touch
+// records they create themselves, so they can run against a database shared with other tests.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"pulse-auth/internal/model"
+	"pulse-auth/internal/storage"
+	"pulse-auth/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the contract against the storage.
+func Run(t *testing.T, s storage.Storage) {
+	t.Run("User", func(t *testing.T) { testUser(t, s) })
+	t.Run("UserDeletion", func(t *testing.T) { testUserDeletion(t, s) })
+	t.Run("Username", func(t *testing.T) { testUsername(t, s) })
+	t.Run("ListUsers", func(t *testing.T) { testListUsers(t, s) })
+	t.Run("SearchUsers", func(t *testing.T) { testSearchUsers(t, s) })
+	t.Run("Roles", func(t *testing.T) { testRoles(t, s) })
+	t.Run("Token", func(t *testing.T) { testToken(t, s) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, s) })
+	t.Run("TokenCleanup", func(t *testing.T) { testTokenCleanup(t, s) })
+	t.Run("Consent", func(t *testing.T) { testConsent(t, s) })
+	t.Run("Identity", func(t *testing.T) { testIdentity(t, s) })
+	t.Run("Export", func(t *testing.T) { testExport(t, s) })
+	t.Run("WithTx", func(t *testing.T) { testWithTx(t, s) })
+}
+
+func testUser(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	users := s.User()
+
+	created := createUser(t, s, func(params *model.UserRegister) {
+		params.FirstName = "Nikita"
+		params.City = "Moscow"
+	})
+	assert.True(t, created.HasPassword)
+	assert.True(t, created.Searchable)
+	assert.Equal(t, model.DefaultProfileVisibility(), created.Visibility)
+
+	got, err := users.GetUserByID(ctx, created.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+
+	login, err := users.LoginUser(ctx, &model.UserLogin{Username: created.Username, HashedPassword: "hash"})
+	require.NoError(t, err)
+	assert.Equal(t, created, login)
+
+	_, err = users.LoginUser(ctx, &model.UserLogin{Username: created.Username, HashedPassword: "wrong"})
+	assertStatus(t, http.StatusNotFound, err)
+
+	_, err = users.GetUserByID(ctx, model.UserID(utils.GenerateUUID()))
+	assertStatus(t, http.StatusNotFound, err)
+
+	_, err = users.CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: strings.ToUpper(created.Username)})
+	assertStatus(t, http.StatusConflict, err)
+
+	_, err = users.CreateUser(ctx, &model.UserRegister{ID: utils.GenerateUUID(), Username: uniqueUsername(), Sex: "unknown"})
+	assertStatus(t, http.StatusBadRequest, err)
+
+	city := "Kazan"
+	searchable := false
+	updated, err := users.UpdateUser(ctx, &model.UserUpdate{ID: created.UserID, City: &city, Searchable: &searchable})
+	require.NoError(t, err)
+	assert.Equal(t, "Nikita", updated.FirstName)
+	assert.Equal(t, city, updated.City)
+	assert.False(t, updated.Searchable)
+
+	_, err = users.UpdateUser(ctx, &model.UserUpdate{ID: model.UserID(utils.GenerateUUID()), City: &city})
+	assertStatus(t, http.StatusNotFound, err)
+
+	require.NoError(t, users.UpdatePassword(ctx, created.UserID, ""))
+	got, err = users.GetUserByID(ctx, created.UserID)
+	require.NoError(t, err)
+	assert.False(t, got.HasPassword)
+
+	err = users.UpdatePassword(ctx, model.UserID(utils.GenerateUUID()), "hash")
+	assertStatus(t, http.StatusNotFound, err)
+
+	missing := model.UserID(utils.GenerateUUID())
+	batch, err := users.GetUsersByIDs(ctx, []model.UserID{created.UserID, missing})
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, created.UserID, batch[0].UserID)
+}
+
+func testUserDeletion(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	users := s.User()
+
+	user := createUser(t, s, nil)
+	token := createToken(t, s, user.UserID, time.Hour)
+	login := &model.UserLogin{Username: user.Username, HashedPassword: "hash"}
+
+	require.NoError(t, users.DeleteUser(ctx, user.UserID, model.DeletedByUser))
+	assertStatus(t, http.StatusNotFound, users.DeleteUser(ctx, user.UserID, model.DeletedByUser))
+
+	_, err := users.GetUserByID(ctx, user.UserID)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = users.LoginUser(ctx, login)
+	assertStatus(t, http.StatusNotFound, err)
+	batch, err := users.GetUsersByIDs(ctx, []model.UserID{user.UserID})
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	_, err = users.RestoreUser(ctx, login, time.Now().Add(time.Minute))
+	assertStatus(t, http.StatusNotFound, err)
+
+	restored, err := users.RestoreUser(ctx, login, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, user.UserID, restored.UserID)
+
+	_, err = users.RestoreUserByID(ctx, user.UserID, time.Now().Add(-time.Minute), model.DeletedByUser)
+	assertStatus(t, http.StatusNotFound, err)
+	require.NoError(t, users.DeleteUser(ctx, user.UserID, model.DeletedByUser))
+	_, err = users.RestoreUserByID(ctx, user.UserID, time.Now().Add(time.Minute), model.DeletedByUser)
+	assertStatus(t, http.StatusNotFound, err)
+	restored, err = users.RestoreUserByID(ctx, user.UserID, time.Now().Add(-time.Minute), model.DeletedByUser)
+	require.NoError(t, err)
+	assert.Equal(t, user.Username, restored.Username)
+
+	// A deprovisioned account is only restored by provisioning, neither by the password nor by the user's id.
+	require.NoError(t, users.DeleteUser(ctx, user.UserID, model.DeletedByProvisioning))
+	deleted, err := users.GetUserWithDeleted(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.False(t, deleted.DeletedAt.IsZero())
+	assert.Equal(t, model.DeletedByProvisioning, deleted.DeletedBy)
+	_, err = users.RestoreUser(ctx, login, time.Now().Add(-time.Minute))
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = users.RestoreUserByID(ctx, user.UserID, time.Now().Add(-time.Minute), model.DeletedByUser)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = users.RestoreUserByID(ctx, user.UserID, time.Now().Add(-time.Minute), model.DeletedByProvisioning)
+	require.NoError(t, err)
+
+	require.NoError(t, users.DeleteUser(ctx, user.UserID, model.DeletedByUser))
+	purged, err := users.PurgeUsers(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+
+	_, err = users.RestoreUser(ctx, login, time.Now().Add(-time.Hour))
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = users.GetUserWithDeleted(ctx, user.UserID)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = s.Token().GetToken(ctx, token.Token)
+	assertStatus(t, http.StatusNotFound, err)
+
+	// Purging frees the username.
+	createUser(t, s, func(params *model.UserRegister) {
+		params.Username = user.Username
+	})
+}
+
+func testUsername(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	users := s.User()
+
+	user := createUser(t, s, nil)
+	other := createUser(t, s, nil)
+
+	got, err := users.GetUserByUsername(ctx, strings.ToUpper(user.Username))
+	require.NoError(t, err)
+	assert.Equal(t, user.UserID, got.UserID)
+
+	_, err = users.GetUserByUsername(ctx, uniqueUsername())
+	assertStatus(t, http.StatusNotFound, err)
+
+	_, err = users.ChangeUsername(ctx, user.UserID, strings.ToUpper(other.Username), time.Now().Add(time.Hour))
+	assertStatus(t, http.StatusConflict, err)
+
+	reservedUntil := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	renamed := uniqueUsername()
+	changed, err := users.ChangeUsername(ctx, user.UserID, renamed, reservedUntil)
+	require.NoError(t, err)
+	assert.Equal(t, renamed, changed.Username)
+
+	history, err := users.ListUsernameHistory(ctx, user.UserID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, user.Username, history[0].Username)
+	assert.True(t, reservedUntil.Equal(history[0].ReservedUntil))
+
+	record, err := users.GetUsernameRecord(ctx, strings.ToUpper(user.Username))
+	require.NoError(t, err)
+	assert.Equal(t, user.UserID, record.UserID)
+
+	_, err = users.GetUsernameRecord(ctx, renamed)
+	assertStatus(t, http.StatusNotFound, err)
+
+	_, err = users.ChangeUsername(ctx, model.UserID(utils.GenerateUUID()), uniqueUsername(), reservedUntil)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+func testListUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	city := "List " + utils.GenerateUUID()
+	first := createUser(t, s, func(params *model.UserRegister) { params.City = city })
+	// Users are listed by creation time, which is stored in milliseconds.
+	time.Sleep(2 * time.Millisecond)
+	second := createUser(t, s, func(params *model.UserRegister) { params.City = city })
+	deleted := createUser(t, s, func(params *model.UserRegister) { params.City = city })
+	require.NoError(t, s.User().DeleteUser(ctx, deleted.UserID, model.DeletedByUser))
+
+	users, total, err := s.User().ListUsers(ctx, &model.UserListParams{
+		Conditions: []model.UserCondition{{Field: model.UserFieldCity, Operator: model.OperatorEqual, Value: strings.ToUpper(city)}},
+		Limit:      10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), total)
+	assert.Equal(t, []model.UserID{first.UserID, second.UserID}, userIDs(users))
+
+	users, total, err = s.User().ListUsers(ctx, &model.UserListParams{
+		Conditions: []model.UserCondition{
+			{Field: model.UserFieldCity, Operator: model.OperatorEqual, Value: city},
+			{Field: model.UserFieldUsername, Operator: model.OperatorNotEqual, Value: first.Username},
+		},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), total)
+	assert.Equal(t, []model.UserID{second.UserID}, userIDs(users))
+
+	users, total, err = s.User().ListUsers(ctx, &model.UserListParams{
+		Conditions: []model.UserCondition{{Field: model.UserFieldCity, Operator: model.OperatorEqual, Value: city}},
+		Offset:     1,
+		Limit:      10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), total)
+	assert.Equal(t, []model.UserID{second.UserID}, userIDs(users))
+
+	_, _, err = s.User().ListUsers(ctx, &model.UserListParams{
+		Conditions: []model.UserCondition{{Field: "password", Operator: model.OperatorPresent}},
+	})
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+func testSearchUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	secondName := "Search" + utils.GenerateUUID()
+	anna := createUser(t, s, func(params *model.UserRegister) {
+		params.FirstName, params.SecondName, params.City = "Anna", secondName, "Moscow"
+	})
+	boris := createUser(t, s, func(params *model.UserRegister) {
+		params.FirstName, params.SecondName, params.City = "Boris", secondName, "Kazan"
+	})
+	hidden := createUser(t, s, func(params *model.UserRegister) {
+		params.FirstName, params.SecondName, params.City = "Anton", secondName, "Moscow"
+	})
+	private := model.VisibilityPrivate
+	_, err := s.User().UpdateUser(ctx, &model.UserUpdate{ID: hidden.UserID, CityVisibility: &private})
+	require.NoError(t, err)
+
+	users, total, err := s.User().SearchUsers(ctx, &model.UserSearchParams{SecondName: strings.ToLower(secondName), Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), total)
+	assert.Equal(t, []model.UserID{anna.UserID, hidden.UserID}, userIDs(users))
+
+	users, _, err = s.User().SearchUsers(ctx, &model.UserSearchParams{
+		SecondName: secondName,
+		After:      &model.UserCursor{SecondName: hidden.SecondName, FirstName: hidden.FirstName, ID: hidden.UserID},
+		Limit:      2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []model.UserID{boris.UserID}, userIDs(users))
+
+	// The city filter skips users who keep their city private.
+	users, total, err = s.User().SearchUsers(ctx, &model.UserSearchParams{SecondName: secondName, City: "moscow", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), total)
+	assert.Equal(t, []model.UserID{anna.UserID}, userIDs(users))
+
+	found, err := s.User().SearchUser(ctx, "Boris", secondName)
+	require.NoError(t, err)
+	assert.Equal(t, boris.UserID, found.UserID)
+
+	_, err = s.User().SearchUser(ctx, "Nobody", secondName)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+func testRoles(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	user := createUser(t, s, nil)
+
+	roles, err := s.User().GetRoles(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	require.NoError(t, s.User().SetRoles(ctx, user.UserID, []string{"moderator", "admin"}))
+	roles, err = s.User().GetRoles(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "moderator"}, roles)
+
+	require.NoError(t, s.User().SetRoles(ctx, user.UserID, nil))
+	roles, err = s.User().GetRoles(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+}
+
+func testToken(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	tokens := s.Token()
+	user := createUser(t, s, nil)
+
+	token := createToken(t, s, user.UserID, time.Hour)
+	got, err := tokens.GetToken(ctx, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, token, got)
+
+	expired := createToken(t, s, user.UserID, -time.Second)
+	_, err = tokens.GetToken(ctx, expired.Token)
+	assertStatus(t, http.StatusNotFound, err)
+
+	_, err = tokens.CreateToken(ctx, &model.TokenWithMetadata{TokenID: utils.GenerateUUID(), UserID: user.UserID})
+	assertStatus(t, http.StatusBadRequest, err)
+
+	require.NoError(t, tokens.ReauthenticateToken(ctx, token.TokenID))
+	assertStatus(t, http.StatusNotFound, tokens.ReauthenticateToken(ctx, model.TokenID(utils.GenerateUUID())))
+
+	require.NoError(t, tokens.RevokeToken(ctx, token))
+	assertStatus(t, http.StatusNotFound, tokens.RevokeToken(ctx, token))
+	_, err = tokens.GetToken(ctx, token.Token)
+	assertStatus(t, http.StatusNotFound, err)
+	assertStatus(t, http.StatusNotFound, tokens.ReauthenticateToken(ctx, token.TokenID))
+}
+
+func testSessions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	tokens := s.Token()
+	user := createUser(t, s, nil)
+
+	older := createToken(t, s, user.UserID, time.Hour)
+	newer := createToken(t, s, user.UserID, time.Hour)
+	createToken(t, s, user.UserID, -time.Second)
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, tokens.TouchToken(ctx, older.TokenID))
+
+	sessions, err := tokens.ListSessions(ctx, user.UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, older.TokenID, sessions[0].TokenID)
+	assert.Equal(t, newer.TokenID, sessions[1].TokenID)
+	assert.Equal(t, "test", sessions[0].Device.UserAgent)
+
+	client := model.ClientID("client-" + utils.GenerateUUID())
+	clientToken, err := tokens.CreateToken(ctx, &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   user.UserID,
+		Token:    utils.GenerateUUID(),
+		AlivedAt: time.Now().Add(time.Hour),
+		ClientID: client,
+		Scopes:   []string{"openid", "profile"},
+	})
+	require.NoError(t, err)
+	stored, err := tokens.GetToken(ctx, clientToken.Token)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openid", "profile"}, stored.Scopes)
+	assert.True(t, stored.AuthenticatedAt.IsZero())
+	assertStatus(t, http.StatusNotFound, tokens.ReauthenticateToken(ctx, clientToken.TokenID))
+	require.NoError(t, tokens.RevokeClientTokens(ctx, user.UserID, client))
+	_, err = tokens.GetToken(ctx, clientToken.Token)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = tokens.GetToken(ctx, older.Token)
+	require.NoError(t, err)
+
+	require.NoError(t, tokens.RevokeUserTokens(ctx, user.UserID))
+	sessions, err = tokens.ListSessions(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// The history keeps the revoked and the expired tokens.
+	history, err := tokens.ListSessionHistory(ctx, user.UserID)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	for _, session := range history {
+		if session.TokenID == clientToken.TokenID {
+			assert.False(t, session.RevokedAt.IsZero())
+			assert.Equal(t, client, session.ClientID)
+		}
+	}
+}
+
+func testTokenCleanup(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	tokens := s.Token()
+	user := createUser(t, s, nil)
+
+	active := createToken(t, s, user.UserID, time.Hour)
+	createToken(t, s, user.UserID, -time.Minute)
+	revoked := createToken(t, s, user.UserID, time.Hour)
+	require.NoError(t, tokens.RevokeToken(ctx, revoked))
+
+	deleted, err := tokens.DeleteExpiredTokens(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	deleted, err = tokens.DeleteExpiredTokens(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(2))
+
+	_, err = tokens.GetToken(ctx, active.Token)
+	require.NoError(t, err)
+	deleted, err = tokens.DeleteExpiredTokens(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func testConsent(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	consents := s.Consent()
+	user := createUser(t, s, nil)
+	client := model.ClientID("client-" + utils.GenerateUUID())
+
+	_, err := consents.GetConsent(ctx, user.UserID, client)
+	assertStatus(t, http.StatusNotFound, err)
+
+	granted, err := consents.GrantConsent(ctx, &model.Consent{UserID: user.UserID, ClientID: client, Scopes: []string{"profile", "openid"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openid", "profile"}, granted.Scopes)
+
+	got, err := consents.GetConsent(ctx, user.UserID, client)
+	require.NoError(t, err)
+	assert.Equal(t, granted, got)
+
+	require.NoError(t, consents.RevokeConsent(ctx, user.UserID, client))
+	assertStatus(t, http.StatusNotFound, consents.RevokeConsent(ctx, user.UserID, client))
+	listed, err := consents.ListConsents(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+
+	regranted, err := consents.GrantConsent(ctx, &model.Consent{UserID: user.UserID, ClientID: client, Scopes: []string{"openid"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openid"}, regranted.Scopes)
+	listed, err = consents.ListConsents(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Len(t, listed, 1)
+}
+
+func testIdentity(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	identities := s.Identity()
+	user := createUser(t, s, nil)
+	subject := utils.GenerateUUID()
+
+	identity, err := identities.CreateIdentity(ctx, &model.Identity{
+		IdentityID: model.IdentityID(utils.GenerateUUID()),
+		UserID:     user.UserID,
+		Provider:   "test",
+		Subject:    subject,
+		Email:      "user@example.com",
+	})
+	require.NoError(t, err)
+
+	got, err := identities.GetIdentity(ctx, "test", subject)
+	require.NoError(t, err)
+	assert.Equal(t, identity, got)
+
+	_, err = identities.CreateIdentity(ctx, &model.Identity{
+		IdentityID: model.IdentityID(utils.GenerateUUID()),
+		UserID:     user.UserID,
+		Provider:   "test",
+		Subject:    subject,
+	})
+	assertStatus(t, http.StatusConflict, err)
+
+	listed, err := identities.ListIdentities(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Identity{identity}, listed)
+
+	other := createUser(t, s, nil)
+	assertStatus(t, http.StatusNotFound, identities.DeleteIdentity(ctx, other.UserID, identity.IdentityID))
+	require.NoError(t, identities.DeleteIdentity(ctx, user.UserID, identity.IdentityID))
+	assertStatus(t, http.StatusNotFound, identities.DeleteIdentity(ctx, user.UserID, identity.IdentityID))
+
+	_, err = identities.GetIdentity(ctx, "test", subject)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+func testExport(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	exports := s.Export()
+	user := createUser(t, s, nil)
+
+	export, err := exports.CreateExport(ctx, &model.Export{ExportID: model.ExportID(utils.GenerateUUID()), UserID: user.UserID})
+	require.NoError(t, err)
+	assert.Equal(t, model.ExportStatusPending, export.Status)
+
+	got, err := exports.GetExport(ctx, export.ExportID)
+	require.NoError(t, err)
+	assert.Equal(t, export, got)
+
+	_, err = exports.GetExport(ctx, model.ExportID(utils.GenerateUUID()))
+	assertStatus(t, http.StatusNotFound, err)
+
+	// Only running exports can be finished, and only ready ones downloaded.
+	err = exports.CompleteExport(ctx, export.ExportID, []byte("archive"), time.Now().Add(time.Hour))
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = exports.GetExportArchive(ctx, export.ExportID)
+	assertStatus(t, http.StatusNotFound, err)
+
+	_, err = exports.DeleteExpiredExports(ctx, time.Now())
+	require.NoError(t, err)
+	_, err = exports.GetExport(ctx, export.ExportID)
+	require.NoError(t, err, "exports without expiry are kept")
+}
+
+func testWithTx(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var committed *model.User
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		committed = createUser(t, tx, nil)
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = s.User().GetUserByID(ctx, committed.UserID)
+	require.NoError(t, err)
+
+	failure := errors.New("rollback")
+	var rolledBack *model.User
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		rolledBack = createUser(t, tx, nil)
+		if err := tx.User().DeleteUser(ctx, committed.UserID, model.DeletedByUser); err != nil {
+			return err
+		}
+		return tx.WithTx(ctx, func(nested storage.Storage) error {
+			return failure
+		})
+	})
+	assert.ErrorIs(t, err, failure)
+
+	_, err = s.User().GetUserByID(ctx, rolledBack.UserID)
+	assertStatus(t, http.StatusNotFound, err)
+	_, err = s.User().GetUserByID(ctx, committed.UserID)
+	require.NoError(t, err, "the deletion is rolled back")
+
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.User().LockUser(ctx, committed.UserID); err != nil {
+			return err
+		}
+		return tx.User().UpdatePassword(ctx, committed.UserID, "locked")
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.User().DeleteUser(ctx, committed.UserID, model.DeletedByUser))
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		return tx.User().LockUser(ctx, committed.UserID)
+	})
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+// createUser registers a user with a unique username and the password hash "hash". update adjusts the params.
+func createUser(t *testing.T, s storage.Storage, update func(params *model.UserRegister)) *model.User {
+	t.Helper()
+
+	params := &model.UserRegister{
+		ID:             utils.GenerateUUID(),
+		Username:       uniqueUsername(),
+		HashedPassword: "hash",
+	}
+	if update != nil {
+		update(params)
+	}
+
+	user, err := s.User().CreateUser(context.Background(), params)
+	require.NoError(t, err)
+
+	return user
+}
+
+// createToken issues a token to the user that expires after lifetime.
+func createToken(t *testing.T, s storage.Storage, userID model.UserID, lifetime time.Duration) *model.Token {
+	t.Helper()
+
+	token, err := s.Token().CreateToken(context.Background(), &model.TokenWithMetadata{
+		TokenID:  utils.GenerateUUID(),
+		UserID:   userID,
+		Token:    utils.GenerateUUID(),
+		AlivedAt: time.Now().Add(lifetime),
+		Device:   model.Device{UserAgent: "test"},
+	})
+	require.NoError(t, err)
+
+	return token
+}
+
+func uniqueUsername() string {
+	return "user" + utils.GenerateUUID()
+}
+
+func userIDs(users []*model.User) []model.UserID {
+	ids := make([]model.UserID, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.UserID)
+	}
+
+	return ids
+}
+
+// assertStatus checks that err is an utils.ErrorResult with the status code.
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+
+	result, ok := utils.FromError(err)
+	if assert.True(t, ok, "error %v is not an ErrorResult", err) {
+		assert.Equal(t, status, result.StatusCode, result.Error())
+	}
+}
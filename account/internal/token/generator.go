@@ -31,10 +31,15 @@ func (g *Generator) GenerateToken(user *model.User) (string, error) {
 		return "", fmt.Errorf("user cannot be empty")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+	claims := jwt.MapClaims{
 		"iss": g.serverName,
 		"sub": user.Username,
-	})
+	}
+	if len(user.Roles) > 0 {
+		claims["roles"] = user.Roles
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
 
 	s, err := token.SignedString(key)
 	if err != nil {
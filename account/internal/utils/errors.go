@@ -4,14 +4,28 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 const (
 	ValidationErrorMessage string = "validation error: incorrect params"
 	NotFoundMessage        string = "not found"
-	InternalErrorMessage   string = "internal error"
+	UnauthorizedMessage    string = "unauthorized"
+	ConflictMessage        string = "conflict"
+
+	ReauthenticationRequiredMessage string = "reauthentication required"
+	CSRFTokenMismatchMessage        string = "csrf token mismatch"
+	InsufficientScopeMessage        string = "insufficient scope"
+	InvalidLinkMessage              string = "link is invalid or expired"
+	UsernameCooldownMessage         string = "username was changed too recently"
+	DeletedByUserMessage            string = "account was deleted by the user"
+	InternalErrorMessage            string = "internal error"
 )
 
+// uniqueViolationCode is the Postgres SQLSTATE for unique_violation.
+const uniqueViolationCode = "23505"
+
 type ErrorResult struct {
 	Err        error
 	Msg        string
@@ -30,6 +44,14 @@ func WrapValidationError(err error) *ErrorResult {
 	}
 }
 
+func WrapUnauthorizedError(err error, msg string) *ErrorResult {
+	return &ErrorResult{
+		Err:        err,
+		Msg:        msg,
+		StatusCode: http.StatusUnauthorized,
+	}
+}
+
 func WrapForbiddenError(err error, msg string) *ErrorResult {
 	return &ErrorResult{
 		Err:        err,
@@ -76,10 +98,19 @@ func FromError(err error) (*ErrorResult, bool) {
 	return result, true
 }
 
+func IsNotFoundError(err error) bool {
+	result, ok := FromError(err)
+	return ok && result.StatusCode == http.StatusNotFound
+}
+
 func WrapSqlError(err error) error {
+	var pgErr *pgconn.PgError
+
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		return WrapNotFoundError(err, NotFoundMessage)
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+		return WrapError(err, ConflictMessage, http.StatusConflict)
 	default:
 		return WrapInternalError(err)
 	}
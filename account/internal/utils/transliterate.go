@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+)
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// latinToCyrillic is ordered so that longer letter combinations are replaced first.
+var latinToCyrillic = strings.NewReplacer(
+	"shch", "щ", "zh", "ж", "kh", "х", "ts", "ц", "ch", "ч", "sh", "ш", "yu", "ю", "ya", "я", "yo", "ё",
+	"a", "а", "b", "б", "v", "в", "g", "г", "d", "д", "e", "е", "z", "з", "i", "и", "y", "й", "k", "к",
+	"l", "л", "m", "м", "n", "н", "o", "о", "p", "п", "r", "р", "s", "с", "t", "т", "u", "у", "f", "ф",
+	"h", "х", "c", "к", "w", "в", "x", "кс", "j", "дж", "q", "к",
+)
+
+// Transliterations returns the lower-cased text together with its Latin and Cyrillic transliterations
+// of Russian, without duplicates, so that "Никита" and "nikita" find each other.
+func Transliterations(text string) []string {
+	text = strings.ToLower(text)
+
+	var latin strings.Builder
+	for _, r := range text {
+		if replacement, ok := cyrillicToLatin[r]; ok {
+			latin.WriteString(replacement)
+		} else {
+			latin.WriteRune(r)
+		}
+	}
+
+	variants := []string{text}
+	for _, variant := range []string{latin.String(), latinToCyrillic.Replace(text)} {
+		if !slices.Contains(variants, variant) {
+			variants = append(variants, variant)
+		}
+	}
+
+	return variants
+}
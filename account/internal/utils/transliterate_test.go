@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransliterations(t *testing.T) {
+	assert.Equal(t, []string{"никита", "nikita"}, Transliterations("Никита"))
+	assert.Equal(t, []string{"nikita", "никита"}, Transliterations("nikita"))
+	assert.Equal(t, []string{"щукин", "shchukin"}, Transliterations("Щукин"))
+	assert.Equal(t, []string{"shchukin", "щукин"}, Transliterations("Shchukin"))
+	assert.Equal(t, []string{"42"}, Transliterations("42"))
+}
@@ -33,6 +33,6 @@ CREATE TABLE IF NOT EXISTS token_table
     CONSTRAINT fk_token_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
 );
 -- +goose Down
--- +goose StatementBegin
-SELECT 'down SQL query';
--- +goose StatementEnd
+DROP TABLE IF EXISTS token_table;
+
+DROP TABLE IF EXISTS user_table;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE token_table
+    ADD COLUMN IF NOT EXISTS client_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_token_table_user_id_client_id ON token_table (user_id, client_id);
+
+CREATE TABLE IF NOT EXISTS consent_table
+(
+    user_id    TEXT                     NOT NULL,
+    client_id  TEXT                     NOT NULL,
+    scopes     TEXT                     NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT pk_consent_table PRIMARY KEY (user_id, client_id),
+    CONSTRAINT fk_consent_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS consent_table;
+
+DROP INDEX IF EXISTS idx_token_table_user_id_client_id;
+
+ALTER TABLE token_table
+    DROP COLUMN IF EXISTS client_id;
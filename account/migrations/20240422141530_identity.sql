@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS identity_table
+(
+    id         TEXT                     NOT NULL,
+    user_id    TEXT                     NOT NULL,
+    provider   TEXT                     NOT NULL,
+    subject    TEXT                     NOT NULL,
+    email      TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT pk_identity_table PRIMARY KEY (id),
+    CONSTRAINT fk_identity_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_identity_table_provider_subject
+    ON identity_table (provider, subject) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_identity_table_user_id ON identity_table (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS identity_table;
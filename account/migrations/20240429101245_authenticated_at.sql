@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE token_table
+    ADD COLUMN IF NOT EXISTS authenticated_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE token_table
+SET authenticated_at = created_at
+WHERE authenticated_at IS NULL;
+
+ALTER TABLE token_table
+    ALTER COLUMN authenticated_at SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE token_table
+    DROP COLUMN IF EXISTS authenticated_at;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS user_role_table
+(
+    user_id    TEXT                     NOT NULL,
+    role       TEXT                     NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    CONSTRAINT pk_user_role_table PRIMARY KEY (user_id, role),
+    CONSTRAINT fk_user_role_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_role_table;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE user_table
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE user_table
+SET updated_at = created_at
+WHERE updated_at IS NULL;
+
+ALTER TABLE user_table
+    ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_username_lower
+    ON user_table (LOWER(username)) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_user_table_username_lower;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS updated_at;
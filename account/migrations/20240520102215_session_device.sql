@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE token_table
+    ADD COLUMN IF NOT EXISTS user_agent   TEXT,
+    ADD COLUMN IF NOT EXISTS ip_address   TEXT,
+    ADD COLUMN IF NOT EXISTS client_name  TEXT,
+    ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE token_table
+SET last_used_at = created_at
+WHERE last_used_at IS NULL;
+
+ALTER TABLE token_table
+    ALTER COLUMN last_used_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_token_table_user_id
+    ON token_table (user_id) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_token_table_user_id;
+
+ALTER TABLE token_table
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS client_name,
+    DROP COLUMN IF EXISTS last_used_at;
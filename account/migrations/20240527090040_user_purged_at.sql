@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE user_table
+    ADD COLUMN IF NOT EXISTS purged_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_deleted_at
+    ON user_table (deleted_at) WHERE deleted_at IS NOT NULL AND purged_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_user_table_deleted_at;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS purged_at;
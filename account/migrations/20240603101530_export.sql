@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS export_table
+(
+    id         TEXT                     NOT NULL,
+    user_id    TEXT                     NOT NULL,
+    status     TEXT                     NOT NULL,
+    error      TEXT,
+    archive    BYTEA,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT pk_export_table PRIMARY KEY (id),
+    CONSTRAINT fk_export_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_table_user_id ON export_table (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_export_table_status_updated_at
+    ON export_table (status, updated_at) WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP TABLE IF EXISTS export_table;
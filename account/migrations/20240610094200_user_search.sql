@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS idx_user_table_search_order
+    ON user_table (LOWER(second_name), LOWER(first_name), id) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_first_name_prefix
+    ON user_table (LOWER(first_name) text_pattern_ops) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_second_name_prefix
+    ON user_table (LOWER(second_name) text_pattern_ops) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_city_lower
+    ON user_table (LOWER(city)) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_birthdate
+    ON user_table (birthdate) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_user_table_birthdate;
+DROP INDEX IF EXISTS idx_user_table_city_lower;
+DROP INDEX IF EXISTS idx_user_table_second_name_prefix;
+DROP INDEX IF EXISTS idx_user_table_first_name_prefix;
+DROP INDEX IF EXISTS idx_user_table_search_order;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE user_table
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (
+        LOWER(COALESCE(first_name, '') || ' ' || COALESCE(second_name, '') || ' ' ||
+              COALESCE(username, '') || ' ' || COALESCE(city, ''))
+        ) STORED;
+
+ALTER TABLE user_table
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(first_name, '') || ' ' || COALESCE(second_name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(username, '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE(city, '')), 'C') ||
+        setweight(to_tsvector('simple', COALESCE(biography, '')), 'D')
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_search_text_trgm
+    ON user_table USING GIN (search_text gin_trgm_ops) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_search_vector
+    ON user_table USING GIN (search_vector) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_user_table_search_vector;
+DROP INDEX IF EXISTS idx_user_table_search_text_trgm;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS search_text;
+
+-- The pg_trgm extension is kept, other schemas may use it.
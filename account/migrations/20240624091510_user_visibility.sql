@@ -0,0 +1,66 @@
+-- +goose Up
+ALTER TABLE user_table
+    ADD COLUMN IF NOT EXISTS sex_visibility       TEXT    NOT NULL DEFAULT 'authenticated',
+    ADD COLUMN IF NOT EXISTS birthdate_visibility TEXT    NOT NULL DEFAULT 'authenticated',
+    ADD COLUMN IF NOT EXISTS biography_visibility TEXT    NOT NULL DEFAULT 'authenticated',
+    ADD COLUMN IF NOT EXISTS city_visibility      TEXT    NOT NULL DEFAULT 'authenticated',
+    ADD COLUMN IF NOT EXISTS searchable           BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Fuzzy search must not find users by the city or biography they keep private.
+DROP INDEX IF EXISTS idx_user_table_search_vector;
+DROP INDEX IF EXISTS idx_user_table_search_text_trgm;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE user_table
+    ADD COLUMN search_text TEXT GENERATED ALWAYS AS (
+        LOWER(COALESCE(first_name, '') || ' ' || COALESCE(second_name, '') || ' ' || COALESCE(username, '') || ' ' ||
+              CASE WHEN city_visibility <> 'private' THEN COALESCE(city, '') ELSE '' END)
+        ) STORED,
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(first_name, '') || ' ' || COALESCE(second_name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(username, '')), 'B') ||
+        setweight(to_tsvector('simple', CASE WHEN city_visibility <> 'private' THEN COALESCE(city, '') ELSE '' END), 'C') ||
+        setweight(to_tsvector('simple', CASE WHEN biography_visibility <> 'private' THEN COALESCE(biography, '') ELSE '' END), 'D')
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_search_text_trgm
+    ON user_table USING GIN (search_text gin_trgm_ops) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_search_vector
+    ON user_table USING GIN (search_vector) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_user_table_search_vector;
+DROP INDEX IF EXISTS idx_user_table_search_text_trgm;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE user_table
+    ADD COLUMN search_text TEXT GENERATED ALWAYS AS (
+        LOWER(COALESCE(first_name, '') || ' ' || COALESCE(second_name, '') || ' ' ||
+              COALESCE(username, '') || ' ' || COALESCE(city, ''))
+        ) STORED,
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(first_name, '') || ' ' || COALESCE(second_name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(username, '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE(city, '')), 'C') ||
+        setweight(to_tsvector('simple', COALESCE(biography, '')), 'D')
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_search_text_trgm
+    ON user_table USING GIN (search_text gin_trgm_ops) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_table_search_vector
+    ON user_table USING GIN (search_vector) WHERE deleted_at IS NULL;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS searchable,
+    DROP COLUMN IF EXISTS city_visibility,
+    DROP COLUMN IF EXISTS biography_visibility,
+    DROP COLUMN IF EXISTS birthdate_visibility,
+    DROP COLUMN IF EXISTS sex_visibility;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS username_history_table
+(
+    user_id        TEXT                     NOT NULL,
+    username       TEXT                     NOT NULL,
+
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    reserved_until TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    CONSTRAINT fk_username_history_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_table_username_lower
+    ON username_history_table (LOWER(username), created_at);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_table_user_id
+    ON username_history_table (user_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS username_history_table;
@@ -0,0 +1,44 @@
+-- +goose Up
+ALTER TABLE user_table
+    ADD COLUMN IF NOT EXISTS username_canonical TEXT;
+
+-- NFKC with lower case approximates the canonical form the application computes for new usernames.
+-- Of existing users whose usernames only differ in case or width the oldest one gets the canonical form,
+-- the others keep signing in with their exact username and get one when they change it.
+UPDATE user_table AS u
+SET username_canonical = LOWER(NORMALIZE(u.username, NFKC))
+WHERE NOT EXISTS (SELECT 1
+                  FROM user_table AS o
+                  WHERE LOWER(NORMALIZE(o.username, NFKC)) = LOWER(NORMALIZE(u.username, NFKC))
+                    AND (o.created_at, o.id) < (u.created_at, u.id));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_table_username_canonical
+    ON user_table (username_canonical);
+
+ALTER TABLE username_history_table
+    ADD COLUMN IF NOT EXISTS username_canonical TEXT;
+
+UPDATE username_history_table
+SET username_canonical = LOWER(NORMALIZE(username, NFKC));
+
+ALTER TABLE username_history_table
+    ALTER COLUMN username_canonical SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_username_history_table_username_lower;
+
+CREATE INDEX IF NOT EXISTS idx_username_history_table_username_canonical
+    ON username_history_table (username_canonical, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_username_history_table_username_canonical;
+
+CREATE INDEX IF NOT EXISTS idx_username_history_table_username_lower
+    ON username_history_table (LOWER(username), created_at);
+
+ALTER TABLE username_history_table
+    DROP COLUMN IF EXISTS username_canonical;
+
+DROP INDEX IF EXISTS idx_user_table_username_canonical;
+
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS username_canonical;
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS idx_token_table_alived_at ON token_table (alived_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_token_table_alived_at;
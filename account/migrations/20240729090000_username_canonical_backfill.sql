@@ -0,0 +1,43 @@
+-- +goose Up
+-- Users whose usernames only differed in case or width from an older account were left without a canonical
+-- username by 20240708100215_username_canonical, so lookups by username never found them. Each of them is
+-- renamed to their username with the lowest number appended that is free in canonical form, oldest user first.
+-- +goose StatementBegin
+DO
+$$
+    DECLARE
+        colliding RECORD;
+        suffix    INT;
+        candidate TEXT;
+    BEGIN
+        FOR colliding IN SELECT id, username
+                         FROM user_table
+                         WHERE username_canonical IS NULL
+                         ORDER BY created_at, id
+            LOOP
+                suffix := 2;
+                LOOP
+                    candidate := colliding.username || suffix;
+                    EXIT WHEN NOT EXISTS (SELECT 1
+                                          FROM user_table
+                                          WHERE username_canonical = LOWER(NORMALIZE(candidate, NFKC)))
+                        AND NOT EXISTS (SELECT 1
+                                        FROM username_history_table
+                                        WHERE username_canonical = LOWER(NORMALIZE(candidate, NFKC)));
+                    suffix := suffix + 1;
+                END LOOP;
+
+                UPDATE user_table
+                SET username           = candidate,
+                    username_canonical = LOWER(NORMALIZE(candidate, NFKC))
+                WHERE id = colliding.id;
+
+                RAISE NOTICE 'renamed user % from % to %', colliding.id, colliding.username, candidate;
+            END LOOP;
+    END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- The renames are kept, the previous usernames belong to the older accounts.
+SELECT 1;
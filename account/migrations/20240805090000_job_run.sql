@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS job_run_table
+(
+    name         TEXT                     NOT NULL,
+
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    started_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    CONSTRAINT pk_job_run_table PRIMARY KEY (name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_run_table;
@@ -0,0 +1,30 @@
+-- +goose Up
+ALTER TABLE token_table
+    ADD COLUMN IF NOT EXISTS scopes TEXT;
+
+-- Client tokens carry the scopes the user granted and never count as a fresh sign in.
+ALTER TABLE token_table
+    ALTER COLUMN authenticated_at DROP NOT NULL;
+
+UPDATE token_table t
+SET scopes = c.scopes
+FROM consent_table c
+WHERE t.client_id IS NOT NULL
+  AND c.user_id = t.user_id
+  AND c.client_id = t.client_id
+  AND c.deleted_at IS NULL;
+
+UPDATE token_table
+SET authenticated_at = NULL
+WHERE client_id IS NOT NULL;
+
+-- +goose Down
+UPDATE token_table
+SET authenticated_at = created_at
+WHERE authenticated_at IS NULL;
+
+ALTER TABLE token_table
+    ALTER COLUMN authenticated_at SET NOT NULL;
+
+ALTER TABLE token_table
+    DROP COLUMN IF EXISTS scopes;
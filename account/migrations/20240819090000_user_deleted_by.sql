@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE user_table
+    ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(32);
+
+-- Deletions before the column can't be told apart, they stay restorable by the user as they were.
+UPDATE user_table
+SET deleted_by = 'user'
+WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE user_table
+    DROP COLUMN IF EXISTS deleted_by;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- The outcome of the last run of each job, so that every instance reports the runs of all of them.
+ALTER TABLE job_run_table
+    ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS result      TEXT,
+    ADD COLUMN IF NOT EXISTS error       TEXT;
+
+-- +goose Down
+ALTER TABLE job_run_table
+    DROP COLUMN IF EXISTS finished_at,
+    DROP COLUMN IF EXISTS result,
+    DROP COLUMN IF EXISTS error;
@@ -0,0 +1,12 @@
+// Package migrations embeds the SQL migrations so that the binary can apply them without the source tree.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
+
+// SQLiteFS holds the migrations of the SQLite storage in the sqlite directory.
+//
+//go:embed sqlite/*.sql
+var SQLiteFS embed.FS
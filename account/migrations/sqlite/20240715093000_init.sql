@@ -0,0 +1,150 @@
+-- +goose Up
+-- SQLite only lower-cases ASCII, so the *_lower columns and search_text are filled by the application.
+-- Times are stored as Unix milliseconds.
+CREATE TABLE IF NOT EXISTS user_table
+(
+    id                   TEXT    NOT NULL,
+    username             TEXT    NOT NULL,
+    username_canonical   TEXT,
+    username_lower       TEXT    NOT NULL,
+    hashed_password      TEXT    NOT NULL,
+    first_name           TEXT    NOT NULL DEFAULT '',
+    first_name_lower     TEXT    NOT NULL DEFAULT '',
+    second_name          TEXT    NOT NULL DEFAULT '',
+    second_name_lower    TEXT    NOT NULL DEFAULT '',
+    sex                  TEXT    NOT NULL DEFAULT '',
+    birthdate            INTEGER,
+    biography            TEXT    NOT NULL DEFAULT '',
+    city                 TEXT    NOT NULL DEFAULT '',
+    city_lower           TEXT    NOT NULL DEFAULT '',
+    search_text          TEXT    NOT NULL DEFAULT '',
+
+    sex_visibility       TEXT    NOT NULL DEFAULT 'authenticated',
+    birthdate_visibility TEXT    NOT NULL DEFAULT 'authenticated',
+    biography_visibility TEXT    NOT NULL DEFAULT 'authenticated',
+    city_visibility      TEXT    NOT NULL DEFAULT 'authenticated',
+    searchable           INTEGER NOT NULL DEFAULT 1,
+
+    created_at           INTEGER NOT NULL,
+    updated_at           INTEGER NOT NULL,
+    deleted_at           INTEGER,
+    purged_at            INTEGER,
+
+    CONSTRAINT pk_user_table PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_table_username_canonical ON user_table (username_canonical);
+
+CREATE INDEX IF NOT EXISTS idx_user_table_search_order
+    ON user_table (second_name_lower, first_name_lower, id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS token_table
+(
+    id               TEXT    NOT NULL,
+    user_id          TEXT    NOT NULL,
+    token            TEXT    NOT NULL,
+    client_id        TEXT,
+
+    created_at       INTEGER NOT NULL,
+    deleted_at       INTEGER,
+    alived_at        INTEGER NOT NULL,
+    authenticated_at INTEGER NOT NULL,
+    last_used_at     INTEGER NOT NULL,
+
+    user_agent       TEXT,
+    ip_address       TEXT,
+    client_name      TEXT,
+
+    CONSTRAINT pk_token_table PRIMARY KEY (id),
+    CONSTRAINT fk_token_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_table_token ON token_table (token);
+CREATE INDEX IF NOT EXISTS idx_token_table_user_id ON token_table (user_id);
+
+CREATE TABLE IF NOT EXISTS consent_table
+(
+    user_id    TEXT    NOT NULL,
+    client_id  TEXT    NOT NULL,
+    scopes     TEXT    NOT NULL,
+
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    deleted_at INTEGER,
+
+    CONSTRAINT pk_consent_table PRIMARY KEY (user_id, client_id),
+    CONSTRAINT fk_consent_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE TABLE IF NOT EXISTS identity_table
+(
+    id         TEXT    NOT NULL,
+    user_id    TEXT    NOT NULL,
+    provider   TEXT    NOT NULL,
+    subject    TEXT    NOT NULL,
+    email      TEXT,
+
+    created_at INTEGER NOT NULL,
+    deleted_at INTEGER,
+
+    CONSTRAINT pk_identity_table PRIMARY KEY (id),
+    CONSTRAINT fk_identity_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_identity_table_provider_subject
+    ON identity_table (provider, subject) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_identity_table_user_id ON identity_table (user_id);
+
+CREATE TABLE IF NOT EXISTS user_role_table
+(
+    user_id    TEXT    NOT NULL,
+    role       TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+
+    CONSTRAINT pk_user_role_table PRIMARY KEY (user_id, role),
+    CONSTRAINT fk_user_role_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE TABLE IF NOT EXISTS export_table
+(
+    id         TEXT    NOT NULL,
+    user_id    TEXT    NOT NULL,
+    status     TEXT    NOT NULL,
+    error      TEXT,
+    archive    BLOB,
+
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    expires_at INTEGER,
+
+    CONSTRAINT pk_export_table PRIMARY KEY (id),
+    CONSTRAINT fk_export_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_table_status ON export_table (status, created_at);
+
+CREATE TABLE IF NOT EXISTS username_history_table
+(
+    user_id            TEXT    NOT NULL,
+    username           TEXT    NOT NULL,
+    username_canonical TEXT    NOT NULL,
+    created_at         INTEGER NOT NULL,
+    reserved_until     INTEGER NOT NULL,
+
+    CONSTRAINT fk_username_history_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_table_user_id ON username_history_table (user_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_table_username_canonical
+    ON username_history_table (username_canonical, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS username_history_table;
+DROP TABLE IF EXISTS export_table;
+DROP TABLE IF EXISTS user_role_table;
+DROP TABLE IF EXISTS identity_table;
+DROP TABLE IF EXISTS consent_table;
+DROP TABLE IF EXISTS token_table;
+DROP TABLE IF EXISTS user_table;
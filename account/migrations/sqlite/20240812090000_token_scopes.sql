@@ -0,0 +1,58 @@
+-- +goose Up
+-- SQLite can't drop NOT NULL from a column, so the table is rebuilt with authenticated_at optional.
+CREATE TABLE token_table_new
+(
+    id               TEXT    NOT NULL,
+    user_id          TEXT    NOT NULL,
+    token            TEXT    NOT NULL,
+    client_id        TEXT,
+    scopes           TEXT,
+
+    created_at       INTEGER NOT NULL,
+    deleted_at       INTEGER,
+    alived_at        INTEGER NOT NULL,
+    authenticated_at INTEGER,
+    last_used_at     INTEGER NOT NULL,
+
+    user_agent       TEXT,
+    ip_address       TEXT,
+    client_name      TEXT,
+
+    CONSTRAINT pk_token_table PRIMARY KEY (id),
+    CONSTRAINT fk_token_table_user_table FOREIGN KEY (user_id) REFERENCES user_table (id)
+);
+
+-- Client tokens carry the scopes the user granted and never count as a fresh sign in.
+INSERT INTO token_table_new (id, user_id, token, client_id, scopes, created_at, deleted_at, alived_at,
+                             authenticated_at, last_used_at, user_agent, ip_address, client_name)
+SELECT t.id,
+       t.user_id,
+       t.token,
+       t.client_id,
+       c.scopes,
+       t.created_at,
+       t.deleted_at,
+       t.alived_at,
+       CASE WHEN t.client_id IS NULL THEN t.authenticated_at END,
+       t.last_used_at,
+       t.user_agent,
+       t.ip_address,
+       t.client_name
+FROM token_table t
+         LEFT JOIN consent_table c
+                   ON c.user_id = t.user_id AND c.client_id = t.client_id AND c.deleted_at IS NULL;
+
+DROP TABLE token_table;
+ALTER TABLE token_table_new RENAME TO token_table;
+
+CREATE INDEX IF NOT EXISTS idx_token_table_token ON token_table (token);
+CREATE INDEX IF NOT EXISTS idx_token_table_user_id ON token_table (user_id);
+CREATE INDEX IF NOT EXISTS idx_token_table_alived_at ON token_table (alived_at);
+
+-- +goose Down
+UPDATE token_table
+SET authenticated_at = created_at
+WHERE authenticated_at IS NULL;
+
+ALTER TABLE token_table
+    DROP COLUMN scopes;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE user_table
+    ADD COLUMN deleted_by TEXT;
+
+-- Deletions before the column can't be told apart, they stay restorable by the user as they were.
+UPDATE user_table
+SET deleted_by = 'user'
+WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE user_table
+    DROP COLUMN deleted_by;